@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"effective-mobile-subscriptions/internal/config"
+	"effective-mobile-subscriptions/internal/repository"
+)
+
+// выполняет подкоманду check-dates: печатает подписки, у которых end_date раньше start_date
+func runCheckDates(cfg *config.Config) error {
+	if cfg.Storage.Driver != config.StorageDriverPostgres {
+		return fmt.Errorf("check-dates requires storage driver %q, got %q", config.StorageDriverPostgres, cfg.Storage.Driver)
+	}
+	db, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	invalid, err := repository.NewSubscriptionRepository(db).FindInvalidPeriods(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(invalid) == 0 {
+		fmt.Println("No subscriptions with end_date earlier than start_date")
+		fmt.Println("The constraint can be validated: ALTER TABLE subscriptions VALIDATE CONSTRAINT chk_subscriptions_end_date;")
+		return nil
+	}
+	for _, sub := range invalid {
+		fmt.Printf("%s  user=%s  service=%q  start_date=%s  end_date=%s\n",
+			sub.ID, sub.UserID, sub.ServiceName, sub.StartDate.Format("2006-01-02"), sub.EndDate.Format("2006-01-02"))
+	}
+	return fmt.Errorf("found %d subscriptions with end_date earlier than start_date", len(invalid))
+}
@@ -6,6 +6,7 @@ import (
 	_ "effective-mobile-subscriptions/docs"
 	"effective-mobile-subscriptions/internal/config"
 	"effective-mobile-subscriptions/internal/handler"
+	"effective-mobile-subscriptions/internal/rates"
 	"effective-mobile-subscriptions/internal/repository"
 	"effective-mobile-subscriptions/internal/service"
 	"errors"
@@ -36,42 +37,94 @@ import (
 // @BasePath /
 
 func main() {
-	// загрузка конфигурации
-	cfg, err := config.LoadConfig("./internal/config")
+	// загрузка конфигурации: defaults -> yaml -> SUBS_* env -> флаги
+	cfg, args, err := config.Load(os.Args[1:])
 	if err != nil {
 		log.Fatalf("Configuration loading error: %v", err)
 	}
 
-	// инициализация бд
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Database.Host, cfg.Database.Port, cfg.Database.User, cfg.Database.Password, cfg.Database.DBName, cfg.Database.SSLMode)
+	// подкоманды: migrate up | migrate down N | migrate status | config print | check-dates | map-services [apply]
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			if err := runMigrate(cfg, args[1:]); err != nil {
+				log.Fatalf("Migration error: %v", err)
+			}
+			return
+		case "config":
+			if len(args) < 2 || args[1] != "print" {
+				log.Fatalf("Usage: config print")
+			}
+			if err := config.Print(os.Stdout, cfg); err != nil {
+				log.Fatalf("Configuration printing error: %v", err)
+			}
+			return
+		case "check-dates":
+			if err := runCheckDates(cfg); err != nil {
+				log.Fatalf("Date check failed: %v", err)
+			}
+			return
+		case "map-services":
+			if err := runMapServices(cfg, args[1:]); err != nil {
+				log.Fatalf("Service mapping failed: %v", err)
+			}
+			return
+		default:
+			log.Fatalf("Unknown command %q (expected: migrate, config, check-dates, map-services)", args[0])
+		}
+	}
 
-	db, err := sql.Open("postgres", connStr)
+	// инициализация слоев
+	subRepo, db, closeStore, err := newSubscriptionStore(cfg)
 	if err != nil {
-		log.Fatalf("Failed to open connection to DB: %v", err)
+		log.Fatalf("Storage initialization error: %v", err)
 	}
-	defer db.Close()
-
-	// проверка соединения
-	if err := db.Ping(); err != nil {
-		log.Fatalf("Failed to connect to DB: %v", err)
+	defer closeStore()
+	rateProvider, err := newRateProvider(cfg, db)
+	if err != nil {
+		log.Fatalf("Exchange rates initialization error: %v", err)
 	}
-	log.Println("Successfully connected to PostgreSQL!")
-
-	// инициализация слоев
-	subRepo := repository.NewSubscriptionRepository(db)
-	subService := service.NewSubscriptionService(subRepo)
+	subService := service.NewSubscriptionService(subRepo, rateProvider)
+	subService.IdempotencyTTL = cfg.Idempotency.TTL
+	subService.OverlapPolicy = cfg.Overlap.Policy
 	subHandler := handler.NewSubscriptionHandler(subService)
 
+	// фоновая очистка мягко удаленных подписок
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	defer stopPurge()
+	go runPurger(purgeCtx, subService, cfg.Purge)
+	go runIdempotencyCleanup(purgeCtx, subService, cfg.Idempotency)
+
 	// настройка Роутера
 	r := mux.NewRouter()
+	r.Use(handler.RequestIDMiddleware)
+	r.Use(handler.ActorMiddleware)
 
 	r.HandleFunc("/subscriptions", subHandler.CreateSubscription).Methods("POST")
 	r.HandleFunc("/subscriptions", subHandler.ListSubscriptions).Methods("GET")
+	r.HandleFunc("/subscriptions/batch", subHandler.CreateSubscriptionsBatch).Methods("POST")
+	r.HandleFunc("/subscriptions/import", subHandler.ImportSubscriptions).Methods("POST")
+	r.HandleFunc("/subscriptions/export", subHandler.ExportSubscriptions).Methods("GET")
+	r.HandleFunc("/subscriptions/overlaps", subHandler.GetSubscriptionOverlaps).Methods("GET")
 	r.HandleFunc("/subscriptions/analytics", subHandler.GetCostAnalytics).Methods("GET")
+	r.HandleFunc("/subscriptions/analytics/timeseries", subHandler.GetCostTimeSeries).Methods("GET")
 	r.HandleFunc("/subscriptions/{id}", subHandler.GetSubscriptionByID).Methods("GET")
 	r.HandleFunc("/subscriptions/{id}", subHandler.UpdateSubscription).Methods("PUT")
 	r.HandleFunc("/subscriptions/{id}", subHandler.DeleteSubscription).Methods("DELETE")
+	r.HandleFunc("/subscriptions/{id}/history", subHandler.GetSubscriptionHistory).Methods("GET")
+	r.HandleFunc("/subscriptions/{id}/price-changes", subHandler.ChangeSubscriptionPrice).Methods("POST")
+	r.HandleFunc("/subscriptions/{id}/price-changes", subHandler.GetSubscriptionPriceChanges).Methods("GET")
+	r.HandleFunc("/subscriptions/{id}/charges", subHandler.GetUpcomingCharges).Methods("GET")
+	r.HandleFunc("/subscriptions/{id}/restore", subHandler.RestoreSubscription).Methods("POST")
+	r.HandleFunc("/subscriptions/{id}/cancel", subHandler.CancelSubscription).Methods("POST")
+	r.HandleFunc("/subscriptions/{id}/pause", subHandler.PauseSubscription).Methods("POST")
+	r.HandleFunc("/subscriptions/{id}/resume", subHandler.ResumeSubscription).Methods("POST")
+	r.HandleFunc("/subscriptions/{id}/pauses", subHandler.GetSubscriptionPauses).Methods("GET")
+	r.HandleFunc("/services", subHandler.CreateService).Methods("POST")
+	r.HandleFunc("/services", subHandler.ListServices).Methods("GET")
+	r.HandleFunc("/services/{id}", subHandler.GetService).Methods("GET")
+	r.HandleFunc("/services/{id}", subHandler.UpdateService).Methods("PUT")
+	r.HandleFunc("/services/{id}", subHandler.DeleteService).Methods("DELETE")
 	r.PathPrefix("/swagger/").Handler(http.StripPrefix("/swagger/", http.FileServer(http.Dir("./docs"))))
 
 	// запуск HTTP-сервера
@@ -97,6 +150,7 @@ func main() {
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 	log.Println("Shutdown signal received, attempting graceful shutdown...")
+	stopPurge()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -106,3 +160,52 @@ func main() {
 	}
 	log.Println("Server stopped gracefully")
 }
+
+// создает хранилище подписок согласно storage.driver, соединение с PostgreSQL (nil для memory)
+// и функцию закрытия хранилища
+func newSubscriptionStore(cfg *config.Config) (repository.Store, *sql.DB, func(), error) {
+	if cfg.Storage.Driver == config.StorageDriverMemory {
+		log.Println("Using in-memory storage, data will be lost on restart")
+		return repository.NewMemorySubscriptionRepository(), nil, func() {}, nil
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if cfg.Database.AutoMigrate {
+		if _, err := newMigrator(db).Up(context.Background()); err != nil {
+			db.Close()
+			return nil, nil, nil, fmt.Errorf("auto-migration failed: %w", err)
+		}
+	}
+
+	return repository.NewSubscriptionRepository(db), db, func() { db.Close() }, nil
+}
+
+// открывает и проверяет соединение с PostgreSQL
+func openDB(cfg *config.Config) (*sql.DB, error) {
+	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		cfg.Database.Host, cfg.Database.Port, cfg.Database.User, cfg.Database.Password, cfg.Database.DBName, cfg.Database.SSLMode)
+
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open connection to DB: %w", err)
+	}
+
+	// проверка соединения
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to DB: %w", err)
+	}
+	log.Println("Successfully connected to PostgreSQL!")
+	return db, nil
+}
+
+// создает источник курсов валют согласно rates.source; db нужен для источника table
+func newRateProvider(cfg *config.Config, db *sql.DB) (rates.Provider, error) {
+	if cfg.Rates.Source == config.RatesSourceTable {
+		return rates.NewTableProvider(db, cfg.Rates.Base), nil
+	}
+	return rates.NewFileProvider(cfg.Rates.Base, cfg.Rates.File)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"effective-mobile-subscriptions/internal/config"
+	"effective-mobile-subscriptions/internal/repository"
+	"effective-mobile-subscriptions/internal/service"
+)
+
+// выполняет подкоманду map-services [apply]: сопоставляет названия сервисов из подписок с каталогом
+// и переводит подписки на канонические названия. Без apply только печатает результат, ничего не сохраняя
+func runMapServices(cfg *config.Config, args []string) error {
+	if cfg.Storage.Driver != config.StorageDriverPostgres {
+		return fmt.Errorf("map-services requires storage driver %q, got %q", config.StorageDriverPostgres, cfg.Storage.Driver)
+	}
+	apply := false
+	if len(args) > 0 {
+		if args[0] != "apply" {
+			return errors.New("usage: map-services [apply]")
+		}
+		apply = true
+	}
+	db, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	rateProvider, err := newRateProvider(cfg, db)
+	if err != nil {
+		return err
+	}
+
+	svc := service.NewSubscriptionService(repository.NewSubscriptionRepository(db), rateProvider)
+	mappings, err := svc.MapServiceNames(context.Background(), apply)
+	if err != nil {
+		return err
+	}
+	var remapped, unmatched int64
+	for _, mapping := range mappings {
+		if mapping.Service == nil {
+			unmatched++
+			fmt.Printf("%q (%d subscriptions): not found in the catalogue\n", mapping.ServiceName, mapping.Subscriptions)
+			continue
+		}
+		if mapping.Remapped == 0 {
+			continue
+		}
+		remapped += mapping.Remapped
+		fmt.Printf("%q -> %q: %d of %d subscriptions", mapping.ServiceName, mapping.Service.Name, mapping.Remapped, mapping.Subscriptions)
+		if mapping.OverlapAllowed > 0 {
+			fmt.Printf(", %d marked as allowed overlap", mapping.OverlapAllowed)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("%d subscriptions mapped, %d service names not found in the catalogue\n", remapped, unmatched)
+	if !apply && remapped > 0 {
+		fmt.Println("Dry run, nothing was saved. Run `map-services apply` to save the mapping")
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"effective-mobile-subscriptions/internal/config"
+	"effective-mobile-subscriptions/internal/migrate"
+	"effective-mobile-subscriptions/migrations"
+)
+
+// создает мигратор над встроенными в бинарник миграциями
+func newMigrator(db *sql.DB) *migrate.Migrator {
+	migrator, err := migrate.NewMigrator(db, migrations.FS)
+	if err != nil {
+		// набор миграций встроен при сборке, ошибка здесь означает битый бинарник
+		log.Fatalf("Failed to load embedded migrations: %v", err)
+	}
+	return migrator
+}
+
+// выполняет подкоманду migrate: up | down N | status
+func runMigrate(cfg *config.Config, args []string) error {
+	if cfg.Storage.Driver != config.StorageDriverPostgres {
+		return fmt.Errorf("migrations require storage driver %q, got %q", config.StorageDriverPostgres, cfg.Storage.Driver)
+	}
+	if len(args) == 0 {
+		return errors.New("usage: migrate up | migrate down N | migrate status")
+	}
+	db, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	migrator := newMigrator(db)
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+		return nil
+	case "down":
+		if len(args) < 2 {
+			return errors.New("usage: migrate down N")
+		}
+		steps, err := strconv.Atoi(args[1])
+		if err != nil || steps <= 0 {
+			return fmt.Errorf("incorrect number of migrations to roll back: %q", args[1])
+		}
+		_, err = migrator.Down(ctx, steps)
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			switch {
+			case status.AppliedAt != nil:
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			case status.BaselinePending:
+				state = "unversioned, baseline pending"
+			}
+			fmt.Printf("V%-4d %-40s %s\n", status.Version, status.Name, state)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q (expected up, down or status)", args[0])
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"effective-mobile-subscriptions/internal/config"
+	"effective-mobile-subscriptions/internal/service"
+)
+
+// периодически удаляет истекшие ключи идемпотентности; останавливается по ctx
+func runIdempotencyCleanup(ctx context.Context, svc *service.SubscriptionService, cfg config.IdempotencyConfig) {
+	ticker := time.NewTicker(cfg.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		purged, err := svc.PurgeExpiredIdempotencyKeys(ctx)
+		if err != nil {
+			log.Printf("ERROR: Cleanup of expired idempotency keys failed: %v", err)
+		} else if purged > 0 {
+			log.Printf("Removed %d expired idempotency keys", purged)
+		}
+	}
+}
+
+// периодически удаляет подписки, мягко удаленные раньше cfg.Retention; останавливается по ctx
+func runPurger(ctx context.Context, svc *service.SubscriptionService, cfg config.PurgeConfig) {
+	if cfg.Retention <= 0 {
+		log.Println("Purge of deleted subscriptions is disabled")
+		return
+	}
+	log.Printf("Purging subscriptions deleted more than %s ago every %s", cfg.Retention, cfg.Interval)
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	for {
+		purged, err := svc.PurgeDeleted(ctx, cfg.Retention)
+		if err != nil {
+			log.Printf("ERROR: Purge of deleted subscriptions failed: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted subscriptions", purged)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
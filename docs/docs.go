@@ -22,35 +22,44 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/subscriptions": {
+        "/services": {
             "get": {
+                "description": "Записи каталога в порядке названия.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "services"
+                ],
+                "summary": "Каталог сервисов",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Фильтр по категории",
+                        "name": "category",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Получить список всех подписок",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/model.Subscription"
+                                "$ref": "#/definitions/model.Service"
                             }
                         }
                     },
                     "500": {
-                        "description": "Ошибка БД/сервиса",
+                        "description": "Ошибка сервиса или БД",
                         "schema": {
-                            "$ref": "#/definitions/handler.InternalServerErrorResponse"
+                            "$ref": "#/definitions/handler.ProblemDetails"
                         }
                     }
                 }
             },
             "post": {
-                "description": "Создает новую запись об онлайн-подписке",
+                "description": "Каноническое название и синонимы сравниваются без учета регистра и лишних пробелов и должны быть уникальны во всем каталоге.\nПодписки, созданные с любым из этих названий, сохраняются под каноническим. Существующие подписки\nпереводятся на каталог подкомандой map-services.",
                 "consumes": [
                     "application/json"
                 ],
@@ -58,17 +67,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "services"
                 ],
-                "summary": "Создать новую подписку",
+                "summary": "Добавить сервис в каталог",
                 "parameters": [
                     {
-                        "description": "Данные новой подписки",
-                        "name": "subscription",
+                        "description": "Название, синонимы, категория и цена по умолчанию",
+                        "name": "service",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.CreateSubscriptionRequest"
+                            "$ref": "#/definitions/model.ServiceRequest"
                         }
                     }
                 ],
@@ -76,82 +85,43 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/model.Subscription"
+                            "$ref": "#/definitions/model.Service"
                         }
                     },
                     "400": {
-                        "description": "Некорректный запрос или ошибка валидации (UUID, дата, формат JSON)",
+                        "description": "Некорректный запрос или ошибка валидации",
                         "schema": {
-                            "$ref": "#/definitions/handler.BadRequestResponse"
+                            "$ref": "#/definitions/handler.ProblemDetails"
                         }
-                    }
-                }
-            }
-        },
-        "/subscriptions/analytics": {
-            "get": {
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "subscriptions"
-                ],
-                "summary": "Подсчет суммарной стоимости подписок по фильтрам",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Фильтр по UUID пользователя",
-                        "name": "user_id",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Фильтр по названию подписки",
-                        "name": "service_name",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Период от (MM-YYYY)",
-                        "name": "start_date_from",
-                        "in": "query"
                     },
-                    {
-                        "type": "string",
-                        "description": "Период до (MM-YYYY)",
-                        "name": "start_date_to",
-                        "in": "query"
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
+                    "409": {
+                        "description": "Название или синоним заняты другим сервисом (service_name_taken)",
                         "schema": {
-                            "$ref": "#/definitions/handler.CostAnalyticsResponse"
+                            "$ref": "#/definitions/handler.ProblemDetails"
                         }
                     },
-                    "400": {
-                        "description": "Ошибка валидации параметров запроса (UUID, дата)",
+                    "500": {
+                        "description": "Ошибка сервиса или БД",
                         "schema": {
-                            "$ref": "#/definitions/handler.BadRequestResponse"
+                            "$ref": "#/definitions/handler.ProblemDetails"
                         }
                     }
                 }
             }
         },
-        "/subscriptions/{id}": {
+        "/services/{id}": {
             "get": {
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "services"
                 ],
-                "summary": "Получить подписку по ID",
+                "summary": "Получить сервис каталога по ID",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "UUID подписки",
+                        "description": "UUID сервиса",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -161,24 +131,25 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.Subscription"
+                            "$ref": "#/definitions/model.Service"
                         }
                     },
                     "400": {
                         "description": "Некорректный формат ID",
                         "schema": {
-                            "$ref": "#/definitions/handler.BadRequestResponse"
+                            "$ref": "#/definitions/handler.ProblemDetails"
                         }
                     },
                     "404": {
-                        "description": "Подписка не найдена",
+                        "description": "Сервис не найден",
                         "schema": {
-                            "$ref": "#/definitions/handler.SubscriptionNotFoundResponse"
+                            "$ref": "#/definitions/handler.ProblemDetails"
                         }
                     }
                 }
             },
             "put": {
+                "description": "Заменяет название, синонимы, категорию и цену по умолчанию. При смене названия подписки,\nсохраненные под прежним, переводятся на новое.",
                 "consumes": [
                     "application/json"
                 ],
@@ -186,24 +157,24 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "services"
                 ],
-                "summary": "Обновить существующую подписку",
+                "summary": "Заменить сервис каталога",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "UUID подписки",
+                        "description": "UUID сервиса",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Обновленные данные подписки",
-                        "name": "subscription",
+                        "description": "Новые данные сервиса",
+                        "name": "service",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.UpdateSubscriptionRequest"
+                            "$ref": "#/definitions/model.ServiceRequest"
                         }
                     }
                 ],
@@ -211,32 +182,45 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.Subscription"
+                            "$ref": "#/definitions/model.Service"
                         }
                     },
                     "400": {
-                        "description": "Некорректный запрос или ошибка валидации",
+                        "description": "Некорректный запрос, формат ID или ошибка валидации",
                         "schema": {
-                            "$ref": "#/definitions/handler.BadRequestResponse"
+                            "$ref": "#/definitions/handler.ProblemDetails"
                         }
                     },
                     "404": {
-                        "description": "Подписка не найдена",
+                        "description": "Сервис не найден",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "409": {
+                        "description": "Название или синоним заняты другим сервисом (service_name_taken)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервиса или БД",
                         "schema": {
-                            "$ref": "#/definitions/handler.SubscriptionNotFoundResponse"
+                            "$ref": "#/definitions/handler.ProblemDetails"
                         }
                     }
                 }
             },
             "delete": {
+                "description": "Подписки сохраняют название, но теряют ссылку на запись каталога (service_id).",
                 "tags": [
-                    "subscriptions"
+                    "services"
                 ],
-                "summary": "Удалить подписку по ID",
+                "summary": "Удалить сервис из каталога",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "UUID подписки",
+                        "description": "UUID сервиса",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -244,122 +228,2063 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "204": {
-                        "description": "Подписка успешно удалена (No Content)"
+                        "description": "Сервис удален (No Content)"
                     },
                     "400": {
                         "description": "Некорректный формат ID",
                         "schema": {
-                            "$ref": "#/definitions/handler.BadRequestResponse"
+                            "$ref": "#/definitions/handler.ProblemDetails"
                         }
                     },
                     "404": {
-                        "description": "Подписка не найдена",
+                        "description": "Сервис не найден",
                         "schema": {
-                            "$ref": "#/definitions/handler.SubscriptionNotFoundResponse"
+                            "$ref": "#/definitions/handler.ProblemDetails"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "handler.BadRequestResponse": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string",
-                    "example": "некорректный формат user_id (ожидается UUID)"
-                }
-            }
-        },
-        "handler.CostAnalyticsResponse": {
-            "type": "object",
-            "properties": {
-                "total_cost": {
-                    "type": "integer"
-                }
-            }
-        },
-        "handler.InternalServerErrorResponse": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string",
-                    "example": "Internal Server Error"
-                }
-            }
         },
-        "handler.SubscriptionNotFoundResponse": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string",
-                    "example": "Subscription not found"
+        "/subscriptions": {
+            "get": {
+                "description": "Список с фильтрами, сортировкой по любому столбцу и keyset-пагинацией.\nДля следующей страницы передайте next_cursor из ответа в параметре cursor с теми же sort_by и order.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Получить список подписок",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Фильтр по UUID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтр по названию подписки",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Минимальная цена (включительно)",
+                        "name": "price_min",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Максимальная цена (включительно)",
+                        "name": "price_max",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Подписка активна в месяце (MM-YYYY)",
+                        "name": "active_at",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "true — только бессрочные, false — только с end_date",
+                        "name": "open_ended",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "id",
+                            "user_id",
+                            "service_name",
+                            "price",
+                            "start_date",
+                            "end_date",
+                            "created_at"
+                        ],
+                        "type": "string",
+                        "default": "created_at",
+                        "description": "Столбец сортировки",
+                        "name": "sort_by",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Направление сортировки (по умолчанию desc для created_at, иначе asc)",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Размер страницы (1-500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Курсор следующей страницы",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Включать мягко удаленные подписки",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SubscriptionPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации параметров запроса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка БД/сервиса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
                 }
-            }
-        },
-        "model.CreateSubscriptionRequest": {
-            "type": "object",
-            "properties": {
-                "price": {
-                    "type": "integer"
-                },
-                "service_name": {
-                    "type": "string"
-                },
-                "start_date": {
-                    "description": "Входящая дата в формате \"MM-YYYY\"",
-                    "type": "string"
-                },
-                "user_id": {
-                    "description": "Входящий ID в виде строки UUID",
-                    "type": "string"
+            },
+            "post": {
+                "description": "Создает новую запись об онлайн-подписке.\nС заголовком Idempotency-Key повтор запроса с тем же телом не создает дубль, а возвращает исходный ответ 201\n(с заголовком Idempotent-Replayed: true); тот же ключ с другим телом — 422. Ключ действует idempotency.ttl (по умолчанию 24 часа).\nПересечение периода с другой подпиской пользователя на тот же сервис обрабатывается по overlap.policy:\nreject — 409, warn — подписка создается, в поле overlaps перечислены пересекающиеся подписки, allow — не проверяется.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Создать новую подписку",
+                "parameters": [
+                    {
+                        "description": "Данные новой подписки",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.CreateSubscriptionRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Автор изменения для истории",
+                        "name": "X-Actor",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ключ идемпотентности (до 255 символов)",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        },
+                        "headers": {
+                            "Idempotent-Replayed": {
+                                "type": "string",
+                                "description": "true, если ответ повторен по Idempotency-Key"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный запрос или ошибка валидации (UUID, дата, формат JSON)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "409": {
+                        "description": "Период пересекается с другой подпиской на тот же сервис (subscription_overlap)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "422": {
+                        "description": "Idempotency-Key уже использован с другим телом запроса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
                 }
             }
         },
-        "model.Subscription": {
-            "type": "object",
-            "properties": {
-                "created_at": {
-                    "type": "string"
-                },
-                "end_date": {
-                    "description": "Используем *time.Time, чтобы разрешить NULL (опционально)",
-                    "type": "string"
-                },
-                "id": {
-                    "type": "string"
-                },
-                "price": {
-                    "description": "Целое число рублей",
+        "/subscriptions/analytics": {
+            "get": {
+                "description": "В режиме prorated (по умолчанию) цена подписки умножается на число месяцев её активности внутри периода,\nбессрочные подписки учитываются до текущего месяца. В режиме start_date суммируются цены подписок, начавшихся в периоде.\nС параметром group_by вместо объекта возвращается массив model.CostGroup ({key, total_cost, currency, count}).\nЦены в разных валютах пересчитываются в валюту currency (по умолчанию базовую) по курсам источника курсов, сумма округляется.\nЦена подписки относится к её периоду списания: с basis=monthly_equivalent (по умолчанию) она приводится к месячной,\nс basis=charged учитывается в месяцах фактических списаний.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Подсчет суммарной стоимости подписок по фильтрам",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Фильтр по UUID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтр по названию подписки",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Период от (MM-YYYY или YYYY-MM-DD)",
+                        "name": "start_date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Период до включительно (MM-YYYY — до конца месяца, или YYYY-MM-DD)",
+                        "name": "start_date_to",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "prorated",
+                            "start_date"
+                        ],
+                        "type": "string",
+                        "default": "prorated",
+                        "description": "Режим подсчета",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "monthly_equivalent",
+                            "charged"
+                        ],
+                        "type": "string",
+                        "default": "monthly_equivalent",
+                        "description": "Учет периода списания",
+                        "name": "basis",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "month",
+                            "day"
+                        ],
+                        "type": "string",
+                        "default": "month",
+                        "description": "Неполные месяцы: целиком (month) или по дням (day, только mode=prorated и basis=monthly_equivalent)",
+                        "name": "proration",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Измерения группировки через запятую: service_name, user_id, month",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "USD",
+                        "description": "Валюта результата (ISO 4217), по умолчанию базовая",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.CostAnalyticsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации параметров запроса (UUID, дата, валюта)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "422": {
+                        "description": "В выборке есть подписки в валюте без курса пересчета (unsupported_currency)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/analytics/timeseries": {
+            "get": {
+                "description": "Возвращает по одной записи на каждый месяц периода (включая месяцы без трат): стоимость, число активных,\nновых и завершившихся подписок. Без start_date_to ряд заканчивается текущим месяцем, без start_date_from охватывает 12 месяцев.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Помесячный ряд стоимости подписок",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Фильтр по UUID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтр по названию подписки",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Период от (MM-YYYY или YYYY-MM-DD)",
+                        "name": "start_date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Период до включительно (MM-YYYY — до конца месяца, или YYYY-MM-DD)",
+                        "name": "start_date_to",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "monthly_equivalent",
+                            "charged"
+                        ],
+                        "type": "string",
+                        "default": "monthly_equivalent",
+                        "description": "Учет периода списания",
+                        "name": "basis",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "month",
+                            "day"
+                        ],
+                        "type": "string",
+                        "default": "month",
+                        "description": "Неполные месяцы: целиком (month) или по дням (day, только basis=monthly_equivalent)",
+                        "name": "proration",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "USD",
+                        "description": "Валюта результата (ISO 4217), по умолчанию базовая",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.CostBucket"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации параметров запроса (UUID, дата, длина периода, валюта)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "422": {
+                        "description": "В выборке есть подписки в валюте без курса пересчета (unsupported_currency)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка БД/сервиса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/batch": {
+            "post": {
+                "description": "Каждый элемент массива проверяется так же, как в POST /subscriptions; корректные элементы сохраняются одним запросом.\nВ ответе по каждому элементу: created, invalid (ошибки валидации или пересечение с подпиской на тот же сервис), failed (ошибка сохранения)\nили skipped (atomic=true и в пакете есть ошибки — не создано ничего). Не более 1000 элементов.\nСтатус 201 — созданы все, 207 — созданы не все, 422 — atomic-пакет отменен.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Создать несколько подписок",
+                "parameters": [
+                    {
+                        "description": "Данные новых подписок",
+                        "name": "subscriptions",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.CreateSubscriptionRequest"
+                            }
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Создать все подписки или ни одной",
+                        "name": "atomic",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Автор изменения для истории",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.BatchCreateResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Multi-Status",
+                        "schema": {
+                            "$ref": "#/definitions/handler.BatchCreateResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный JSON, пустой или слишком большой пакет",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "422": {
+                        "description": "atomic=true: пакет отменен из-за ошибок в элементах",
+                        "schema": {
+                            "$ref": "#/definitions/handler.BatchCreateResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервиса или БД",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/export": {
+            "get": {
+                "description": "Потоковая выгрузка всех подписок по тем же фильтрам и сортировке, что и GET /subscriptions (limit и cursor не используются).\nCSV содержит строку заголовка, даты записаны так же, как заданы (MM-YYYY или YYYY-MM-DD), поэтому файл принимает POST /subscriptions/import.\nbom=true добавляет UTF-8 BOM, чтобы Excel правильно открыл кириллицу. NDJSON — по одному JSON-объекту подписки на строку.",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Выгрузка подписок в CSV или NDJSON",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "ndjson"
+                        ],
+                        "type": "string",
+                        "default": "csv",
+                        "description": "Формат выгрузки",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Добавить UTF-8 BOM в начало CSV",
+                        "name": "bom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтр по UUID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтр по названию подписки",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Минимальная цена (включительно)",
+                        "name": "price_min",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Максимальная цена (включительно)",
+                        "name": "price_max",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Подписка активна в месяце (MM-YYYY)",
+                        "name": "active_at",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "true — только бессрочные, false — только с end_date",
+                        "name": "open_ended",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "id",
+                            "user_id",
+                            "service_name",
+                            "price",
+                            "start_date",
+                            "end_date",
+                            "created_at"
+                        ],
+                        "type": "string",
+                        "default": "created_at",
+                        "description": "Столбец сортировки",
+                        "name": "sort_by",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Направление сортировки (по умолчанию desc для created_at, иначе asc)",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Включать мягко удаленные подписки",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Поток строк выгрузки",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации параметров запроса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка БД/сервиса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/import": {
+            "post": {
+                "description": "Принимает CSV (text/csv) со строкой заголовка. Обязательные столбцы: service_name, price, user_id, start_date;\nнеобязательные: end_date, currency, billing_period. Порядок столбцов произвольный.\nФайл читается потоково, каждая строка проверяется так же, как в POST /subscriptions; корректные строки сохраняются\nпакетами, ошибочные пропускаются и попадают в отчет с номером строки. С dry_run=true строки только проверяются.",
+                "consumes": [
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Импорт подписок из CSV",
+                "parameters": [
+                    {
+                        "description": "CSV-файл с заголовком",
+                        "name": "file",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Только проверить файл, ничего не сохраняя",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Автор изменения для истории",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ImportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный заголовок CSV или параметр dry_run",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "415": {
+                        "description": "Тело не в формате text/csv",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервиса или БД",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/overlaps": {
+            "get": {
+                "description": "Пары действующих подписок одного пользователя на один сервис с пересекающимися периодами, из-за которых аналитика\nучитывает сервис дважды. В паре первой идет подписка, начавшаяся раньше; overlap_start и overlap_end — общий период\n(overlap_end нет, если обе подписки бессрочные). Пары упорядочены по user_id, service_name и overlap_start.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Отчет о пересекающихся подписках",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Фильтр по UUID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтр по названию подписки",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Максимальное число пар (1-1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.SubscriptionOverlap"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации параметров запроса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка БД/сервиса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Получить подписку по ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия подписки для If-Match"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный формат ID",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Обновляет существующую запись об онлайн-подписке, используя переданные поля.\nprice, currency и billing_period действуют с месяца начала, поэтому у подписки, начавшейся до текущего месяца,\nих изменение отклоняется: цена меняется (или исправляется с месяца начала) через POST /subscriptions/{id}/price-changes\n(price_change_required), валюта и период списания не меняются (started_terms_locked).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Обновить существующую подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Обновленные данные подписки",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.UpdateSubscriptionRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag, полученный при чтении подписки",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Автор изменения для истории",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный запрос, формат ID, ошибка валидации или изменение цены начавшейся подписки",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "409": {
+                        "description": "Новый период пересекается с другой подпиской на тот же сервис (subscription_overlap)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "412": {
+                        "description": "Подписка изменена с момента чтения (version_mismatch)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервиса или БД",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Мягкое удаление: подписка скрывается из выдачи и аналитики, но её можно восстановить\nчерез POST /subscriptions/{id}/restore до окончательной очистки.",
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Удалить подписку по ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag, полученный при чтении подписки",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Автор изменения для истории",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Подписка успешно удалена (No Content)"
+                    },
+                    "400": {
+                        "description": "Некорректный формат ID",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "412": {
+                        "description": "Подписка изменена с момента чтения (version_mismatch)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/cancel": {
+            "post": {
+                "description": "Подписка действует до конца месяца effective_month (по умолчанию текущего): он записывается в end_date.\nПовторная отмена с тем же месяцем ничего не меняет. Тело запроса можно не передавать.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Отменить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Последний месяц действия подписки (MM-YYYY)",
+                        "name": "cancel",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/model.CancelRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag, полученный при чтении подписки",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Автор изменения для истории",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный запрос, формат ID или месяц раньше начала подписки",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "409": {
+                        "description": "Подписка уже заканчивается раньше effective_month (invalid_status_transition)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "412": {
+                        "description": "Подписка изменена с момента чтения (version_mismatch)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервиса или БД",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/charges": {
+            "get": {
+                "description": "Ближайшие даты списаний начиная с сегодняшнего дня: от start_date с шагом периода списания (billing_period)\nпо end_date (для дат MM-YYYY — до конца месяца). Сумма — цена, действующая в месяце списания, с учетом изменений цены.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Предстоящие списания по подписке",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 12,
+                        "description": "Максимальное число списаний (1-100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Charge"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный формат ID или limit",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервиса или БД",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/history": {
+            "get": {
+                "description": "События created, updated, deleted, restored, price_changed, paused и resumed в порядке возникновения: состояние до и после,\nавтор (заголовок X-Actor запроса, изменившего подписку) и время. Доступна и для удаленных подписок.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "История изменений подписки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.SubscriptionEvent"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный формат ID",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервиса или БД",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/pause": {
+            "post": {
+                "description": "Приостановка действует с месяца from (по умолчанию текущего) до возобновления.\nМесяцы приостановки не учитываются в аналитике расходов и предстоящих списаниях.\nМесяц раньше текущего меняет стоимость прошедших месяцев и требует retroactive: true.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Приостановить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Первый месяц приостановки (MM-YYYY)",
+                        "name": "pause",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/model.PauseRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag, полученный при чтении подписки",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Автор изменения для истории",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный запрос, формат ID, месяц вне периода подписки или прошедший месяц без retroactive (retroactive_not_confirmed)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "409": {
+                        "description": "Подписка уже приостановлена или заканчивается раньше from (invalid_status_transition)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "412": {
+                        "description": "Подписка изменена с момента чтения (version_mismatch)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервиса или БД",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/pauses": {
+            "get": {
+                "description": "Интервалы приостановки в порядке paused_from; resumed_from отсутствует у текущей приостановки.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Приостановки подписки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Pause"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный формат ID",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервиса или БД",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/price-changes": {
+            "get": {
+                "description": "Исходная цена с месяца начала подписки и последующие изменения в порядке effective_from.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "График цен подписки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.PriceChange"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный формат ID",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервиса или БД",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Новая цена действует с первого числа месяца effective_from и учитывается аналитикой только для месяцев\nначиная с него; прошлые месяцы сохраняют прежнюю цену. Повторное изменение с того же месяца заменяет предыдущее.\neffective_from, равный месяцу начала подписки, исправляет исходную цену.\nВозвращает итоговый график цен подписки.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Изменить цену подписки с указанного месяца",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новая цена и месяц начала её действия (MM-YYYY)",
+                        "name": "change",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.PriceChangeRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Автор изменения для истории",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.PriceChange"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный запрос или ошибка валидации",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервиса или БД",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/restore": {
+            "post": {
+                "description": "Отменяет мягкое удаление. Для неудаленной подписки возвращает её без изменений.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Восстановить удаленную подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Автор изменения для истории",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный формат ID",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена или уже окончательно удалена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "409": {
+                        "description": "После удаления создана пересекающаяся подписка на тот же сервис (subscription_overlap, политика reject)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервиса или БД",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/resume": {
+            "post": {
+                "description": "Завершает текущую приостановку: подписка снова действует с месяца from (по умолчанию текущего).\nМесяц раньше текущего меняет стоимость прошедших месяцев и требует retroactive: true.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Возобновить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Месяц возобновления (MM-YYYY)",
+                        "name": "resume",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/model.PauseRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag, полученный при чтении подписки",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Автор изменения для истории",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный запрос, формат ID, месяц не позже начала приостановки или прошедший месяц без retroactive (retroactive_not_confirmed)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "409": {
+                        "description": "Подписка не приостановлена (invalid_status_transition)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "412": {
+                        "description": "Подписка изменена с момента чтения (version_mismatch)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервиса или БД",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "handler.BatchCreateResponse": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.BatchItemResult"
+                    }
+                }
+            }
+        },
+        "handler.BatchItemResult": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.FieldProblem"
+                    }
+                },
+                "index": {
+                    "description": "позиция элемента в массиве запроса",
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "created",
+                        "invalid",
+                        "failed",
+                        "skipped"
+                    ]
+                },
+                "subscription": {
+                    "$ref": "#/definitions/model.Subscription"
+                }
+            }
+        },
+        "handler.CostAnalyticsResponse": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "total_cost": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.FieldProblem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "invalid_month_year"
+                },
+                "detail": {
+                    "type": "string",
+                    "example": "incorrect format start_date (expected MM-YYYY)"
+                },
+                "field": {
+                    "type": "string",
+                    "example": "start_date"
+                }
+            }
+        },
+        "handler.ImportLineProblem": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.FieldProblem"
+                    }
+                },
+                "line": {
+                    "description": "номер строки файла, заголовок — строка 1",
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "handler.ImportResponse": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "errors": {
+                    "description": "ошибки строк в порядке файла, не более 1000",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.ImportLineProblem"
+                    }
+                },
+                "errors_truncated": {
+                    "description": "в errors попали не все ошибочные строки",
+                    "type": "boolean"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "description": "строк данных без заголовка",
+                    "type": "integer"
+                },
+                "valid": {
+                    "description": "строк, прошедших проверку",
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.ProblemDetails": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "invalid_uuid"
+                },
+                "detail": {
+                    "type": "string",
+                    "example": "incorrect format user_id (expected UUID)"
+                },
+                "errors": {
+                    "description": "все ошибки валидации запроса по полям",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.FieldProblem"
+                    }
+                },
+                "field": {
+                    "type": "string",
+                    "example": "user_id"
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/subscriptions"
+                },
+                "request_id": {
+                    "type": "string",
+                    "example": "6f1c1d0e-8a4b-4f0e-9a51-0c6f0b8e2d3a"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 400
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Bad Request"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "about:blank"
+                }
+            }
+        },
+        "model.CancelRequest": {
+            "type": "object",
+            "properties": {
+                "effective_month": {
+                    "description": "последний месяц действия подписки (MM-YYYY); по умолчанию текущий",
+                    "type": "string",
+                    "example": "12-2025"
+                }
+            }
+        },
+        "model.Charge": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "date": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.CostBucket": {
+            "type": "object",
+            "properties": {
+                "active_count": {
+                    "type": "integer"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "ended_count": {
+                    "type": "integer"
+                },
+                "month": {
+                    "type": "string",
+                    "example": "07-2025"
+                },
+                "new_count": {
+                    "type": "integer"
+                },
+                "total_cost": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.CreateSubscriptionRequest": {
+            "type": "object",
+            "properties": {
+                "billing_period": {
+                    "description": "weekly, monthly (по умолчанию), quarterly или yearly",
+                    "type": "string",
+                    "example": "yearly"
+                },
+                "currency": {
+                    "description": "код валюты ISO 4217; по умолчанию базовая валюта сервиса",
+                    "type": "string",
+                    "example": "USD"
+                },
+                "end_date": {
+                    "type": "string",
+                    "example": "2026-06-15"
+                },
+                "price": {
+                    "description": "можно не указывать, если у сервиса в каталоге есть цена по умолчанию",
+                    "type": "integer"
+                },
+                "service_name": {
+                    "description": "название или синоним из каталога сервисов; найденное в каталоге сохраняется под каноническим названием",
+                    "type": "string"
+                },
+                "start_date": {
+                    "description": "MM-YYYY или YYYY-MM-DD",
+                    "type": "string",
+                    "example": "07-2025"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.Pause": {
+            "type": "object",
+            "properties": {
+                "paused_from": {
+                    "type": "string"
+                },
+                "resumed_from": {
+                    "description": "первый месяц после приостановки; nil — подписка приостановлена до возобновления",
+                    "type": "string"
+                }
+            }
+        },
+        "model.PauseRequest": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "description": "месяц (MM-YYYY), с которого подписка приостанавливается или возобновляется; по умолчанию текущий",
+                    "type": "string",
+                    "example": "09-2025"
+                },
+                "retroactive": {
+                    "description": "подтверждение месяца from раньше текущего: меняет стоимость прошедших месяцев в аналитике",
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
+        "model.PriceChange": {
+            "type": "object",
+            "properties": {
+                "effective_from": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.PriceChangeRequest": {
+            "type": "object",
+            "properties": {
+                "effective_from": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.Service": {
+            "type": "object",
+            "properties": {
+                "aliases": {
+                    "description": "другие названия того же сервиса; сравниваются без учета регистра и лишних пробелов",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "Яндекс Плюс",
+                        "Яндекс.Плюс"
+                    ]
+                },
+                "category": {
+                    "type": "string",
+                    "example": "music"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "currency": {
+                    "description": "валюта цены по умолчанию (ISO 4217)",
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "default_price": {
+                    "description": "цена, которая подставляется при создании подписки без price",
+                    "type": "integer",
+                    "example": 399
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "description": "каноническое название: под ним сохраняются подписки на сервис",
+                    "type": "string",
+                    "example": "Yandex Plus"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ServiceRequest": {
+            "type": "object",
+            "properties": {
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "Яндекс Плюс",
+                        "Яндекс.Плюс"
+                    ]
+                },
+                "category": {
+                    "type": "string",
+                    "example": "music"
+                },
+                "currency": {
+                    "description": "валюта цены по умолчанию; если не указана — базовая",
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "default_price": {
+                    "type": "integer",
+                    "example": 399
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Yandex Plus"
+                }
+            }
+        },
+        "model.Subscription": {
+            "type": "object",
+            "properties": {
+                "billing_period": {
+                    "description": "период, за который списывается Price",
+                    "type": "string",
+                    "enum": [
+                        "weekly",
+                        "monthly",
+                        "quarterly",
+                        "yearly"
+                    ],
+                    "example": "monthly"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "currency": {
+                    "description": "код валюты цены (ISO 4217)",
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "date_precision": {
+                    "description": "month или day: с какой точностью заданы start_date и end_date",
+                    "type": "string",
+                    "enum": [
+                        "month",
+                        "day"
+                    ],
+                    "example": "month"
+                },
+                "deleted_at": {
+                    "description": "момент мягкого удаления; nil у действующей подписки",
+                    "type": "string"
+                },
+                "end_date": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "overlap_allowed": {
+                    "description": "пересечение с другими подписками пользователя на тот же сервис допущено (политика warn или allow)",
+                    "type": "boolean"
+                },
+                "overlaps": {
+                    "description": "ID пересекающихся подписок, найденных при создании или изменении (политика warn); не хранится",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "price": {
                     "type": "integer"
                 },
+                "service_id": {
+                    "description": "запись каталога сервисов, с которой сопоставлено service_name; отсутствует, если название не найдено в каталоге",
+                    "type": "string"
+                },
                 "service_name": {
                     "type": "string"
                 },
                 "start_date": {
                     "type": "string"
                 },
+                "status": {
+                    "description": "статус на текущую дату по периоду и приостановкам; не хранится",
+                    "type": "string",
+                    "enum": [
+                        "active",
+                        "paused",
+                        "ended",
+                        "scheduled"
+                    ],
+                    "example": "active"
+                },
+                "user_id": {
+                    "type": "string"
+                },
+                "version": {
+                    "description": "увеличивается при каждом изменении; используется как ETag",
+                    "type": "integer"
+                }
+            }
+        },
+        "model.SubscriptionEvent": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "type": "string"
+                },
+                "after": {
+                    "type": "object"
+                },
+                "before": {
+                    "type": "object"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "subscription_id": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.SubscriptionOverlap": {
+            "type": "object",
+            "properties": {
+                "overlap_end": {
+                    "type": "string"
+                },
+                "overlap_start": {
+                    "description": "первый и последний день пересечения; overlap_end отсутствует, если обе подписки бессрочные",
+                    "type": "string"
+                },
+                "overlapping_id": {
+                    "description": "подписка, пересекающаяся с ней",
+                    "type": "string"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "subscription_id": {
+                    "description": "подписка, начавшаяся раньше",
+                    "type": "string"
+                },
                 "user_id": {
                     "type": "string"
                 }
             }
         },
+        "model.SubscriptionPage": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Subscription"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
         "model.UpdateSubscriptionRequest": {
             "type": "object",
             "properties": {
+                "billing_period": {
+                    "type": "string"
+                },
+                "currency": {
+                    "description": "валюта и период списания начавшейся до текущего месяца подписки не меняются",
+                    "type": "string"
+                },
                 "end_date": {
-                    "description": "\"MM-YYYY\" (Опционально)",
                     "type": "string"
                 },
                 "price": {
+                    "description": "исходная цена; у подписки, начавшейся до текущего месяца, меняется только через POST /subscriptions/{id}/price-changes",
                     "type": "integer"
                 },
                 "service_name": {
                     "type": "string"
                 },
                 "start_date": {
-                    "description": "\"MM-YYYY\"",
                     "type": "string"
                 }
             }
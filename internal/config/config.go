@@ -1,41 +1,277 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"effective-mobile-subscriptions/internal/model"
+	"effective-mobile-subscriptions/internal/rates"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
 )
 
+// путь к yaml-файлу конфигурации по умолчанию
+const DefaultConfigPath = "./internal/config/config.yaml"
+
+// префикс переменных окружения: SUBS_DATABASE_HOST переопределяет database.host
+const EnvPrefix = "SUBS"
+
+// значение, которым заменяются секреты при выводе конфигурации
+const redacted = "******"
+
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
+	Server   ServerConfig   `mapstructure:"server" yaml:"server"`
+	Storage  StorageConfig  `mapstructure:"storage" yaml:"storage"`
+	Database DatabaseConfig `mapstructure:"database" yaml:"database"`
+	Purge    PurgeConfig    `mapstructure:"purge" yaml:"purge"`
+	Rates    RatesConfig    `mapstructure:"rates" yaml:"rates"`
+	// ключи идемпотентности POST /subscriptions
+	Idempotency IdempotencyConfig `mapstructure:"idempotency" yaml:"idempotency"`
+	// пересечения подписок одного пользователя на один сервис
+	Overlap OverlapConfig `mapstructure:"overlap" yaml:"overlap"`
 }
 
+// драйверы хранилища подписок
+const (
+	StorageDriverPostgres = "postgres"
+	StorageDriverMemory   = "memory"
+)
+
 // настройки HTTP-сервера
 type ServerConfig struct {
-	Port string `mapstructure:"port"`
+	Port string `mapstructure:"port" yaml:"port"`
+}
+
+// выбор хранилища: postgres или memory (данные живут только в памяти процесса)
+type StorageConfig struct {
+	Driver string `mapstructure:"driver" yaml:"driver"`
 }
 
 // настройки подключения к PostgreSQL
 type DatabaseConfig struct {
-	Host     string `mapstructure:"host"`
-	Port     string `mapstructure:"port"`
-	User     string `mapstructure:"user"`
-	Password string `mapstructure:"password"`
-	DBName   string `mapstructure:"dbname"`
-	SSLMode  string `mapstructure:"sslmode"`
-}
-
-// загружает конфигурацию из файла yaml
-func LoadConfig(path string) (*Config, error) {
-	viper.AddConfigPath(path)
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	if err := viper.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("error reading configuration file: %w", err)
+	Host     string `mapstructure:"host" yaml:"host"`
+	Port     string `mapstructure:"port" yaml:"port"`
+	User     string `mapstructure:"user" yaml:"user"`
+	Password string `mapstructure:"password" yaml:"password"`
+	DBName   string `mapstructure:"dbname" yaml:"dbname"`
+	SSLMode  string `mapstructure:"sslmode" yaml:"sslmode"`
+	// применять встроенные миграции при старте сервера
+	AutoMigrate bool `mapstructure:"auto_migrate" yaml:"auto_migrate"`
+}
+
+// фоновая очистка мягко удаленных подписок
+type PurgeConfig struct {
+	// сколько хранить удаленные подписки; 0 отключает очистку
+	Retention time.Duration `mapstructure:"retention" yaml:"retention"`
+	// как часто запускать очистку
+	Interval time.Duration `mapstructure:"interval" yaml:"interval"`
+}
+
+// хранение ключей идемпотентности
+type IdempotencyConfig struct {
+	// сколько ключ защищает от повторного создания
+	TTL time.Duration `mapstructure:"ttl" yaml:"ttl"`
+	// как часто удалять истекшие ключи
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval" yaml:"cleanup_interval"`
+}
+
+// проверка пересечений подписок
+type OverlapConfig struct {
+	// reject — отклонять пересекающуюся подписку, warn — создавать с предупреждением, allow — не проверять
+	Policy string `mapstructure:"policy" yaml:"policy"`
+}
+
+// источники курсов валют
+const (
+	RatesSourceFile  = "file"
+	RatesSourceTable = "table"
+)
+
+// курсы валют для пересчета аналитики
+type RatesConfig struct {
+	// file (yaml-файл File) или table (таблица exchange_rates, только для postgres)
+	Source string `mapstructure:"source" yaml:"source"`
+	File   string `mapstructure:"file" yaml:"file"`
+	// базовая валюта: к ней заданы курсы, она же валюта подписок и аналитики по умолчанию
+	Base string `mapstructure:"base" yaml:"base"`
+}
+
+// значения по умолчанию (нижний слой конфигурации)
+var defaults = map[string]interface{}{
+	"server.port":                  "8080",
+	"storage.driver":               StorageDriverPostgres,
+	"database.host":                "localhost",
+	"database.port":                "5432",
+	"database.user":                "",
+	"database.password":            "",
+	"database.dbname":              "subscription_service",
+	"database.sslmode":             "disable",
+	"database.auto_migrate":        false,
+	"purge.retention":              "720h",
+	"purge.interval":               "1h",
+	"rates.source":                 RatesSourceFile,
+	"rates.file":                   "./internal/config/rates.yaml",
+	"rates.base":                   "RUB",
+	"idempotency.ttl":              "24h",
+	"idempotency.cleanup_interval": "1h",
+	"overlap.policy":               model.OverlapPolicyReject,
+}
+
+// флаги командной строки и ключи конфигурации, которые они переопределяют
+var flagKeys = map[string]string{
+	"port":            "server.port",
+	"storage":         "storage.driver",
+	"db-host":         "database.host",
+	"db-port":         "database.port",
+	"db-user":         "database.user",
+	"db-password":     "database.password",
+	"db-name":         "database.dbname",
+	"db-sslmode":      "database.sslmode",
+	"auto-migrate":    "database.auto_migrate",
+	"purge-retention": "purge.retention",
+	"purge-interval":  "purge.interval",
+}
+
+// загружает конфигурацию слоями: значения по умолчанию, yaml-файл, переменные окружения SUBS_*,
+// флаги командной строки. Возвращает конфигурацию и оставшиеся позиционные аргументы (подкоманду)
+func Load(args []string) (*Config, []string, error) {
+	flags := pflag.NewFlagSet("subscriptions", pflag.ContinueOnError)
+	configPath := flags.String("config", DefaultConfigPath, "path to yaml configuration file")
+	flags.String("port", "", "HTTP server port")
+	flags.String("storage", "", "storage driver (postgres or memory)")
+	flags.String("db-host", "", "PostgreSQL host")
+	flags.String("db-port", "", "PostgreSQL port")
+	flags.String("db-user", "", "PostgreSQL user")
+	flags.String("db-password", "", "PostgreSQL password")
+	flags.String("db-name", "", "PostgreSQL database name")
+	flags.String("db-sslmode", "", "PostgreSQL sslmode")
+	flags.Bool("auto-migrate", false, "apply embedded migrations on server start")
+	flags.Duration("purge-retention", 0, "how long soft-deleted subscriptions are kept (0 disables purge)")
+	flags.Duration("purge-interval", 0, "how often soft-deleted subscriptions are purged")
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse command line flags: %w", err)
 	}
+
+	v := viper.New()
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+	v.SetConfigFile(*configPath)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		// файл по умолчанию необязателен: конфигурация может целиком прийти из окружения
+		var pathErr *os.PathError
+		if flags.Changed("config") || !errors.As(err, &pathErr) {
+			return nil, nil, fmt.Errorf("error reading configuration file: %w", err)
+		}
+	}
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	for name, key := range flagKeys {
+		if err := v.BindPFlag(key, flags.Lookup(name)); err != nil {
+			return nil, nil, fmt.Errorf("failed to bind flag --%s: %w", name, err)
+		}
+	}
+
 	cfg := &Config{}
-	if err := viper.Unmarshal(cfg); err != nil {
-		return nil, fmt.Errorf("failed to deserialize config: %w", err)
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, nil, fmt.Errorf("failed to deserialize config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, flags.Args(), nil
+}
+
+// проверяет обязательные поля и допустимые значения; возвращает все найденные проблемы сразу
+func (c *Config) Validate() error {
+	var problems []string
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port <= 0 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("server.port must be a valid TCP port, got %q", c.Server.Port))
+	}
+	switch c.Storage.Driver {
+	case StorageDriverPostgres:
+		required := map[string]string{
+			"database.host":   c.Database.Host,
+			"database.port":   c.Database.Port,
+			"database.user":   c.Database.User,
+			"database.dbname": c.Database.DBName,
+		}
+		for _, key := range []string{"database.host", "database.port", "database.user", "database.dbname"} {
+			if strings.TrimSpace(required[key]) == "" {
+				problems = append(problems, key+" is required")
+			}
+		}
+		switch c.Database.SSLMode {
+		case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		default:
+			problems = append(problems, fmt.Sprintf("database.sslmode %q is not supported", c.Database.SSLMode))
+		}
+	case StorageDriverMemory:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown storage driver %q (expected %s or %s)", c.Storage.Driver, StorageDriverPostgres, StorageDriverMemory))
+	}
+	if !rates.ValidCurrency(c.Rates.Base) {
+		problems = append(problems, fmt.Sprintf("rates.base must be an ISO 4217 currency code, got %q", c.Rates.Base))
+	}
+	switch c.Rates.Source {
+	case RatesSourceFile:
+		if strings.TrimSpace(c.Rates.File) == "" {
+			problems = append(problems, "rates.file is required for rates source file")
+		}
+	case RatesSourceTable:
+		if c.Storage.Driver != StorageDriverPostgres {
+			problems = append(problems, "rates source table requires storage driver postgres")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown rates source %q (expected %s or %s)", c.Rates.Source, RatesSourceFile, RatesSourceTable))
+	}
+	if c.Idempotency.TTL <= 0 {
+		problems = append(problems, "idempotency.ttl must be positive")
+	}
+	if c.Idempotency.CleanupInterval <= 0 {
+		problems = append(problems, "idempotency.cleanup_interval must be positive")
+	}
+	switch c.Overlap.Policy {
+	case model.OverlapPolicyReject, model.OverlapPolicyWarn, model.OverlapPolicyAllow:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown overlap policy %q (expected %s, %s or %s)",
+			c.Overlap.Policy, model.OverlapPolicyReject, model.OverlapPolicyWarn, model.OverlapPolicyAllow))
+	}
+	if c.Purge.Retention < 0 {
+		problems = append(problems, "purge.retention cannot be negative")
+	}
+	if c.Purge.Retention > 0 && c.Purge.Interval <= 0 {
+		problems = append(problems, "purge.interval must be positive when purge is enabled")
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// копия конфигурации со скрытыми секретами
+func (c *Config) Redacted() *Config {
+	copied := *c
+	if copied.Database.Password != "" {
+		copied.Database.Password = redacted
+	}
+	return &copied
+}
+
+// выводит действующую конфигурацию в yaml, секреты скрыты
+func Print(w io.Writer, cfg *Config) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg.Redacted()); err != nil {
+		return fmt.Errorf("failed to encode configuration: %w", err)
 	}
-	return cfg, nil
+	return encoder.Close()
 }
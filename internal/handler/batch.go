@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"effective-mobile-subscriptions/internal/model"
+	"effective-mobile-subscriptions/internal/service"
+)
+
+// статусы элементов пакетного создания
+const (
+	BatchItemCreated = "created"
+	BatchItemInvalid = "invalid"
+	BatchItemFailed  = "failed"
+	BatchItemSkipped = "skipped"
+)
+
+// результат создания одного элемента пакета
+type BatchItemResult struct {
+	// позиция элемента в массиве запроса
+	Index        int                 `json:"index"`
+	Status       string              `json:"status" enums:"created,invalid,failed,skipped"`
+	Subscription *model.Subscription `json:"subscription,omitempty"`
+	Errors       []FieldProblem      `json:"errors,omitempty"`
+}
+
+// ответ пакетного создания
+type BatchCreateResponse struct {
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Items   []BatchItemResult `json:"items"`
+}
+
+// @Summary Создать несколько подписок
+// @Description Каждый элемент массива проверяется так же, как в POST /subscriptions; корректные элементы сохраняются одним запросом.
+// @Description В ответе по каждому элементу: created, invalid (ошибки валидации или пересечение с подпиской на тот же сервис), failed (ошибка сохранения)
+// @Description или skipped (atomic=true и в пакете есть ошибки — не создано ничего). Не более 1000 элементов.
+// @Description Статус 201 — созданы все, 207 — созданы не все, 422 — atomic-пакет отменен.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param subscriptions body []model.CreateSubscriptionRequest true "Данные новых подписок"
+// @Param atomic query bool false "Создать все подписки или ни одной" default(false)
+// @Param X-Actor header string false "Автор изменения для истории"
+// @Success 201 {object} BatchCreateResponse
+// @Success 207 {object} BatchCreateResponse
+// @Failure 400 {object} ProblemDetails "Некорректный JSON, пустой или слишком большой пакет"
+// @Failure 422 {object} BatchCreateResponse "atomic=true: пакет отменен из-за ошибок в элементах"
+// @Failure 500 {object} ProblemDetails "Ошибка сервиса или БД"
+// @Router /subscriptions/batch [post]
+func (h *SubscriptionHandler) CreateSubscriptionsBatch(w http.ResponseWriter, r *http.Request) {
+	atomic := false
+	if value := r.URL.Query().Get("atomic"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			RespondProblem(w, r, http.StatusBadRequest, service.CodeInvalidParameter, "atomic", "atomic must be true or false")
+			return
+		}
+		atomic = parsed
+	}
+	var reqs []model.CreateSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+		log.Printf("ERROR: Invalid batch request payload: %v", err)
+		RespondProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "", "Invalid request payload or malformed JSON (expected array)")
+		return
+	}
+	results, err := h.Service.CreateBatch(r.Context(), reqs, atomic)
+	if err != nil {
+		log.Printf("ERROR: Service failed to create subscriptions batch: %v", err)
+		RespondServiceError(w, r, err)
+		return
+	}
+	response := BatchCreateResponse{Items: make([]BatchItemResult, 0, len(results))}
+	for i, result := range results {
+		item := BatchItemResult{Index: i, Subscription: result.Subscription}
+		switch {
+		case result.Subscription != nil:
+			item.Status = BatchItemCreated
+			response.Created++
+		case result.Skipped:
+			item.Status = BatchItemSkipped
+		case errors.Is(result.Err, service.ErrValidation), errors.Is(result.Err, service.ErrConflict):
+			item.Status = BatchItemInvalid
+			item.Errors = fieldProblems(result.Err)
+			response.Failed++
+		default:
+			item.Status = BatchItemFailed
+			item.Errors = fieldProblems(result.Err)
+			response.Failed++
+		}
+		response.Items = append(response.Items, item)
+	}
+	status := http.StatusCreated
+	switch {
+	case atomic && response.Created < len(results):
+		status = http.StatusUnprocessableEntity
+	case response.Created < len(results):
+		status = http.StatusMultiStatus
+	}
+	RespondJSON(w, status, response)
+}
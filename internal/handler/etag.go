@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ETag подписки по её версии
+func formatETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ожидаемая версия из заголовка If-Match: nil, если заголовка нет или он равен "*".
+// Поддерживается одно сильное значение "N". If-Match требует сильного сравнения (RFC 9110, 13.1.1),
+// поэтому слабый W/"N", как и любое нераспознанное значение, не совпадет ни с одной версией
+func parseIfMatch(r *http.Request) *int {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return nil
+	}
+	version := -1
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		if parsed, err := strconv.Atoi(value[1 : len(value)-1]); err == nil && parsed >= 0 {
+			version = parsed
+		}
+	}
+	return &version
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		header string
+		want   *int
+	}{
+		{header: "", want: nil},
+		{header: "*", want: nil},
+		{header: `"3"`, want: intPtr(3)},
+		{header: ` "3" `, want: intPtr(3)},
+		// слабый ETag не проходит сильное сравнение
+		{header: `W/"3"`, want: intPtr(-1)},
+		{header: `3`, want: intPtr(-1)},
+		{header: `""3""`, want: intPtr(-1)},
+		{header: `"`, want: intPtr(-1)},
+		{header: `"-1"`, want: intPtr(-1)},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("PUT", "/subscriptions/1", nil)
+		if tt.header != "" {
+			r.Header.Set("If-Match", tt.header)
+		}
+		got := parseIfMatch(r)
+		switch {
+		case tt.want == nil && got != nil:
+			t.Errorf("parseIfMatch(%q) = %d, want nil", tt.header, *got)
+		case tt.want != nil && (got == nil || *got != *tt.want):
+			t.Errorf("parseIfMatch(%q) = %v, want %d", tt.header, got, *tt.want)
+		}
+	}
+}
+
+func intPtr(value int) *int {
+	return &value
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"effective-mobile-subscriptions/internal/model"
+	"effective-mobile-subscriptions/internal/service"
+)
+
+// форматы выгрузки подписок
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+)
+
+// через сколько строк выгрузка сбрасывается клиенту
+const exportFlushEvery = 100
+
+// срок записи очередной порции выгрузки: WriteTimeout сервера отсчитывается от начала запроса
+// и оборвал бы длинную выгрузку, поэтому срок продлевается при каждом сбросе
+const exportChunkTimeout = 15 * time.Second
+
+// столбцы CSV-выгрузки; даты в формате, которым они были заданы, чтобы файл можно было снова импортировать
+var exportCSVHeader = []string{
+	"id", "service_name", "price", "currency", "billing_period", "user_id",
+	"start_date", "end_date", "date_precision", "created_at", "version", "deleted_at",
+}
+
+// @Summary Выгрузка подписок в CSV или NDJSON
+// @Description Потоковая выгрузка всех подписок по тем же фильтрам и сортировке, что и GET /subscriptions (limit и cursor не используются).
+// @Description CSV содержит строку заголовка, даты записаны так же, как заданы (MM-YYYY или YYYY-MM-DD), поэтому файл принимает POST /subscriptions/import.
+// @Description bom=true добавляет UTF-8 BOM, чтобы Excel правильно открыл кириллицу. NDJSON — по одному JSON-объекту подписки на строку.
+// @Tags subscriptions
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param format query string false "Формат выгрузки" Enums(csv, ndjson) default(csv)
+// @Param bom query bool false "Добавить UTF-8 BOM в начало CSV" default(false)
+// @Param user_id query string false "Фильтр по UUID пользователя"
+// @Param service_name query string false "Фильтр по названию подписки"
+// @Param price_min query int false "Минимальная цена (включительно)"
+// @Param price_max query int false "Максимальная цена (включительно)"
+// @Param active_at query string false "Подписка активна в месяце (MM-YYYY)"
+// @Param open_ended query bool false "true — только бессрочные, false — только с end_date"
+// @Param sort_by query string false "Столбец сортировки" Enums(id, user_id, service_name, price, start_date, end_date, created_at) default(created_at)
+// @Param order query string false "Направление сортировки (по умолчанию desc для created_at, иначе asc)" Enums(asc, desc)
+// @Param include_deleted query bool false "Включать мягко удаленные подписки" default(false)
+// @Success 200 {string} string "Поток строк выгрузки"
+// @Failure 400 {object} ProblemDetails "Ошибка валидации параметров запроса"
+// @Failure 500 {object} ProblemDetails "Ошибка БД/сервиса"
+// @Router /subscriptions/export [get]
+func (h *SubscriptionHandler) ExportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = ExportFormatCSV
+	}
+	if format != ExportFormatCSV && format != ExportFormatNDJSON {
+		RespondProblem(w, r, http.StatusBadRequest, service.CodeInvalidParameter, "format", "format must be csv or ndjson")
+		return
+	}
+	bom := false
+	if value := query.Get("bom"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			RespondProblem(w, r, http.StatusBadRequest, service.CodeInvalidParameter, "bom", "bom must be true or false")
+			return
+		}
+		bom = parsed
+	}
+	export := &exportWriter{w: w, rc: http.NewResponseController(w), format: format, bom: bom}
+	export.extendDeadline()
+	err := h.Service.Export(r.Context(), listRequestFromQuery(r), export.write)
+	if err != nil {
+		if !export.started {
+			log.Printf("ERROR: Service failed to export subscriptions: %v", err)
+			RespondServiceError(w, r, err)
+			return
+		}
+		// статус уже отправлен: обрываем соединение, чтобы клиент не принял неполную выгрузку за целую
+		log.Printf("ERROR: Subscription export interrupted after %d rows: %v", export.rows, err)
+		panic(http.ErrAbortHandler)
+	}
+	if err := export.finish(); err != nil {
+		log.Printf("ERROR: Failed to finish subscription export: %v", err)
+	}
+}
+
+// пишет выгрузку в ответ; заголовки ответа отправляются с первой строкой,
+// чтобы ошибку до начала выгрузки можно было вернуть как problem+json
+type exportWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	format  string
+	bom     bool
+	started bool
+	rows    int
+	csv     *csv.Writer
+	json    *json.Encoder
+}
+
+// отправить заголовки ответа и, для CSV, строку заголовка
+func (e *exportWriter) start() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	if e.format == ExportFormatNDJSON {
+		e.w.Header().Set("Content-Type", "application/x-ndjson")
+		e.w.Header().Set("Content-Disposition", `attachment; filename="subscriptions.ndjson"`)
+		e.w.WriteHeader(http.StatusOK)
+		e.json = json.NewEncoder(e.w)
+		return nil
+	}
+	e.w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	e.w.Header().Set("Content-Disposition", `attachment; filename="subscriptions.csv"`)
+	e.w.WriteHeader(http.StatusOK)
+	if e.bom {
+		if _, err := e.w.Write([]byte("\ufeff")); err != nil {
+			return err
+		}
+	}
+	e.csv = csv.NewWriter(e.w)
+	return e.csv.Write(exportCSVHeader)
+}
+
+// записать одну подписку
+func (e *exportWriter) write(sub *model.Subscription) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	e.rows++
+	if e.format == ExportFormatNDJSON {
+		if err := e.json.Encode(sub); err != nil {
+			return err
+		}
+	} else if err := e.csv.Write(exportCSVRecord(sub)); err != nil {
+		return err
+	}
+	if e.rows%exportFlushEvery == 0 {
+		return e.flush()
+	}
+	return nil
+}
+
+// завершить выгрузку: пустой результат тоже получает заголовки, буфер сбрасывается клиенту
+func (e *exportWriter) finish() error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	return e.flush()
+}
+
+// сбросить буфер CSV и ответа клиенту
+func (e *exportWriter) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if flusher, ok := e.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	e.extendDeadline()
+	return nil
+}
+
+// продлить срок записи ответа на exportChunkTimeout
+func (e *exportWriter) extendDeadline() {
+	if err := e.rc.SetWriteDeadline(time.Now().Add(exportChunkTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("WARN: Failed to extend export write deadline: %v", err)
+	}
+}
+
+// строка CSV-выгрузки в порядке exportCSVHeader
+func exportCSVRecord(sub *model.Subscription) []string {
+	dateLayout := "01-2006"
+	if sub.DatePrecision == model.DatePrecisionDay {
+		dateLayout = "2006-01-02"
+	}
+	endDate, deletedAt := "", ""
+	if sub.EndDate != nil {
+		endDate = sub.EndDate.Format(dateLayout)
+	}
+	if sub.DeletedAt != nil {
+		deletedAt = sub.DeletedAt.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		sub.ID.String(),
+		sub.ServiceName,
+		strconv.Itoa(sub.Price),
+		sub.Currency,
+		sub.BillingPeriod,
+		sub.UserID.String(),
+		sub.StartDate.Format(dateLayout),
+		endDate,
+		sub.DatePrecision,
+		sub.CreatedAt.UTC().Format(time.RFC3339),
+		strconv.Itoa(sub.Version),
+		deletedAt,
+	}
+}
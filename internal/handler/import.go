@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"effective-mobile-subscriptions/internal/service"
+)
+
+// код ошибки для тела в неподдерживаемом формате
+const CodeUnsupportedMediaType = "unsupported_media_type"
+
+// срок чтения очередной порции файла и записи отчета: ReadTimeout и WriteTimeout сервера отсчитываются
+// от начала запроса и оборвали бы импорт большого файла, поэтому сроки продлеваются по ходу импорта
+const importChunkTimeout = 15 * time.Second
+
+// ошибки одной строки CSV-файла
+type ImportLineProblem struct {
+	// номер строки файла, заголовок — строка 1
+	Line   int            `json:"line" example:"2"`
+	Errors []FieldProblem `json:"errors"`
+}
+
+// отчет об импорте
+type ImportResponse struct {
+	DryRun bool `json:"dry_run"`
+	// строк данных без заголовка
+	Rows int `json:"rows"`
+	// строк, прошедших проверку
+	Valid   int `json:"valid"`
+	Created int `json:"created"`
+	Failed  int `json:"failed"`
+	// ошибки строк в порядке файла, не более 1000
+	Errors []ImportLineProblem `json:"errors"`
+	// в errors попали не все ошибочные строки
+	ErrorsTruncated bool `json:"errors_truncated"`
+}
+
+// @Summary Импорт подписок из CSV
+// @Description Принимает CSV (text/csv) со строкой заголовка. Обязательные столбцы: service_name, price, user_id, start_date;
+// @Description необязательные: end_date, currency, billing_period. Порядок столбцов произвольный.
+// @Description Файл читается потоково, каждая строка проверяется так же, как в POST /subscriptions; корректные строки сохраняются
+// @Description пакетами, ошибочные пропускаются и попадают в отчет с номером строки. С dry_run=true строки только проверяются.
+// @Tags subscriptions
+// @Accept text/csv
+// @Produce json
+// @Param file body string true "CSV-файл с заголовком"
+// @Param dry_run query bool false "Только проверить файл, ничего не сохраняя" default(false)
+// @Param X-Actor header string false "Автор изменения для истории"
+// @Success 200 {object} ImportResponse
+// @Failure 400 {object} ProblemDetails "Некорректный заголовок CSV или параметр dry_run"
+// @Failure 415 {object} ProblemDetails "Тело не в формате text/csv"
+// @Failure 500 {object} ProblemDetails "Ошибка сервиса или БД"
+// @Router /subscriptions/import [post]
+func (h *SubscriptionHandler) ImportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "text/csv" {
+		RespondProblem(w, r, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "", "Content-Type must be text/csv")
+		return
+	}
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			RespondProblem(w, r, http.StatusBadRequest, service.CodeInvalidParameter, "dry_run", "dry_run must be true or false")
+			return
+		}
+		dryRun = parsed
+	}
+	// срок записи снимается на время импорта и снова ставится перед отправкой отчета:
+	// продлить уже истекший срок нельзя
+	rc := http.NewResponseController(w)
+	setImportWriteDeadline(rc, time.Time{})
+	report, err := h.Service.ImportCSV(r.Context(), &deadlineReader{r: r.Body, rc: rc}, dryRun)
+	setImportWriteDeadline(rc, time.Now().Add(importChunkTimeout))
+	if err != nil {
+		log.Printf("ERROR: Service failed to import subscriptions: %v", err)
+		RespondServiceError(w, r, err)
+		return
+	}
+	response := ImportResponse{
+		DryRun:          report.DryRun,
+		Rows:            report.Rows,
+		Valid:           report.Valid,
+		Created:         report.Created,
+		Failed:          report.Failed,
+		Errors:          make([]ImportLineProblem, 0, len(report.Errors)),
+		ErrorsTruncated: report.ErrorsTruncated,
+	}
+	for _, lineErr := range report.Errors {
+		response.Errors = append(response.Errors, ImportLineProblem{Line: lineErr.Line, Errors: fieldProblems(lineErr.Err)})
+	}
+	RespondJSON(w, http.StatusOK, response)
+}
+
+// продлевает срок чтения тела запроса на importChunkTimeout перед каждым чтением
+type deadlineReader struct {
+	r  io.Reader
+	rc *http.ResponseController
+}
+
+func (d *deadlineReader) Read(p []byte) (int, error) {
+	if err := d.rc.SetReadDeadline(time.Now().Add(importChunkTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("WARN: Failed to extend import read deadline: %v", err)
+	}
+	return d.r.Read(p)
+}
+
+// установить срок записи ответа импорта
+func setImportWriteDeadline(rc *http.ResponseController, deadline time.Time) {
+	if err := rc.SetWriteDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("WARN: Failed to set import write deadline: %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+	"regexp"
+
+	"effective-mobile-subscriptions/internal/requestctx"
+	"github.com/google/uuid"
+)
+
+// заголовок с идентификатором запроса
+const RequestIDHeader = "X-Request-ID"
+
+// допустимый формат идентификатора, присланного клиентом
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// присваивает запросу идентификатор (из X-Request-ID клиента или новый UUID),
+// возвращает его в ответе и кладет в контекст для логов и ошибок
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(requestctx.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// заголовок с автором изменений для истории подписок
+const ActorHeader = "X-Actor"
+
+// допустимый формат автора: email, логин или имя сервиса
+var actorPattern = regexp.MustCompile(`^[A-Za-z0-9._@:+-]{1,128}$`)
+
+// кладет в контекст автора изменений из X-Actor; некорректное значение игнорируется
+func ActorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := r.Header.Get(ActorHeader)
+		if !actorPattern.MatchString(actor) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(requestctx.WithActor(r.Context(), actor)))
+	})
+}
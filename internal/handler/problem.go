@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"effective-mobile-subscriptions/internal/requestctx"
+	"effective-mobile-subscriptions/internal/service"
+)
+
+// коды ошибок уровня HTTP (остальные коды определены в service)
+const (
+	CodeInvalidJSON   = "invalid_json"
+	CodeInternalError = "internal_error"
+)
+
+// тело ошибки в формате RFC 7807 (application/problem+json)
+type ProblemDetails struct {
+	Type      string `json:"type" example:"about:blank"`
+	Title     string `json:"title" example:"Bad Request"`
+	Status    int    `json:"status" example:"400"`
+	Detail    string `json:"detail,omitempty" example:"incorrect format user_id (expected UUID)"`
+	Instance  string `json:"instance,omitempty" example:"/subscriptions"`
+	Code      string `json:"code" example:"invalid_uuid"`
+	Field     string `json:"field,omitempty" example:"user_id"`
+	RequestID string `json:"request_id,omitempty" example:"6f1c1d0e-8a4b-4f0e-9a51-0c6f0b8e2d3a"`
+	// все ошибки валидации запроса по полям
+	Errors []FieldProblem `json:"errors,omitempty"`
+}
+
+// ошибка валидации отдельного поля
+type FieldProblem struct {
+	Code   string `json:"code" example:"invalid_month_year"`
+	Field  string `json:"field,omitempty" example:"start_date"`
+	Detail string `json:"detail" example:"incorrect format start_date (expected MM-YYYY)"`
+}
+
+// отправляет ошибку в формате application/problem+json
+func RespondProblem(w http.ResponseWriter, r *http.Request, status int, code, field, detail string) {
+	writeProblem(w, r, ProblemDetails{Status: status, Code: code, Field: field, Detail: detail})
+}
+
+// дополняет problem общими полями и отправляет его
+func writeProblem(w http.ResponseWriter, r *http.Request, problem ProblemDetails) {
+	problem.Type = "about:blank"
+	problem.Title = http.StatusText(problem.Status)
+	problem.Instance = r.URL.Path
+	problem.RequestID = requestctx.RequestID(r.Context())
+	response, err := json.Marshal(problem)
+	if err != nil {
+		log.Printf("CRITICAL ERROR: Failed to marshal problem details to JSON: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	w.Write(response)
+}
+
+// переводит ошибку сервиса в HTTP-статус и problem+json
+func RespondServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErrs *service.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		writeProblem(w, r, validationProblem(validationErrs))
+		return
+	}
+	var serviceErr *service.Error
+	if !errors.As(err, &serviceErr) {
+		RespondProblem(w, r, http.StatusInternalServerError, CodeInternalError, "", "Internal Server Error")
+		return
+	}
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(serviceErr, service.ErrValidation):
+		status = http.StatusBadRequest
+	case errors.Is(serviceErr, service.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(serviceErr, service.ErrPreconditionFailed):
+		status = http.StatusPreconditionFailed
+	case errors.Is(serviceErr, service.ErrUnprocessable):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(serviceErr, service.ErrConflict):
+		status = http.StatusConflict
+	}
+	problem := ProblemDetails{Status: status, Code: serviceErr.Code, Field: serviceErr.Field, Detail: serviceErr.Message}
+	if status == http.StatusBadRequest {
+		problem.Errors = []FieldProblem{{Code: serviceErr.Code, Field: serviceErr.Field, Detail: serviceErr.Message}}
+	}
+	writeProblem(w, r, problem)
+}
+
+// problem со списком всех ошибок полей; при единственной ошибке её код и поле дублируются на верхний уровень
+func validationProblem(validationErrs *service.ValidationErrors) ProblemDetails {
+	problem := ProblemDetails{
+		Status: http.StatusBadRequest,
+		Code:   service.CodeValidationFailed,
+		Errors: make([]FieldProblem, 0, len(validationErrs.Errors)),
+	}
+	messages := make([]string, 0, len(validationErrs.Errors))
+	for _, fieldErr := range validationErrs.Errors {
+		problem.Errors = append(problem.Errors, FieldProblem{Code: fieldErr.Code, Field: fieldErr.Field, Detail: fieldErr.Message})
+		messages = append(messages, fieldErr.Message)
+	}
+	problem.Detail = strings.Join(messages, "; ")
+	if len(validationErrs.Errors) == 1 {
+		problem.Code = validationErrs.Errors[0].Code
+		problem.Field = validationErrs.Errors[0].Field
+	}
+	return problem
+}
+
+// ошибки полей для отдельного элемента пакетного запроса; сбои хранилища не раскрываются клиенту
+func fieldProblems(err error) []FieldProblem {
+	var validationErrs *service.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		return validationProblem(validationErrs).Errors
+	}
+	var serviceErr *service.Error
+	if errors.As(err, &serviceErr) {
+		return []FieldProblem{{Code: serviceErr.Code, Field: serviceErr.Field, Detail: serviceErr.Message}}
+	}
+	return []FieldProblem{{Code: CodeInternalError, Detail: "Internal Server Error"}}
+}
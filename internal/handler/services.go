@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"effective-mobile-subscriptions/internal/model"
+	"github.com/gorilla/mux"
+)
+
+// @Summary Добавить сервис в каталог
+// @Description Каноническое название и синонимы сравниваются без учета регистра и лишних пробелов и должны быть уникальны во всем каталоге.
+// @Description Подписки, созданные с любым из этих названий, сохраняются под каноническим. Существующие подписки
+// @Description переводятся на каталог подкомандой map-services.
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param service body model.ServiceRequest true "Название, синонимы, категория и цена по умолчанию"
+// @Success 201 {object} model.Service
+// @Failure 400 {object} ProblemDetails "Некорректный запрос или ошибка валидации"
+// @Failure 409 {object} ProblemDetails "Название или синоним заняты другим сервисом (service_name_taken)"
+// @Failure 500 {object} ProblemDetails "Ошибка сервиса или БД"
+// @Router /services [post]
+func (h *SubscriptionHandler) CreateService(w http.ResponseWriter, r *http.Request) {
+	var req model.ServiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("ERROR: Failed to decode request body for service: %v", err)
+		RespondProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "", "Incorrect format JSON")
+		return
+	}
+	service, err := h.Service.CreateService(r.Context(), req)
+	if err != nil {
+		RespondServiceError(w, r, err)
+		return
+	}
+	RespondJSON(w, http.StatusCreated, service)
+}
+
+// @Summary Каталог сервисов
+// @Description Записи каталога в порядке названия.
+// @Tags services
+// @Produce json
+// @Param category query string false "Фильтр по категории"
+// @Success 200 {array} model.Service
+// @Failure 500 {object} ProblemDetails "Ошибка сервиса или БД"
+// @Router /services [get]
+func (h *SubscriptionHandler) ListServices(w http.ResponseWriter, r *http.Request) {
+	services, err := h.Service.ListServices(r.Context(), r.URL.Query().Get("category"))
+	if err != nil {
+		RespondServiceError(w, r, err)
+		return
+	}
+	RespondJSON(w, http.StatusOK, services)
+}
+
+// @Summary Получить сервис каталога по ID
+// @Tags services
+// @Produce json
+// @Param id path string true "UUID сервиса"
+// @Success 200 {object} model.Service
+// @Failure 400 {object} ProblemDetails "Некорректный формат ID"
+// @Failure 404 {object} ProblemDetails "Сервис не найден"
+// @Router /services/{id} [get]
+func (h *SubscriptionHandler) GetService(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	service, err := h.Service.GetService(r.Context(), vars["id"])
+	if err != nil {
+		RespondServiceError(w, r, err)
+		return
+	}
+	RespondJSON(w, http.StatusOK, service)
+}
+
+// @Summary Заменить сервис каталога
+// @Description Заменяет название, синонимы, категорию и цену по умолчанию. При смене названия подписки,
+// @Description сохраненные под прежним, переводятся на новое.
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param id path string true "UUID сервиса"
+// @Param service body model.ServiceRequest true "Новые данные сервиса"
+// @Success 200 {object} model.Service
+// @Failure 400 {object} ProblemDetails "Некорректный запрос, формат ID или ошибка валидации"
+// @Failure 404 {object} ProblemDetails "Сервис не найден"
+// @Failure 409 {object} ProblemDetails "Название или синоним заняты другим сервисом (service_name_taken)"
+// @Failure 500 {object} ProblemDetails "Ошибка сервиса или БД"
+// @Router /services/{id} [put]
+func (h *SubscriptionHandler) UpdateService(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	var req model.ServiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("ERROR: Failed to decode request body for service update: %v", err)
+		RespondProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "", "Incorrect format JSON")
+		return
+	}
+	service, err := h.Service.UpdateService(r.Context(), id, req)
+	if err != nil {
+		RespondServiceError(w, r, err)
+		return
+	}
+	RespondJSON(w, http.StatusOK, service)
+}
+
+// @Summary Удалить сервис из каталога
+// @Description Подписки сохраняют название, но теряют ссылку на запись каталога (service_id).
+// @Tags services
+// @Param id path string true "UUID сервиса"
+// @Success 204 "Сервис удален (No Content)"
+// @Failure 400 {object} ProblemDetails "Некорректный формат ID"
+// @Failure 404 {object} ProblemDetails "Сервис не найден"
+// @Router /services/{id} [delete]
+func (h *SubscriptionHandler) DeleteService(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := h.Service.DeleteService(r.Context(), vars["id"]); err != nil {
+		RespondServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -3,9 +3,9 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"io"
 	"log"
 	"net/http"
-	"strings"
 
 	"effective-mobile-subscriptions/internal/model"
 	"effective-mobile-subscriptions/internal/service"
@@ -19,17 +19,6 @@ func NewSubscriptionHandler(s *service.SubscriptionService) *SubscriptionHandler
 	return &SubscriptionHandler{Service: s}
 }
 
-// структуры для корректного отображения ошибок в Swagger
-type BadRequestResponse struct {
-	Error string `json:"error" example:"incorrect format user_id (expected UUID)"`
-}
-type SubscriptionNotFoundResponse struct {
-	Error string `json:"error" example:"Subscription not found"`
-}
-type InternalServerErrorResponse struct {
-	Error string `json:"error" example:"Internal Server Error"`
-}
-
 // отправляет JSON-ответ
 func RespondJSON(w http.ResponseWriter, status int, payload interface{}) {
 	response, err := json.Marshal(payload)
@@ -44,27 +33,47 @@ func RespondJSON(w http.ResponseWriter, status int, payload interface{}) {
 }
 
 // @Summary Создать новую подписку
-// @Description Создает новую запись об онлайн-подписке
+// @Description Создает новую запись об онлайн-подписке.
+// @Description С заголовком Idempotency-Key повтор запроса с тем же телом не создает дубль, а возвращает исходный ответ 201
+// @Description (с заголовком Idempotent-Replayed: true); тот же ключ с другим телом — 422. Ключ действует idempotency.ttl (по умолчанию 24 часа).
+// @Description Пересечение периода с другой подпиской пользователя на тот же сервис обрабатывается по overlap.policy:
+// @Description reject — 409, warn — подписка создается, в поле overlaps перечислены пересекающиеся подписки, allow — не проверяется.
 // @Tags subscriptions
 // @Accept json
 // @Produce json
 // @Param subscription body model.CreateSubscriptionRequest true "Данные новой подписки"
+// @Param X-Actor header string false "Автор изменения для истории"
+// @Param Idempotency-Key header string false "Ключ идемпотентности (до 255 символов)"
 // @Success 201 {object} model.Subscription
-// @Failure 400 {object} BadRequestResponse "Некорректный запрос или ошибка валидации (UUID, дата, формат JSON)"
+// @Header 201 {string} Idempotent-Replayed "true, если ответ повторен по Idempotency-Key"
+// @Failure 400 {object} ProblemDetails "Некорректный запрос или ошибка валидации (UUID, дата, формат JSON)"
+// @Failure 409 {object} ProblemDetails "Период пересекается с другой подпиской на тот же сервис (subscription_overlap)"
+// @Failure 422 {object} ProblemDetails "Idempotency-Key уже использован с другим телом запроса"
 // @Router /subscriptions [post]
 func (h *SubscriptionHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
 	var req model.CreateSubscriptionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Printf("ERROR: Invalid request payload: %v", err)
-		RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload or malformed JSON"})
+		RespondProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "", "Invalid request payload or malformed JSON")
 		return
 	}
-	sub, err := h.Service.Create(r.Context(), req)
+	var sub *model.Subscription
+	var err error
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		var replayed bool
+		sub, replayed, err = h.Service.CreateIdempotent(r.Context(), key, req)
+		if replayed {
+			w.Header().Set("Idempotent-Replayed", "true")
+		}
+	} else {
+		sub, err = h.Service.Create(r.Context(), req)
+	}
 	if err != nil {
 		log.Printf("ERROR: Service failed to create subscription: %v", err)
-		RespondServiceError(w, err)
+		RespondServiceError(w, r, err)
 		return
 	}
+	w.Header().Set("ETag", formatETag(sub.Version))
 	RespondJSON(w, http.StatusCreated, sub)
 }
 
@@ -73,31 +82,41 @@ func (h *SubscriptionHandler) CreateSubscription(w http.ResponseWriter, r *http.
 // @Produce json
 // @Param id path string true "UUID подписки"
 // @Success 200 {object} model.Subscription
-// @Failure 400 {object} BadRequestResponse "Некорректный формат ID"
-// @Failure 404 {object} SubscriptionNotFoundResponse "Подписка не найдена"
+// @Header 200 {string} ETag "Версия подписки для If-Match"
+// @Failure 400 {object} ProblemDetails "Некорректный формат ID"
+// @Failure 404 {object} ProblemDetails "Подписка не найдена"
 // @Router /subscriptions/{id} [get]
 func (h *SubscriptionHandler) GetSubscriptionByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 	sub, err := h.Service.GetByID(r.Context(), id)
 	if err != nil {
-		RespondServiceError(w, err)
+		RespondServiceError(w, r, err)
 		return
 	}
+	w.Header().Set("ETag", formatETag(sub.Version))
 	RespondJSON(w, http.StatusOK, sub)
 }
 
 // @Summary Обновить существующую подписку
 // @Description Обновляет существующую запись об онлайн-подписке, используя переданные поля.
+// @Description price, currency и billing_period действуют с месяца начала, поэтому у подписки, начавшейся до текущего месяца,
+// @Description их изменение отклоняется: цена меняется (или исправляется с месяца начала) через POST /subscriptions/{id}/price-changes
+// @Description (price_change_required), валюта и период списания не меняются (started_terms_locked).
 // @Tags subscriptions
 // @Accept json
 // @Produce json
 // @Param id path string true "UUID подписки"
 // @Param subscription body model.UpdateSubscriptionRequest true "Обновленные данные подписки"
+// @Param If-Match header string false "ETag, полученный при чтении подписки"
+// @Param X-Actor header string false "Автор изменения для истории"
 // @Success 200 {object} model.Subscription
-// @Failure 400 {object} BadRequestResponse "Некорректный запрос, формат ID или ошибка валидации"
-// @Failure 404 {object} SubscriptionNotFoundResponse "Подписка не найдена"
-// @Failure 500 {object} InternalServerErrorResponse "Ошибка сервиса или БД"
+// @Header 200 {string} ETag "Новая версия подписки"
+// @Failure 400 {object} ProblemDetails "Некорректный запрос, формат ID, ошибка валидации или изменение цены начавшейся подписки"
+// @Failure 404 {object} ProblemDetails "Подписка не найдена"
+// @Failure 409 {object} ProblemDetails "Новый период пересекается с другой подпиской на тот же сервис (subscription_overlap)"
+// @Failure 412 {object} ProblemDetails "Подписка изменена с момента чтения (version_mismatch)"
+// @Failure 500 {object} ProblemDetails "Ошибка сервиса или БД"
 // @Router /subscriptions/{id} [put]
 func (h *SubscriptionHandler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -105,104 +124,472 @@ func (h *SubscriptionHandler) UpdateSubscription(w http.ResponseWriter, r *http.
 	var req model.UpdateSubscriptionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Printf("ERROR: Failed to decode request body for update: %v", err)
-		RespondJSON(w, http.StatusBadRequest, BadRequestResponse{Error: "Incorrect format JSON"})
+		RespondProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "", "Incorrect format JSON")
 		return
 	}
-	updatedSub, err := h.Service.Update(r.Context(), id, req)
+	updatedSub, err := h.Service.Update(r.Context(), id, req, parseIfMatch(r))
 	if err != nil {
 		log.Printf("ERROR: Failed to update subscription %s in service: %v", id, err)
-		RespondServiceError(w, err)
+		RespondServiceError(w, r, err)
 		return
 	}
+	w.Header().Set("ETag", formatETag(updatedSub.Version))
 	RespondJSON(w, http.StatusOK, updatedSub)
 }
 
 // @Summary Удалить подписку по ID
+// @Description Мягкое удаление: подписка скрывается из выдачи и аналитики, но её можно восстановить
+// @Description через POST /subscriptions/{id}/restore до окончательной очистки.
 // @Tags subscriptions
 // @Param id path string true "UUID подписки"
+// @Param If-Match header string false "ETag, полученный при чтении подписки"
+// @Param X-Actor header string false "Автор изменения для истории"
 // @Success 204 "Подписка успешно удалена (No Content)"
-// @Failure 400 {object} BadRequestResponse "Некорректный формат ID"
-// @Failure 404 {object} SubscriptionNotFoundResponse "Подписка не найдена"
+// @Failure 400 {object} ProblemDetails "Некорректный формат ID"
+// @Failure 404 {object} ProblemDetails "Подписка не найдена"
+// @Failure 412 {object} ProblemDetails "Подписка изменена с момента чтения (version_mismatch)"
 // @Router /subscriptions/{id} [delete]
 func (h *SubscriptionHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
-	deleted, err := h.Service.Delete(r.Context(), id)
+	deleted, err := h.Service.Delete(r.Context(), id, parseIfMatch(r))
 	if err != nil {
-		RespondServiceError(w, err)
+		RespondServiceError(w, r, err)
 		return
 	}
 	if !deleted {
-		RespondJSON(w, http.StatusNotFound, map[string]string{"error": "Subscription not found"})
+		RespondServiceError(w, r, service.ErrSubscriptionNotFound)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// @Summary Получить список всех подписок
+// @Summary Восстановить удаленную подписку
+// @Description Отменяет мягкое удаление. Для неудаленной подписки возвращает её без изменений.
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "UUID подписки"
+// @Param X-Actor header string false "Автор изменения для истории"
+// @Success 200 {object} model.Subscription
+// @Header 200 {string} ETag "Новая версия подписки"
+// @Failure 400 {object} ProblemDetails "Некорректный формат ID"
+// @Failure 404 {object} ProblemDetails "Подписка не найдена или уже окончательно удалена"
+// @Failure 409 {object} ProblemDetails "После удаления создана пересекающаяся подписка на тот же сервис (subscription_overlap, политика reject)"
+// @Failure 500 {object} ProblemDetails "Ошибка сервиса или БД"
+// @Router /subscriptions/{id}/restore [post]
+func (h *SubscriptionHandler) RestoreSubscription(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	sub, err := h.Service.Restore(r.Context(), id)
+	if err != nil {
+		RespondServiceError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", formatETag(sub.Version))
+	RespondJSON(w, http.StatusOK, sub)
+}
+
+// @Summary Отменить подписку
+// @Description Подписка действует до конца месяца effective_month (по умолчанию текущего): он записывается в end_date.
+// @Description Повторная отмена с тем же месяцем ничего не меняет. Тело запроса можно не передавать.
 // @Tags subscriptions
+// @Accept json
 // @Produce json
-// @Success 200 {array} model.Subscription
-// @Failure 500 {object} InternalServerErrorResponse "Ошибка БД/сервиса"
+// @Param id path string true "UUID подписки"
+// @Param cancel body model.CancelRequest false "Последний месяц действия подписки (MM-YYYY)"
+// @Param If-Match header string false "ETag, полученный при чтении подписки"
+// @Param X-Actor header string false "Автор изменения для истории"
+// @Success 200 {object} model.Subscription
+// @Header 200 {string} ETag "Новая версия подписки"
+// @Failure 400 {object} ProblemDetails "Некорректный запрос, формат ID или месяц раньше начала подписки"
+// @Failure 404 {object} ProblemDetails "Подписка не найдена"
+// @Failure 409 {object} ProblemDetails "Подписка уже заканчивается раньше effective_month (invalid_status_transition)"
+// @Failure 412 {object} ProblemDetails "Подписка изменена с момента чтения (version_mismatch)"
+// @Failure 500 {object} ProblemDetails "Ошибка сервиса или БД"
+// @Router /subscriptions/{id}/cancel [post]
+func (h *SubscriptionHandler) CancelSubscription(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	var req model.CancelRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		log.Printf("ERROR: Failed to decode request body for cancel: %v", err)
+		RespondProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "", "Incorrect format JSON")
+		return
+	}
+	sub, err := h.Service.Cancel(r.Context(), id, req, parseIfMatch(r))
+	if err != nil {
+		RespondServiceError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", formatETag(sub.Version))
+	RespondJSON(w, http.StatusOK, sub)
+}
+
+// @Summary Приостановить подписку
+// @Description Приостановка действует с месяца from (по умолчанию текущего) до возобновления.
+// @Description Месяцы приостановки не учитываются в аналитике расходов и предстоящих списаниях.
+// @Description Месяц раньше текущего меняет стоимость прошедших месяцев и требует retroactive: true.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "UUID подписки"
+// @Param pause body model.PauseRequest false "Первый месяц приостановки (MM-YYYY)"
+// @Param If-Match header string false "ETag, полученный при чтении подписки"
+// @Param X-Actor header string false "Автор изменения для истории"
+// @Success 200 {object} model.Subscription
+// @Header 200 {string} ETag "Новая версия подписки"
+// @Failure 400 {object} ProblemDetails "Некорректный запрос, формат ID, месяц вне периода подписки или прошедший месяц без retroactive (retroactive_not_confirmed)"
+// @Failure 404 {object} ProblemDetails "Подписка не найдена"
+// @Failure 409 {object} ProblemDetails "Подписка уже приостановлена или заканчивается раньше from (invalid_status_transition)"
+// @Failure 412 {object} ProblemDetails "Подписка изменена с момента чтения (version_mismatch)"
+// @Failure 500 {object} ProblemDetails "Ошибка сервиса или БД"
+// @Router /subscriptions/{id}/pause [post]
+func (h *SubscriptionHandler) PauseSubscription(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	var req model.PauseRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		log.Printf("ERROR: Failed to decode request body for pause: %v", err)
+		RespondProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "", "Incorrect format JSON")
+		return
+	}
+	sub, err := h.Service.Pause(r.Context(), id, req, parseIfMatch(r))
+	if err != nil {
+		RespondServiceError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", formatETag(sub.Version))
+	RespondJSON(w, http.StatusOK, sub)
+}
+
+// @Summary Возобновить подписку
+// @Description Завершает текущую приостановку: подписка снова действует с месяца from (по умолчанию текущего).
+// @Description Месяц раньше текущего меняет стоимость прошедших месяцев и требует retroactive: true.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "UUID подписки"
+// @Param resume body model.PauseRequest false "Месяц возобновления (MM-YYYY)"
+// @Param If-Match header string false "ETag, полученный при чтении подписки"
+// @Param X-Actor header string false "Автор изменения для истории"
+// @Success 200 {object} model.Subscription
+// @Header 200 {string} ETag "Новая версия подписки"
+// @Failure 400 {object} ProblemDetails "Некорректный запрос, формат ID, месяц не позже начала приостановки или прошедший месяц без retroactive (retroactive_not_confirmed)"
+// @Failure 404 {object} ProblemDetails "Подписка не найдена"
+// @Failure 409 {object} ProblemDetails "Подписка не приостановлена (invalid_status_transition)"
+// @Failure 412 {object} ProblemDetails "Подписка изменена с момента чтения (version_mismatch)"
+// @Failure 500 {object} ProblemDetails "Ошибка сервиса или БД"
+// @Router /subscriptions/{id}/resume [post]
+func (h *SubscriptionHandler) ResumeSubscription(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	var req model.PauseRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		log.Printf("ERROR: Failed to decode request body for resume: %v", err)
+		RespondProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "", "Incorrect format JSON")
+		return
+	}
+	sub, err := h.Service.Resume(r.Context(), id, req, parseIfMatch(r))
+	if err != nil {
+		RespondServiceError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", formatETag(sub.Version))
+	RespondJSON(w, http.StatusOK, sub)
+}
+
+// @Summary Приостановки подписки
+// @Description Интервалы приостановки в порядке paused_from; resumed_from отсутствует у текущей приостановки.
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "UUID подписки"
+// @Success 200 {array} model.Pause
+// @Failure 400 {object} ProblemDetails "Некорректный формат ID"
+// @Failure 404 {object} ProblemDetails "Подписка не найдена"
+// @Failure 500 {object} ProblemDetails "Ошибка сервиса или БД"
+// @Router /subscriptions/{id}/pauses [get]
+func (h *SubscriptionHandler) GetSubscriptionPauses(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	pauses, err := h.Service.Pauses(r.Context(), id)
+	if err != nil {
+		RespondServiceError(w, r, err)
+		return
+	}
+	RespondJSON(w, http.StatusOK, pauses)
+}
+
+// разобрать необязательное JSON-тело: пустое тело оставляет req без изменений
+func decodeOptionalBody(r *http.Request, req interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// @Summary История изменений подписки
+// @Description События created, updated, deleted, restored, price_changed, paused и resumed в порядке возникновения: состояние до и после,
+// @Description автор (заголовок X-Actor запроса, изменившего подписку) и время. Доступна и для удаленных подписок.
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "UUID подписки"
+// @Success 200 {array} model.SubscriptionEvent
+// @Failure 400 {object} ProblemDetails "Некорректный формат ID"
+// @Failure 404 {object} ProblemDetails "Подписка не найдена"
+// @Failure 500 {object} ProblemDetails "Ошибка сервиса или БД"
+// @Router /subscriptions/{id}/history [get]
+func (h *SubscriptionHandler) GetSubscriptionHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	events, err := h.Service.History(r.Context(), id)
+	if err != nil {
+		RespondServiceError(w, r, err)
+		return
+	}
+	RespondJSON(w, http.StatusOK, events)
+}
+
+// @Summary Изменить цену подписки с указанного месяца
+// @Description Новая цена действует с первого числа месяца effective_from и учитывается аналитикой только для месяцев
+// @Description начиная с него; прошлые месяцы сохраняют прежнюю цену. Повторное изменение с того же месяца заменяет предыдущее.
+// @Description effective_from, равный месяцу начала подписки, исправляет исходную цену.
+// @Description Возвращает итоговый график цен подписки.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "UUID подписки"
+// @Param change body model.PriceChangeRequest true "Новая цена и месяц начала её действия (MM-YYYY)"
+// @Param X-Actor header string false "Автор изменения для истории"
+// @Success 201 {array} model.PriceChange
+// @Failure 400 {object} ProblemDetails "Некорректный запрос или ошибка валидации"
+// @Failure 404 {object} ProblemDetails "Подписка не найдена"
+// @Failure 500 {object} ProblemDetails "Ошибка сервиса или БД"
+// @Router /subscriptions/{id}/price-changes [post]
+func (h *SubscriptionHandler) ChangeSubscriptionPrice(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	var req model.PriceChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("ERROR: Failed to decode request body for price change: %v", err)
+		RespondProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "", "Incorrect format JSON")
+		return
+	}
+	schedule, err := h.Service.ChangePrice(r.Context(), id, req)
+	if err != nil {
+		RespondServiceError(w, r, err)
+		return
+	}
+	RespondJSON(w, http.StatusCreated, schedule)
+}
+
+// @Summary График цен подписки
+// @Description Исходная цена с месяца начала подписки и последующие изменения в порядке effective_from.
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "UUID подписки"
+// @Success 200 {array} model.PriceChange
+// @Failure 400 {object} ProblemDetails "Некорректный формат ID"
+// @Failure 404 {object} ProblemDetails "Подписка не найдена"
+// @Failure 500 {object} ProblemDetails "Ошибка сервиса или БД"
+// @Router /subscriptions/{id}/price-changes [get]
+func (h *SubscriptionHandler) GetSubscriptionPriceChanges(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	schedule, err := h.Service.PriceSchedule(r.Context(), id)
+	if err != nil {
+		RespondServiceError(w, r, err)
+		return
+	}
+	RespondJSON(w, http.StatusOK, schedule)
+}
+
+// @Summary Предстоящие списания по подписке
+// @Description Ближайшие даты списаний начиная с сегодняшнего дня: от start_date с шагом периода списания (billing_period)
+// @Description по end_date (для дат MM-YYYY — до конца месяца). Сумма — цена, действующая в месяце списания, с учетом изменений цены.
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "UUID подписки"
+// @Param limit query int false "Максимальное число списаний (1-100)" default(12)
+// @Success 200 {array} model.Charge
+// @Failure 400 {object} ProblemDetails "Некорректный формат ID или limit"
+// @Failure 404 {object} ProblemDetails "Подписка не найдена"
+// @Failure 500 {object} ProblemDetails "Ошибка сервиса или БД"
+// @Router /subscriptions/{id}/charges [get]
+func (h *SubscriptionHandler) GetUpcomingCharges(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	charges, err := h.Service.UpcomingCharges(r.Context(), id, r.URL.Query().Get("limit"))
+	if err != nil {
+		RespondServiceError(w, r, err)
+		return
+	}
+	RespondJSON(w, http.StatusOK, charges)
+}
+
+// @Summary Отчет о пересекающихся подписках
+// @Description Пары действующих подписок одного пользователя на один сервис с пересекающимися периодами, из-за которых аналитика
+// @Description учитывает сервис дважды. В паре первой идет подписка, начавшаяся раньше; overlap_start и overlap_end — общий период
+// @Description (overlap_end нет, если обе подписки бессрочные). Пары упорядочены по user_id, service_name и overlap_start.
+// @Tags subscriptions
+// @Produce json
+// @Param user_id query string false "Фильтр по UUID пользователя"
+// @Param service_name query string false "Фильтр по названию подписки"
+// @Param limit query int false "Максимальное число пар (1-1000)" default(100)
+// @Success 200 {array} model.SubscriptionOverlap
+// @Failure 400 {object} ProblemDetails "Ошибка валидации параметров запроса"
+// @Failure 500 {object} ProblemDetails "Ошибка БД/сервиса"
+// @Router /subscriptions/overlaps [get]
+func (h *SubscriptionHandler) GetSubscriptionOverlaps(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := model.OverlapsRequest{
+		UserID:      query.Get("user_id"),
+		ServiceName: query.Get("service_name"),
+		Limit:       query.Get("limit"),
+	}
+	overlaps, err := h.Service.Overlaps(r.Context(), req)
+	if err != nil {
+		RespondServiceError(w, r, err)
+		return
+	}
+	RespondJSON(w, http.StatusOK, overlaps)
+}
+
+// @Summary Получить список подписок
+// @Description Список с фильтрами, сортировкой по любому столбцу и keyset-пагинацией.
+// @Description Для следующей страницы передайте next_cursor из ответа в параметре cursor с теми же sort_by и order.
+// @Tags subscriptions
+// @Produce json
+// @Param user_id query string false "Фильтр по UUID пользователя"
+// @Param service_name query string false "Фильтр по названию подписки"
+// @Param price_min query int false "Минимальная цена (включительно)"
+// @Param price_max query int false "Максимальная цена (включительно)"
+// @Param active_at query string false "Подписка активна в месяце (MM-YYYY)"
+// @Param open_ended query bool false "true — только бессрочные, false — только с end_date"
+// @Param sort_by query string false "Столбец сортировки" Enums(id, user_id, service_name, price, start_date, end_date, created_at) default(created_at)
+// @Param order query string false "Направление сортировки (по умолчанию desc для created_at, иначе asc)" Enums(asc, desc)
+// @Param limit query int false "Размер страницы (1-500)" default(50)
+// @Param cursor query string false "Курсор следующей страницы"
+// @Param include_deleted query bool false "Включать мягко удаленные подписки" default(false)
+// @Success 200 {object} model.SubscriptionPage
+// @Failure 400 {object} ProblemDetails "Ошибка валидации параметров запроса"
+// @Failure 500 {object} ProblemDetails "Ошибка БД/сервиса"
 // @Router /subscriptions [get]
 func (h *SubscriptionHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
-	subscriptions, err := h.Service.List(r.Context())
+	page, err := h.Service.List(r.Context(), listRequestFromQuery(r))
 	if err != nil {
-		log.Printf("FATAL ERROR: Service failed to fetch list of subscriptions: %v", err)
-		RespondJSON(w, http.StatusInternalServerError, InternalServerErrorResponse{Error: "Internal Server Error"})
+		log.Printf("ERROR: Service failed to fetch list of subscriptions: %v", err)
+		RespondServiceError(w, r, err)
 		return
 	}
-	RespondJSON(w, http.StatusOK, subscriptions)
+	RespondJSON(w, http.StatusOK, page)
+}
+
+// собрать параметры списка из query-строки
+func listRequestFromQuery(r *http.Request) model.ListSubscriptionsRequest {
+	query := r.URL.Query()
+	return model.ListSubscriptionsRequest{
+		UserID:         query.Get("user_id"),
+		ServiceName:    query.Get("service_name"),
+		PriceMin:       query.Get("price_min"),
+		PriceMax:       query.Get("price_max"),
+		ActiveAt:       query.Get("active_at"),
+		OpenEnded:      query.Get("open_ended"),
+		SortBy:         query.Get("sort_by"),
+		Order:          query.Get("order"),
+		Limit:          query.Get("limit"),
+		Cursor:         query.Get("cursor"),
+		IncludeDeleted: query.Get("include_deleted"),
+	}
 }
 
 type CostAnalyticsResponse struct {
-	TotalCost int `json:"total_cost"`
+	TotalCost int    `json:"total_cost"`
+	Currency  string `json:"currency" example:"RUB"`
 }
 
 // @Summary Подсчет суммарной стоимости подписок по фильтрам
+// @Description В режиме prorated (по умолчанию) цена подписки умножается на число месяцев её активности внутри периода,
+// @Description бессрочные подписки учитываются до текущего месяца. В режиме start_date суммируются цены подписок, начавшихся в периоде.
+// @Description С параметром group_by вместо объекта возвращается массив model.CostGroup ({key, total_cost, currency, count}).
+// @Description Цены в разных валютах пересчитываются в валюту currency (по умолчанию базовую) по курсам источника курсов, сумма округляется.
+// @Description Цена подписки относится к её периоду списания: с basis=monthly_equivalent (по умолчанию) она приводится к месячной,
+// @Description с basis=charged учитывается в месяцах фактических списаний.
 // @Tags subscriptions
 // @Produce json
 // @Param user_id query string false "Фильтр по UUID пользователя"
 // @Param service_name query string false "Фильтр по названию подписки"
-// @Param start_date_from query string false "Период от (MM-YYYY)"
-// @Param start_date_to query string false "Период до (MM-YYYY)"
+// @Param start_date_from query string false "Период от (MM-YYYY или YYYY-MM-DD)"
+// @Param start_date_to query string false "Период до включительно (MM-YYYY — до конца месяца, или YYYY-MM-DD)"
+// @Param mode query string false "Режим подсчета" Enums(prorated, start_date) default(prorated)
+// @Param basis query string false "Учет периода списания" Enums(monthly_equivalent, charged) default(monthly_equivalent)
+// @Param proration query string false "Неполные месяцы: целиком (month) или по дням (day, только mode=prorated и basis=monthly_equivalent)" Enums(month, day) default(month)
+// @Param group_by query string false "Измерения группировки через запятую: service_name, user_id, month"
+// @Param currency query string false "Валюта результата (ISO 4217), по умолчанию базовая" example(USD)
 // @Success 200 {object} CostAnalyticsResponse
-// @Failure 400 {object} BadRequestResponse "Ошибка валидации параметров запроса (UUID, дата)"
+// @Failure 400 {object} ProblemDetails "Ошибка валидации параметров запроса (UUID, дата, валюта)"
+// @Failure 422 {object} ProblemDetails "В выборке есть подписки в валюте без курса пересчета (unsupported_currency)"
 // @Router /subscriptions/analytics [get]
 func (h *SubscriptionHandler) GetCostAnalytics(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-	req := model.CostAnalyticsRequest{
-		UserID:       query.Get("user_id"),
-		ServiceName:  query.Get("service_name"),
-		StartDateStr: query.Get("start_date_from"),
-		EndDateStr:   query.Get("start_date_to"),
+	req := costAnalyticsRequestFromQuery(r)
+	if req.GroupBy != "" {
+		groups, err := h.Service.GetGroupedCostAnalytics(r.Context(), req)
+		if err != nil {
+			log.Printf("WARN: Grouped analytics request failed: %v", err)
+			RespondServiceError(w, r, err)
+			return
+		}
+		RespondJSON(w, http.StatusOK, groups)
+		return
 	}
-	totalCost, err := h.Service.GetCostAnalytics(r.Context(), req)
+	totalCost, currency, err := h.Service.GetCostAnalytics(r.Context(), req)
 	if err != nil {
 		log.Printf("WARN: Analytics request validation error: %v", err)
-		RespondServiceError(w, err)
+		RespondServiceError(w, r, err)
 		return
 	}
-	RespondJSON(w, http.StatusOK, CostAnalyticsResponse{TotalCost: totalCost})
+	RespondJSON(w, http.StatusOK, CostAnalyticsResponse{TotalCost: totalCost, Currency: currency})
 }
 
-func RespondServiceError(w http.ResponseWriter, err error) {
-	switch {
-	case errors.Is(err, service.ErrValidation):
-		RespondJSON(w, http.StatusBadRequest, BadRequestResponse{Error: UserFacingErrorMessage(err)})
-	case errors.Is(err, service.ErrNotFound):
-		RespondJSON(w, http.StatusNotFound, SubscriptionNotFoundResponse{Error: "Subscription not found"})
-	default:
-		RespondJSON(w, http.StatusInternalServerError, InternalServerErrorResponse{Error: "Internal Server Error"})
+// @Summary Помесячный ряд стоимости подписок
+// @Description Возвращает по одной записи на каждый месяц периода (включая месяцы без трат): стоимость, число активных,
+// @Description новых и завершившихся подписок. Без start_date_to ряд заканчивается текущим месяцем, без start_date_from охватывает 12 месяцев.
+// @Tags subscriptions
+// @Produce json
+// @Param user_id query string false "Фильтр по UUID пользователя"
+// @Param service_name query string false "Фильтр по названию подписки"
+// @Param start_date_from query string false "Период от (MM-YYYY или YYYY-MM-DD)"
+// @Param start_date_to query string false "Период до включительно (MM-YYYY — до конца месяца, или YYYY-MM-DD)"
+// @Param basis query string false "Учет периода списания" Enums(monthly_equivalent, charged) default(monthly_equivalent)
+// @Param proration query string false "Неполные месяцы: целиком (month) или по дням (day, только basis=monthly_equivalent)" Enums(month, day) default(month)
+// @Param currency query string false "Валюта результата (ISO 4217), по умолчанию базовая" example(USD)
+// @Success 200 {array} model.CostBucket
+// @Failure 400 {object} ProblemDetails "Ошибка валидации параметров запроса (UUID, дата, длина периода, валюта)"
+// @Failure 422 {object} ProblemDetails "В выборке есть подписки в валюте без курса пересчета (unsupported_currency)"
+// @Failure 500 {object} ProblemDetails "Ошибка БД/сервиса"
+// @Router /subscriptions/analytics/timeseries [get]
+func (h *SubscriptionHandler) GetCostTimeSeries(w http.ResponseWriter, r *http.Request) {
+	req := costAnalyticsRequestFromQuery(r)
+	buckets, err := h.Service.GetCostTimeSeries(r.Context(), req)
+	if err != nil {
+		log.Printf("WARN: Time series request failed: %v", err)
+		RespondServiceError(w, r, err)
+		return
 	}
+	RespondJSON(w, http.StatusOK, buckets)
 }
 
-func UserFacingErrorMessage(err error) string {
-	const delimiter = ": "
-	msg := err.Error()
-	prefix := service.ErrValidation.Error() + delimiter
-	if strings.HasPrefix(msg, prefix) {
-		return msg[len(prefix):]
+// собрать параметры аналитики из query-строки
+func costAnalyticsRequestFromQuery(r *http.Request) model.CostAnalyticsRequest {
+	query := r.URL.Query()
+	return model.CostAnalyticsRequest{
+		UserID:       query.Get("user_id"),
+		ServiceName:  query.Get("service_name"),
+		StartDateStr: query.Get("start_date_from"),
+		EndDateStr:   query.Get("start_date_to"),
+		Mode:         query.Get("mode"),
+		Basis:        query.Get("basis"),
+		Proration:    query.Get("proration"),
+		GroupBy:      query.Get("group_by"),
+		Currency:     query.Get("currency"),
 	}
-	return msg
 }
@@ -0,0 +1,283 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ключ advisory lock, не дающий двум процессам применять миграции одновременно
+const lockKey = 7245190331
+
+// таблица учета примененных версий
+const schemaTable = "schema_migrations"
+
+// версия схемы, которую до появления раннера применяли вручную или через Flyway
+const baselineVersion = 1
+
+var fileNamePattern = regexp.MustCompile(`^V(\d+)__(\w+)\.(up|down)\.sql$`)
+
+// одна версия схемы с SQL для применения и отката
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// состояние версии схемы для команды status
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	// схема создана до появления раннера: версия будет отмечена примененной при первом up или down
+	BaselinePending bool
+}
+
+// применяет и откатывает встроенные миграции, ведя учет в schema_migrations
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+// собрать миграции из файловой системы (обычно migrations.FS)
+func NewMigrator(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := loadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// прочитать и упорядочить по версии файлы миграций
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration V%d__%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// применить все еще не примененные миграции; возвращает примененные версии
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied := make([]Migration, 0)
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.Migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			err := runInTx(ctx, conn, migration.Up,
+				`INSERT INTO `+schemaTable+` (version, name) VALUES ($1, $2)`, migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("failed to apply migration V%d__%s: %w", migration.Version, migration.Name, err)
+			}
+			log.Printf("Applied migration V%d__%s", migration.Version, migration.Name)
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// откатить steps последних примененных миграций; возвращает откаченные версии
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, errors.New("number of migrations to roll back must be positive")
+	}
+	reverted := make([]Migration, 0, steps)
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.Migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.Migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration V%d__%s has no down script", migration.Version, migration.Name)
+			}
+			err := runInTx(ctx, conn, migration.Down,
+				`DELETE FROM `+schemaTable+` WHERE version = $1`, migration.Version)
+			if err != nil {
+				return fmt.Errorf("failed to roll back migration V%d__%s: %w", migration.Version, migration.Name, err)
+			}
+			log.Printf("Rolled back migration V%d__%s", migration.Version, migration.Name)
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// получить список всех известных миграций с отметкой о применении. Status только читает базу:
+// schema_migrations не создается, а схема до появления раннера отмечается как ожидающая baseline
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire DB connection for migrations: %w", err)
+	}
+	defer conn.Close()
+	var tracked, legacy bool
+	err = conn.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL, to_regclass('subscriptions') IS NOT NULL`,
+		schemaTable).Scan(&tracked, &legacy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for an existing schema: %w", err)
+	}
+	versions := make(map[int]time.Time)
+	if tracked {
+		if versions, err = appliedVersions(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+	baselinePending := legacy && len(versions) == 0
+	statuses := make([]MigrationStatus, 0, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := versions[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		status.BaselinePending = baselinePending && migration.Version == baselineVersion
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// выполнить fn на выделенном соединении под advisory lock, предварительно создав schema_migrations
+// и отметив схему, созданную до появления раннера (см. baseline). Используется только командами,
+// меняющими схему (up и down)
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire DB connection for migrations: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey); err != nil {
+			log.Printf("ERROR: Failed to release migration lock: %v", err)
+		}
+	}()
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+schemaTable+` (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create %s table: %w", schemaTable, err)
+	}
+	if err := m.baseline(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// если schema_migrations пуста, а таблица subscriptions уже есть, схема создана до появления раннера:
+// версия baselineVersion отмечается примененной без выполнения скрипта
+func (m *Migrator) baseline(ctx context.Context, conn *sql.Conn) error {
+	var migrated, legacy bool
+	err := conn.QueryRowContext(ctx, `SELECT
+			EXISTS (SELECT 1 FROM `+schemaTable+`),
+			to_regclass('subscriptions') IS NOT NULL`).Scan(&migrated, &legacy)
+	if err != nil {
+		return fmt.Errorf("failed to check for an existing schema: %w", err)
+	}
+	if migrated || !legacy {
+		return nil
+	}
+	for _, migration := range m.Migrations {
+		if migration.Version != baselineVersion {
+			continue
+		}
+		_, err := conn.ExecContext(ctx, `INSERT INTO `+schemaTable+` (version, name) VALUES ($1, $2)`, migration.Version, migration.Name)
+		if err != nil {
+			return fmt.Errorf("failed to baseline migration V%d__%s: %w", migration.Version, migration.Name, err)
+		}
+		log.Printf("Existing schema found, migration V%d__%s marked as applied", migration.Version, migration.Name)
+	}
+	return nil
+}
+
+// примененные версии и время их применения
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM `+schemaTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+	versions := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("applied migration scanning error: %w", err)
+		}
+		versions[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after iterating rows: %w", err)
+	}
+	return versions, nil
+}
+
+// выполнить скрипт миграции и запись в schema_migrations в одной транзакции
+func runInTx(ctx context.Context, conn *sql.Conn, script, bookkeeping string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -1,36 +1,335 @@
 package model
 
 import (
+	"encoding/json"
 	"github.com/google/uuid"
 	"time"
 )
 
+// режимы подсчета стоимости в аналитике
+const (
+	// стоимость = цена * число месяцев активности подписки внутри периода
+	CostModeProrated = "prorated"
+	// стоимость = сумма цен подписок, начавшихся внутри периода
+	CostModeStartDate = "start_date"
+)
+
+// периоды списания цены подписки
+const (
+	BillingWeekly    = "weekly"
+	BillingMonthly   = "monthly"
+	BillingQuarterly = "quarterly"
+	BillingYearly    = "yearly"
+)
+
+// точность дат подписки
+const (
+	// даты заданы месяцами (MM-YYYY): подписка действует с первого дня start_date до конца месяца end_date
+	DatePrecisionMonth = "month"
+	// даты заданы днями (YYYY-MM-DD): подписка действует со start_date по end_date включительно
+	DatePrecisionDay = "day"
+)
+
+// пропорция стоимости за неполные месяцы в аналитике
+const (
+	// месяц активности учитывается целиком
+	ProrationMonth = "month"
+	// месяц учитывается пропорционально числу дней активности в нем
+	ProrationDay = "day"
+)
+
+// база подсчета стоимости в аналитике
+const (
+	// цена, приведенная к месяцу: годовая подписка дает 1/12 цены в каждом месяце активности
+	BasisMonthlyEquivalent = "monthly_equivalent"
+	// фактические списания: цена учитывается в месяцах, на которые приходятся даты списания
+	BasisCharged = "charged"
+)
+
+// статусы подписки, вычисляемые на текущую дату
+const (
+	// подписка действует
+	StatusActive = "active"
+	// подписка приостановлена в текущем месяце
+	StatusPaused = "paused"
+	// период подписки закончился
+	StatusEnded = "ended"
+	// подписка начнется в будущем
+	StatusScheduled = "scheduled"
+)
+
+// политики пересечения периодов подписок одного пользователя на один сервис
+const (
+	// пересекающаяся подписка не создается
+	OverlapPolicyReject = "reject"
+	// подписка создается, в ответе перечисляются пересекающиеся подписки
+	OverlapPolicyWarn = "warn"
+	// пересечения не проверяются
+	OverlapPolicyAllow = "allow"
+)
+
+// измерения группировки аналитики
+const (
+	GroupByServiceName = "service_name"
+	GroupByUserID      = "user_id"
+	GroupByMonth       = "month"
+)
+
 // запись в бд
 type Subscription struct {
-	ID          uuid.UUID  `json:"id"`
-	ServiceName string     `json:"service_name"`
-	Price       int        `json:"price"`
-	UserID      uuid.UUID  `json:"user_id"`
-	StartDate   time.Time  `json:"start_date"`
-	EndDate     *time.Time `json:"end_date,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
+	ID          uuid.UUID `json:"id"`
+	ServiceName string    `json:"service_name"`
+	// запись каталога сервисов, с которой сопоставлено service_name; отсутствует, если название не найдено в каталоге
+	ServiceID *uuid.UUID `json:"service_id,omitempty"`
+	Price     int        `json:"price"`
+	// код валюты цены (ISO 4217)
+	Currency string `json:"currency" example:"RUB"`
+	// период, за который списывается Price
+	BillingPeriod string     `json:"billing_period" example:"monthly" enums:"weekly,monthly,quarterly,yearly"`
+	UserID        uuid.UUID  `json:"user_id"`
+	StartDate     time.Time  `json:"start_date"`
+	EndDate       *time.Time `json:"end_date,omitempty"`
+	// month или day: с какой точностью заданы start_date и end_date
+	DatePrecision string    `json:"date_precision" example:"month" enums:"month,day"`
+	CreatedAt     time.Time `json:"created_at"`
+	// увеличивается при каждом изменении; используется как ETag
+	Version int `json:"version"`
+	// момент мягкого удаления; nil у действующей подписки
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// статус на текущую дату по периоду и приостановкам; не хранится
+	Status string `json:"status" example:"active" enums:"active,paused,ended,scheduled"`
+	// пересечение с другими подписками пользователя на тот же сервис допущено (политика warn или allow)
+	OverlapAllowed bool `json:"overlap_allowed"`
+	// ID пересекающихся подписок, найденных при создании или изменении (политика warn); не хранится
+	Overlaps []uuid.UUID `json:"overlaps,omitempty"`
+}
+
+// запись каталога сервисов
+type Service struct {
+	ID uuid.UUID `json:"id"`
+	// каноническое название: под ним сохраняются подписки на сервис
+	Name string `json:"name" example:"Yandex Plus"`
+	// другие названия того же сервиса; сравниваются без учета регистра и лишних пробелов
+	Aliases  []string `json:"aliases" example:"Яндекс Плюс,Яндекс.Плюс"`
+	Category string   `json:"category,omitempty" example:"music"`
+	// цена, которая подставляется при создании подписки без price
+	DefaultPrice *int `json:"default_price,omitempty" example:"399"`
+	// валюта цены по умолчанию (ISO 4217)
+	Currency  string    `json:"currency,omitempty" example:"RUB"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// тело запроса на создание или замену записи каталога сервисов
+type ServiceRequest struct {
+	Name         string   `json:"name" example:"Yandex Plus"`
+	Aliases      []string `json:"aliases" example:"Яндекс Плюс,Яндекс.Плюс"`
+	Category     string   `json:"category" example:"music"`
+	DefaultPrice *int     `json:"default_price" example:"399"`
+	// валюта цены по умолчанию; если не указана — базовая
+	Currency string `json:"currency" example:"RUB"`
+}
+
+// сопоставление названия сервиса из подписок с каталогом (подкоманда map-services)
+type ServiceMapping struct {
+	ServiceName string
+	// число подписок с этим названием, включая удаленные
+	Subscriptions int
+	// запись каталога; nil, если название не найдено
+	Service *Service
+	// подписок переведено на каноническое название
+	Remapped int64
+	// из них помечено как допущенное пересечение с подписками, уже имевшими каноническое название
+	OverlapAllowed int64
+}
+
+// пара действующих подписок пользователя на один сервис с пересекающимися периодами
+type SubscriptionOverlap struct {
+	UserID      uuid.UUID `json:"user_id"`
+	ServiceName string    `json:"service_name"`
+	// подписка, начавшаяся раньше
+	SubscriptionID uuid.UUID `json:"subscription_id"`
+	// подписка, пересекающаяся с ней
+	OverlappingID uuid.UUID `json:"overlapping_id"`
+	// первый и последний день пересечения; overlap_end отсутствует, если обе подписки бессрочные
+	OverlapStart time.Time  `json:"overlap_start"`
+	OverlapEnd   *time.Time `json:"overlap_end,omitempty"`
+}
+
+// параметры отчета о пересечениях из URL
+type OverlapsRequest struct {
+	UserID      string `json:"user_id"`
+	ServiceName string `json:"service_name"`
+	Limit       string `json:"limit"`
+}
+
+// провалидированные параметры отчета о пересечениях
+type OverlapFilter struct {
+	UserID      *uuid.UUID
+	ServiceName string
+	Limit       int
+}
+
+// виды событий истории подписки
+const (
+	EventCreated  = "created"
+	EventUpdated  = "updated"
+	EventDeleted  = "deleted"
+	EventRestored = "restored"
+	// изменение цены с указанного месяца; before/after — прежнее и новое изменение (PriceChange)
+	EventPriceChanged = "price_changed"
+	// приостановка и возобновление; before/after — приостановка (Pause) до и после
+	EventPaused  = "paused"
+	EventResumed = "resumed"
+)
+
+// запись истории изменений подписки: состояние до и после, автор и время
+type SubscriptionEvent struct {
+	ID             int64           `json:"id"`
+	SubscriptionID uuid.UUID       `json:"subscription_id"`
+	Type           string          `json:"type"`
+	Actor          string          `json:"actor"`
+	Before         json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After          json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// ключ идемпотентности создания подписки
+type IdempotencyKey struct {
+	Key string
+	// sha256 тела запроса, с которым ключ использован впервые
+	RequestHash string
+	// созданная подписка; nil, пока первый запрос не завершен
+	Response  json.RawMessage
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// предстоящее списание по подписке
+type Charge struct {
+	Date     time.Time `json:"date"`
+	Amount   int       `json:"amount"`
+	Currency string    `json:"currency" example:"RUB"`
+}
+
+// цена подписки, действующая с первого числа месяца EffectiveFrom
+type PriceChange struct {
+	EffectiveFrom time.Time `json:"effective_from"`
+	Price         int       `json:"price"`
+}
+
+// приостановка подписки: месяцы с PausedFrom до ResumedFrom (не включая) не оплачиваются и не учитываются аналитикой
+type Pause struct {
+	PausedFrom time.Time `json:"paused_from"`
+	// первый месяц после приостановки; nil — подписка приостановлена до возобновления
+	ResumedFrom *time.Time `json:"resumed_from,omitempty"`
+}
+
+// запрос на приостановку или возобновление подписки
+type PauseRequest struct {
+	// месяц (MM-YYYY), с которого подписка приостанавливается или возобновляется; по умолчанию текущий
+	From string `json:"from" example:"09-2025"`
+	// подтверждение месяца from раньше текущего: меняет стоимость прошедших месяцев в аналитике
+	Retroactive bool `json:"retroactive" example:"false"`
+}
+
+// запрос на отмену подписки
+type CancelRequest struct {
+	// последний месяц действия подписки (MM-YYYY); по умолчанию текущий
+	EffectiveMonth string `json:"effective_month" example:"12-2025"`
+}
+
+// запрос на изменение цены с указанного месяца
+type PriceChangeRequest struct {
+	Price         int    `json:"price"`
+	EffectiveFrom string `json:"effective_from"`
 }
 
 // структура для данных, получаемых в HTTP-запросе POST
 type CreateSubscriptionRequest struct {
+	// название или синоним из каталога сервисов; найденное в каталоге сохраняется под каноническим названием
 	ServiceName string `json:"service_name"`
-	Price       int    `json:"price"`
-	UserID      string `json:"user_id"`
-	StartDate   string `json:"start_date"`
-	EndDate     *string `json:"end_date"`
+	// можно не указывать, если у сервиса в каталоге есть цена по умолчанию
+	Price int `json:"price"`
+	// код валюты ISO 4217; по умолчанию базовая валюта сервиса
+	Currency string `json:"currency" example:"USD"`
+	// weekly, monthly (по умолчанию), quarterly или yearly
+	BillingPeriod string `json:"billing_period" example:"yearly"`
+	UserID        string `json:"user_id"`
+	// MM-YYYY или YYYY-MM-DD
+	StartDate string  `json:"start_date" example:"07-2025"`
+	EndDate   *string `json:"end_date" example:"2026-06-15"`
 }
 
 // запрос на обновление (PUT/PATCH)
 type UpdateSubscriptionRequest struct {
-    ServiceName *string `json:"service_name,omitempty"`
-    Price       *int    `json:"price,omitempty"`
-    StartDate   *string `json:"start_date,omitempty"`
-    EndDate     *string `json:"end_date,omitempty"`
+	ServiceName *string `json:"service_name,omitempty"`
+	// исходная цена; у подписки, начавшейся до текущего месяца, меняется только через POST /subscriptions/{id}/price-changes
+	Price *int `json:"price,omitempty"`
+	// валюта и период списания начавшейся до текущего месяца подписки не меняются
+	Currency      *string `json:"currency,omitempty"`
+	BillingPeriod *string `json:"billing_period,omitempty"`
+	StartDate     *string `json:"start_date,omitempty"`
+	EndDate       *string `json:"end_date,omitempty"`
+}
+
+// параметры списка подписок из URL
+type ListSubscriptionsRequest struct {
+	UserID      string `json:"user_id"`
+	ServiceName string `json:"service_name"`
+	PriceMin    string `json:"price_min"`
+	PriceMax    string `json:"price_max"`
+	ActiveAt    string `json:"active_at"`
+	OpenEnded   string `json:"open_ended"`
+	SortBy      string `json:"sort_by"`
+	Order       string `json:"order"`
+	Limit       string `json:"limit"`
+	Cursor      string `json:"cursor"`
+	// включать мягко удаленные подписки
+	IncludeDeleted string `json:"include_deleted"`
+}
+
+// столбцы, по которым допускается сортировка списка
+const (
+	SortByID          = "id"
+	SortByUserID      = "user_id"
+	SortByServiceName = "service_name"
+	SortByPrice       = "price"
+	SortByStartDate   = "start_date"
+	SortByEndDate     = "end_date"
+	SortByCreatedAt   = "created_at"
+)
+
+// позиция в списке для keyset-пагинации: значение столбца сортировки и ID последней выданной подписки
+type ListCursor struct {
+	SortBy string    `json:"s"`
+	Desc   bool      `json:"d"`
+	Value  string    `json:"v"`
+	ID     uuid.UUID `json:"id"`
+}
+
+// провалидированные фильтры, сортировка и пагинация списка
+type ListFilter struct {
+	UserID      *uuid.UUID
+	ServiceName string
+	PriceMin    *int
+	PriceMax    *int
+	ActiveAt    *time.Time
+	OpenEnded   *bool
+	SortBy      string
+	Desc        bool
+	Limit       int
+	Cursor      *ListCursor
+	// не скрывать мягко удаленные подписки
+	IncludeDeleted bool
+}
+
+// страница списка подписок
+type SubscriptionPage struct {
+	Items      []Subscription `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	Total      int            `json:"total"`
+	Next       *ListCursor    `json:"-"`
 }
 
 // сбор параметров аналитики из URL
@@ -39,4 +338,49 @@ type CostAnalyticsRequest struct {
 	ServiceName  string `json:"service_name"`
 	StartDateStr string `json:"start_date_from"`
 	EndDateStr   string `json:"start_date_to"`
+	Mode         string `json:"mode"`
+	GroupBy      string `json:"group_by"`
+	// валюта результата; по умолчанию базовая валюта сервиса
+	Currency string `json:"currency"`
+	Basis    string `json:"basis"`
+	// month (по умолчанию) или day
+	Proration string `json:"proration"`
+}
+
+// провалидированные фильтры аналитики, передаваемые в репозиторий
+type CostFilter struct {
+	UserID      *uuid.UUID
+	ServiceName string
+	// первый и последний месяцы периода (первые числа месяцев)
+	From *time.Time
+	To   *time.Time
+	// точные границы периода по дням включительно; nil — граница месяца From/To
+	FromDate *time.Time
+	ToDate   *time.Time
+	Mode     string
+	// ProrationMonth или ProrationDay
+	Proration string
+	// BasisMonthlyEquivalent или BasisCharged
+	Basis string
+	// множители пересчета цены из валюты подписки в валюту результата; nil — без пересчета
+	Rates map[string]float64
+}
+
+// месяц временного ряда стоимости
+type CostBucket struct {
+	Month       string    `json:"month" example:"07-2025"`
+	Period      time.Time `json:"-"`
+	TotalCost   int       `json:"total_cost"`
+	Currency    string    `json:"currency" example:"RUB"`
+	ActiveCount int       `json:"active_count"`
+	NewCount    int       `json:"new_count"`
+	EndedCount  int       `json:"ended_count"`
+}
+
+// строка сгруппированной аналитики; key содержит значения измерений из group_by
+type CostGroup struct {
+	Key       map[string]string `json:"key"`
+	TotalCost int               `json:"total_cost"`
+	Currency  string            `json:"currency" example:"RUB"`
+	Count     int               `json:"count"`
 }
@@ -0,0 +1,41 @@
+package rates
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// курсы из yaml-файла вида `USD: 92.5` (1 единица валюты = 92.5 единицы базовой валюты);
+// файл читается один раз при создании
+type FileProvider struct {
+	base  string
+	rates map[string]float64
+}
+
+func NewFileProvider(base, path string) (*FileProvider, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exchange rates file: %w", err)
+	}
+	rates := make(map[string]float64)
+	if err := yaml.Unmarshal(content, &rates); err != nil {
+		return nil, fmt.Errorf("failed to parse exchange rates file %s: %w", path, err)
+	}
+	for currency, rate := range rates {
+		if !ValidCurrency(currency) || rate <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %s: %v in %s", currency, rate, path)
+		}
+	}
+	return &FileProvider{base: base, rates: rates}, nil
+}
+
+func (p *FileProvider) Base() string {
+	return p.base
+}
+
+func (p *FileProvider) Rates(ctx context.Context, target string) (map[string]float64, error) {
+	return crossRates(p.base, p.rates, target)
+}
@@ -0,0 +1,47 @@
+// Package rates предоставляет курсы валют для пересчета стоимости подписок.
+package rates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// ErrUnknownCurrency возвращается, когда для валюты нет курса
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// допустимый код валюты ISO 4217
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// проверить формат кода валюты (ISO 4217, три заглавные латинские буквы)
+func ValidCurrency(code string) bool {
+	return currencyPattern.MatchString(code)
+}
+
+// источник курсов валют
+type Provider interface {
+	// базовая валюта, к которой заданы курсы; валюта подписок и аналитики по умолчанию
+	Base() string
+	// множители пересчета в валюту target для всех известных валют:
+	// сумма в валюте c, умноженная на rates[c], дает сумму в target
+	Rates(ctx context.Context, target string) (map[string]float64, error)
+}
+
+// пересчитать курсы к базовой валюте (1 единица валюты = toBase[c] единиц base) в множители к target
+func crossRates(base string, toBase map[string]float64, target string) (map[string]float64, error) {
+	all := make(map[string]float64, len(toBase)+1)
+	for currency, rate := range toBase {
+		all[currency] = rate
+	}
+	all[base] = 1
+	targetRate, ok := all[target]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCurrency, target)
+	}
+	multipliers := make(map[string]float64, len(all))
+	for currency, rate := range all {
+		multipliers[currency] = rate / targetRate
+	}
+	return multipliers, nil
+}
@@ -0,0 +1,44 @@
+package rates
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// курсы из таблицы exchange_rates; читаются при каждом запросе, поэтому обновления видны сразу
+type TableProvider struct {
+	DB   *sql.DB
+	base string
+}
+
+func NewTableProvider(db *sql.DB, base string) *TableProvider {
+	return &TableProvider{DB: db, base: base}
+}
+
+func (p *TableProvider) Base() string {
+	return p.base
+}
+
+func (p *TableProvider) Rates(ctx context.Context, target string) (map[string]float64, error) {
+	rows, err := p.DB.QueryContext(ctx, `SELECT currency, rate FROM exchange_rates`)
+	if err != nil {
+		log.Printf("ERROR: Failed to execute exchange rates query: %v", err)
+		return nil, fmt.Errorf("failed to fetch exchange rates from DB: %w", err)
+	}
+	defer rows.Close()
+	toBase := make(map[string]float64)
+	for rows.Next() {
+		var currency string
+		var rate float64
+		if err := rows.Scan(&currency, &rate); err != nil {
+			return nil, fmt.Errorf("exchange rate scanning error: %w", err)
+		}
+		toBase[currency] = rate
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after iterating rows: %w", err)
+	}
+	return crossRates(p.base, toBase, target)
+}
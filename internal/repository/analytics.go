@@ -0,0 +1,313 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"effective-mobile-subscriptions/internal/model"
+	"github.com/lib/pq"
+)
+
+// накапливает позиционные аргументы динамического запроса
+type queryArgs struct {
+	values []interface{}
+}
+
+// добавить аргумент и вернуть его плейсхолдер ($N)
+func (q *queryArgs) add(value interface{}) string {
+	q.values = append(q.values, value)
+	return fmt.Sprintf("$%d", len(q.values))
+}
+
+// построить подзапрос "строк стоимости" (id, user_id, service_name, currency, cost, month, start_date, end_date).
+// в режиме prorated каждая подписка разворачивается в месяцы своей активности внутри периода,
+// бессрочные подписки учитываются до текущего месяца включительно.
+// в режиме start_date подписка дает одну строку в месяце своего начала.
+// стоимость месяца — цена, действующая в этом месяце: последнее изменение из subscription_prices
+// с effective_from не позже месяца, иначе исходная цена подписки.
+// цена относится к периоду списания подписки и пересчитывается по filters.Basis (см. billingFactorExpr).
+// с filters.Proration = day стоимость месяца умножается на долю дней активности в нем (см. dayFractionExpr).
+// при заданных filters.Rates стоимость пересчитывается в валюту результата; у подписок в валютах без курса
+// cost равен NULL, такие строки находит checkRates.
+// месяцы, на которые подписка приостановлена, строк не дают.
+func costRowsQuery(filters model.CostFilter, args *queryArgs) string {
+	var query string
+	// месяц строки стоимости
+	month := "m.month"
+	fxJoin, fxRate := currencyConversion(filters, args)
+	if filters.Mode == model.CostModeStartDate {
+		month = "date_trunc('month', s.start_date)::date"
+		// в режиме charged подписка, начавшаяся в периоде, дает одно (первое) списание
+		factor := "1"
+		if filters.Basis != model.BasisCharged {
+			factor = billingFactorExpr(filters.Basis, "")
+		}
+		query = fmt.Sprintf(`SELECT s.id, s.user_id, s.service_name, s.currency, s.price * %s%s AS cost,
+			date_trunc('month', s.start_date)::date AS month, s.start_date, s.end_date
+			FROM subscriptions s
+			%s
+			WHERE 1=1`, factor, fxRate, fxJoin)
+		from, to := periodBounds(filters)
+		if from != nil {
+			query += " AND s.start_date >= " + args.add(*from)
+		}
+		if to != nil {
+			query += " AND s.start_date <= " + args.add(*to)
+		}
+	} else {
+		lower := "date_trunc('month', s.start_date)::date"
+		if filters.From != nil {
+			lower = fmt.Sprintf("GREATEST(%s, %s::date)", lower, args.add(*filters.From))
+		}
+		upper := "date_trunc('month', COALESCE(s.end_date, CURRENT_DATE))::date"
+		if filters.To != nil {
+			upper = fmt.Sprintf("LEAST(%s, %s::date)", upper, args.add(*filters.To))
+		}
+		factor := billingFactorExpr(filters.Basis, "m.month")
+		if filters.Proration == model.ProrationDay {
+			factor += " * " + dayFractionExpr(filters, args)
+		}
+		query = fmt.Sprintf(`SELECT s.id, s.user_id, s.service_name, s.currency, COALESCE(p.price, s.price) * %s%s AS cost,
+			m.month::date AS month, s.start_date, s.end_date
+			FROM subscriptions s
+			CROSS JOIN LATERAL generate_series(%s::timestamp, %s::timestamp, interval '1 month') AS m(month)
+			LEFT JOIN LATERAL (
+				SELECT sp.price FROM subscription_prices sp
+				WHERE sp.subscription_id = s.id
+					AND sp.effective_from <= m.month
+					AND sp.effective_from > date_trunc('month', s.start_date)
+				ORDER BY sp.effective_from DESC
+				LIMIT 1
+			) p ON TRUE
+			%s
+			WHERE 1=1`, factor, fxRate, lower, upper, fxJoin)
+	}
+	// мягко удаленные подписки в аналитике не учитываются
+	query += " AND s.deleted_at IS NULL AND " + notPausedExpr(month)
+	if filters.UserID != nil {
+		query += " AND s.user_id = " + args.add(*filters.UserID)
+	}
+	if filters.ServiceName != "" {
+		query += " AND s.service_name = " + args.add(filters.ServiceName)
+	}
+	return query
+}
+
+// точные границы периода по дням включительно: FromDate/ToDate, а без них — границы месяцев From/To
+func periodBounds(filters model.CostFilter) (*time.Time, *time.Time) {
+	from, to := filters.FromDate, filters.ToDate
+	if from == nil {
+		from = filters.From
+	}
+	if to == nil && filters.To != nil {
+		monthEnd := filters.To.AddDate(0, 1, -1)
+		to = &monthEnd
+	}
+	return from, to
+}
+
+// доля дней месяца m.month, в которые подписка активна внутри периода.
+// end_date с точностью month означает конец месяца, поэтому ограничивает только подписки с точностью day
+// (LEAST и GREATEST в PostgreSQL пропускают NULL)
+func dayFractionExpr(filters model.CostFilter, args *queryArgs) string {
+	monthEnd := "(m.month + interval '1 month' - interval '1 day')::date"
+	first := "m.month::date, s.start_date"
+	last := monthEnd + ", CASE WHEN s.date_precision = 'day' THEN s.end_date END"
+	from, to := periodBounds(filters)
+	if from != nil {
+		first += ", " + args.add(*from) + "::date"
+	}
+	if to != nil {
+		last += ", " + args.add(*to) + "::date"
+	}
+	return fmt.Sprintf("GREATEST(0, LEAST(%s) - GREATEST(%s) + 1)::numeric / (%s - m.month::date + 1)", last, first, monthEnd)
+}
+
+// множитель цены подписки для месяца month:
+// monthly_equivalent — доля цены периода списания, приходящаяся на месяц;
+// charged — число списаний в месяце (даты списания: start_date + k периодов; день, которого нет в месяце,
+// переносится на его последний день, поэтому ежемесячные, ежеквартальные и ежегодные списания считаются по месяцам)
+func billingFactorExpr(basis, month string) string {
+	if basis != model.BasisCharged {
+		return `(CASE s.billing_period WHEN 'weekly' THEN 52.0 / 12 WHEN 'quarterly' THEN 1.0 / 3 WHEN 'yearly' THEN 1.0 / 12 ELSE 1 END)`
+	}
+	monthsFromStart := fmt.Sprintf(`((EXTRACT(YEAR FROM %[1]s) - EXTRACT(YEAR FROM s.start_date)) * 12
+		+ EXTRACT(MONTH FROM %[1]s) - EXTRACT(MONTH FROM s.start_date))::integer`, month)
+	// еженедельные списания в [month, month + 1 месяц): номера k от ceil(d0 / 7) до floor((d1 - 1) / 7)
+	weeklyCharges := fmt.Sprintf(`GREATEST(0, FLOOR(((%[1]s + interval '1 month')::date - s.start_date - 1) / 7.0)
+		- GREATEST(0, CEIL((%[1]s::date - s.start_date) / 7.0)) + 1)`, month)
+	return fmt.Sprintf(`(CASE s.billing_period
+		WHEN 'weekly' THEN %s
+		WHEN 'quarterly' THEN CASE WHEN %s %% 3 = 0 THEN 1 ELSE 0 END
+		WHEN 'yearly' THEN CASE WHEN %s %% 12 = 0 THEN 1 ELSE 0 END
+		ELSE 1 END)`, weeklyCharges, monthsFromStart, monthsFromStart)
+}
+
+// соединение с курсами валют и множитель стоимости; пустые строки, если пересчет не нужен.
+// соединение внешнее, чтобы подписки в валютах без курса не выпадали из выборки молча
+func currencyConversion(filters model.CostFilter, args *queryArgs) (join, rate string) {
+	if filters.Rates == nil {
+		return "", ""
+	}
+	currencies := make([]string, 0, len(filters.Rates))
+	for currency := range filters.Rates {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	multipliers := make([]float64, 0, len(currencies))
+	for _, currency := range currencies {
+		multipliers = append(multipliers, filters.Rates[currency])
+	}
+	join = fmt.Sprintf("LEFT JOIN unnest(%s::text[], %s::numeric[]) AS fx(currency, rate) ON fx.currency = s.currency",
+		args.add(pq.Array(currencies)), args.add(pq.Array(multipliers)))
+	return join, " * fx.rate"
+}
+
+// вернуть ErrMissingRate, если в выборку попадают подписки в валютах без курса пересчета
+func (r *SubscriptionRepository) checkRates(ctx context.Context, filters model.CostFilter) error {
+	if filters.Rates == nil {
+		return nil
+	}
+	args := &queryArgs{}
+	query := fmt.Sprintf(`SELECT DISTINCT currency FROM (%s) AS cost_rows WHERE cost IS NULL`, costRowsQuery(filters, args))
+	rows, err := r.DB.QueryContext(ctx, query, args.values...)
+	if err != nil {
+		log.Printf("ERROR: Failed to check exchange rates of analytics rows: %v", err)
+		return fmt.Errorf("error when checking exchange rates: %w", err)
+	}
+	defer rows.Close()
+	var missing []string
+	for rows.Next() {
+		var currency string
+		if err := rows.Scan(&currency); err != nil {
+			return fmt.Errorf("currency row scanning error: %w", err)
+		}
+		missing = append(missing, currency)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error after iterating rows: %w", err)
+	}
+	if len(missing) > 0 {
+		return missingRateError(missing)
+	}
+	return nil
+}
+
+// округленная сумма стоимости строк: при пересчете валют стоимость дробная
+const sumCost = "ROUND(COALESCE(SUM(cost), 0)::numeric)::bigint"
+
+// подсчитать суммарную стоимость подписок по заданным фильтрам
+func (r *SubscriptionRepository) GetTotalCost(ctx context.Context, filters model.CostFilter) (int, error) {
+	if err := r.checkRates(ctx, filters); err != nil {
+		return 0, err
+	}
+	args := &queryArgs{}
+	query := fmt.Sprintf(`SELECT %s FROM (%s) AS cost_rows`, sumCost, costRowsQuery(filters, args))
+	var totalCost int64
+	err := r.DB.QueryRowContext(ctx, query, args.values...).Scan(&totalCost)
+	if err != nil {
+		log.Printf("ERROR: Failed to execute GetTotalCost analytics query: %v", err)
+		return 0, fmt.Errorf("error when executing an analytics request: %w", err)
+	}
+	return int(totalCost), nil
+}
+
+// получить помесячную стоимость и счетчики подписок; месяцы без активных подписок не возвращаются
+func (r *SubscriptionRepository) GetCostTimeSeries(ctx context.Context, filters model.CostFilter) ([]model.CostBucket, error) {
+	if err := r.checkRates(ctx, filters); err != nil {
+		return nil, err
+	}
+	args := &queryArgs{}
+	query := fmt.Sprintf(`SELECT month,
+			%s,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE month = date_trunc('month', start_date)::date),
+			COUNT(*) FILTER (WHERE end_date IS NOT NULL AND month = date_trunc('month', end_date)::date)
+		FROM (%s) AS cost_rows
+		GROUP BY month
+		ORDER BY month`, sumCost, costRowsQuery(filters, args))
+	rows, err := r.DB.QueryContext(ctx, query, args.values...)
+	if err != nil {
+		log.Printf("ERROR: Failed to execute GetCostTimeSeries analytics query: %v", err)
+		return nil, fmt.Errorf("error when executing a time series request: %w", err)
+	}
+	defer rows.Close()
+	buckets := make([]model.CostBucket, 0)
+	for rows.Next() {
+		bucket := model.CostBucket{}
+		var totalCost int64
+		if err := rows.Scan(&bucket.Period, &totalCost, &bucket.ActiveCount, &bucket.NewCount, &bucket.EndedCount); err != nil {
+			return nil, fmt.Errorf("time series row scanning error: %w", err)
+		}
+		bucket.TotalCost = int(totalCost)
+		buckets = append(buckets, bucket)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after iterating rows: %w", err)
+	}
+	return buckets, nil
+}
+
+// выражения для измерений группировки
+var groupByColumns = map[string]string{
+	model.GroupByServiceName: "service_name",
+	model.GroupByUserID:      "user_id::text",
+	model.GroupByMonth:       "to_char(month, 'MM-YYYY')",
+}
+
+// подсчитать стоимость и число подписок в разрезе измерений groupBy
+func (r *SubscriptionRepository) GetGroupedCost(ctx context.Context, filters model.CostFilter, groupBy []string) ([]model.CostGroup, error) {
+	columns := make([]string, 0, len(groupBy))
+	positions := make([]string, 0, len(groupBy))
+	for i, dimension := range groupBy {
+		column, ok := groupByColumns[dimension]
+		if !ok {
+			return nil, fmt.Errorf("unsupported group by dimension %q", dimension)
+		}
+		columns = append(columns, column)
+		positions = append(positions, fmt.Sprintf("%d", i+1))
+	}
+	if err := r.checkRates(ctx, filters); err != nil {
+		return nil, err
+	}
+	args := &queryArgs{}
+	query := fmt.Sprintf(`SELECT %s, %s, COUNT(DISTINCT id)
+		FROM (%s) AS cost_rows
+		GROUP BY %s
+		ORDER BY %s`,
+		strings.Join(columns, ", "), sumCost, costRowsQuery(filters, args),
+		strings.Join(positions, ", "), strings.Join(positions, ", "))
+	rows, err := r.DB.QueryContext(ctx, query, args.values...)
+	if err != nil {
+		log.Printf("ERROR: Failed to execute GetGroupedCost analytics query: %v", err)
+		return nil, fmt.Errorf("error when executing a grouped analytics request: %w", err)
+	}
+	defer rows.Close()
+	groups := make([]model.CostGroup, 0)
+	for rows.Next() {
+		values := make([]string, len(groupBy))
+		var totalCost int64
+		var count int
+		dest := make([]interface{}, 0, len(groupBy)+2)
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		dest = append(dest, &totalCost, &count)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("grouped analytics row scanning error: %w", err)
+		}
+		group := model.CostGroup{Key: make(map[string]string, len(groupBy)), TotalCost: int(totalCost), Count: count}
+		for i, dimension := range groupBy {
+			group.Key[dimension] = values[i]
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after iterating rows: %w", err)
+	}
+	return groups, nil
+}
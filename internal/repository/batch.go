@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"effective-mobile-subscriptions/internal/model"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// формат дат в массивах параметров ::date[]
+const arrayDateLayout = "2006-01-02"
+
+// сохранить несколько подписок одним запросом и заполнить их ID, CreatedAt и Version;
+// события created пишутся в той же транзакции. Ошибка любой строки отменяет весь пакет
+func (r *SubscriptionRepository) CreateBatch(ctx context.Context, subs []*model.Subscription) error {
+	if len(subs) == 0 {
+		return nil
+	}
+	ids := make([]string, len(subs))
+	userIDs := make([]string, len(subs))
+	serviceNames := make([]string, len(subs))
+	prices := make([]int64, len(subs))
+	currencies := make([]string, len(subs))
+	billingPeriods := make([]string, len(subs))
+	startDates := make([]string, len(subs))
+	endDates := make([]sql.NullString, len(subs))
+	precisions := make([]string, len(subs))
+	overlapAllowed := make([]bool, len(subs))
+	serviceIDs := make([]sql.NullString, len(subs))
+	byID := make(map[uuid.UUID]*model.Subscription, len(subs))
+	for i, sub := range subs {
+		// ID назначается заранее: порядок строк RETURNING не гарантирован
+		sub.ID = uuid.New()
+		byID[sub.ID] = sub
+		ids[i] = sub.ID.String()
+		userIDs[i] = sub.UserID.String()
+		serviceNames[i] = sub.ServiceName
+		prices[i] = int64(sub.Price)
+		currencies[i] = sub.Currency
+		billingPeriods[i] = sub.BillingPeriod
+		startDates[i] = sub.StartDate.Format(arrayDateLayout)
+		if sub.EndDate != nil {
+			endDates[i] = sql.NullString{String: sub.EndDate.Format(arrayDateLayout), Valid: true}
+		}
+		precisions[i] = sub.DatePrecision
+		overlapAllowed[i] = sub.OverlapAllowed
+		if sub.ServiceID != nil {
+			serviceIDs[i] = sql.NullString{String: sub.ServiceID.String(), Valid: true}
+		}
+	}
+	return r.withTx(ctx, func(tx *SubscriptionRepository) error {
+		query := `INSERT INTO subscriptions (id, user_id, service_name, price, currency, billing_period, start_date, end_date, date_precision, overlap_allowed, service_id)
+			SELECT * FROM unnest($1::uuid[], $2::uuid[], $3::text[], $4::integer[], $5::text[], $6::text[], $7::date[], $8::date[], $9::text[], $10::boolean[], $11::uuid[])
+			RETURNING id, created_at, version`
+		rows, err := tx.DB.QueryContext(ctx, query,
+			pq.Array(ids),
+			pq.Array(userIDs),
+			pq.Array(serviceNames),
+			pq.Array(prices),
+			pq.Array(currencies),
+			pq.Array(billingPeriods),
+			pq.Array(startDates),
+			pq.Array(endDates),
+			pq.Array(precisions),
+			pq.Array(overlapAllowed),
+			pq.Array(serviceIDs),
+		)
+		if err != nil {
+			log.Printf("ERROR: Failed to execute batch INSERT query for %d subscriptions: %v", len(subs), err)
+			return fmt.Errorf("error creating subscriptions in DB: %w", translateError(err))
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id uuid.UUID
+			var sub model.Subscription
+			if err := rows.Scan(&id, &sub.CreatedAt, &sub.Version); err != nil {
+				return fmt.Errorf("created subscription scanning error: %w", err)
+			}
+			byID[id].CreatedAt = sub.CreatedAt
+			byID[id].Version = sub.Version
+			byID[id].Status = subscriptionStatus(*byID[id], false)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error creating subscriptions in DB: %w", translateError(err))
+		}
+		events := make([]*model.SubscriptionEvent, 0, len(subs))
+		for _, sub := range subs {
+			event, err := newEvent(ctx, model.EventCreated, sub.ID, nil, sub)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		return tx.recordEvents(ctx, events)
+	})
+}
+
+// записать несколько событий истории одним запросом
+func (r *SubscriptionRepository) recordEvents(ctx context.Context, events []*model.SubscriptionEvent) error {
+	subscriptionIDs := make([]string, len(events))
+	types := make([]string, len(events))
+	actors := make([]string, len(events))
+	before := make([]sql.NullString, len(events))
+	after := make([]sql.NullString, len(events))
+	for i, event := range events {
+		subscriptionIDs[i] = event.SubscriptionID.String()
+		types[i] = event.Type
+		actors[i] = event.Actor
+		before[i] = sql.NullString{String: string(event.Before), Valid: event.Before != nil}
+		after[i] = sql.NullString{String: string(event.After), Valid: event.After != nil}
+	}
+	query := `INSERT INTO subscription_events (subscription_id, event_type, actor, before, after)
+		SELECT * FROM unnest($1::uuid[], $2::text[], $3::text[], $4::jsonb[], $5::jsonb[])`
+	_, err := r.DB.ExecContext(ctx, query,
+		pq.Array(subscriptionIDs), pq.Array(types), pq.Array(actors), pq.Array(before), pq.Array(after))
+	if err != nil {
+		log.Printf("ERROR: Failed to record %d subscription events: %v", len(events), err)
+		return fmt.Errorf("error recording subscription events: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ErrEndBeforeStart возвращается, когда end_date подписки раньше start_date
+var ErrEndBeforeStart = errors.New("end_date is earlier than start_date")
+
+// ErrVersionConflict возвращается, когда условная запись не прошла из-за изменившейся версии подписки
+var ErrVersionConflict = errors.New("subscription version conflict")
+
+// ErrOverlap возвращается, когда подписка пересекается с другой действующей подпиской пользователя на тот же сервис
+var ErrOverlap = errors.New("subscription overlaps with another subscription of the same service")
+
+// ErrMissingRate возвращается из аналитики, когда у подписок, попавших в выборку, валюта без курса пересчета
+var ErrMissingRate = errors.New("no exchange rate for subscription currency")
+
+// ErrServiceNameTaken возвращается, когда название или синоним сервиса уже заняты другой записью каталога
+var ErrServiceNameTaken = errors.New("service name or alias is already used by another service")
+
+// ошибка ErrMissingRate с перечнем валют без курса
+func missingRateError(currencies []string) error {
+	sort.Strings(currencies)
+	return fmt.Errorf("%w: %s", ErrMissingRate, strings.Join(currencies, ", "))
+}
+
+// имя CHECK-ограничения из миграции V2
+const endDateConstraint = "chk_subscriptions_end_date"
+
+// имя ограничения-исключения пересечений из миграции V11
+const overlapConstraint = "excl_subscriptions_overlap"
+
+// первичный ключ синонимов сервисов из миграции V13
+const serviceAliasConstraint = "service_aliases_pkey"
+
+// перевести известные нарушения ограничений PostgreSQL в ошибки репозитория
+func translateError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	if pqErr.Code == "23514" && pqErr.Constraint == endDateConstraint {
+		return ErrEndBeforeStart
+	}
+	if pqErr.Code == "23P01" && pqErr.Constraint == overlapConstraint {
+		return ErrOverlap
+	}
+	if pqErr.Code == "23505" && pqErr.Constraint == serviceAliasConstraint {
+		return ErrServiceNameTaken
+	}
+	return err
+}
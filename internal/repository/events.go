@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"effective-mobile-subscriptions/internal/model"
+	"effective-mobile-subscriptions/internal/requestctx"
+	"github.com/google/uuid"
+)
+
+// снимок состояния для истории (подписка или изменение цены); nil для отсутствующего состояния
+func eventSnapshot(value interface{}) (json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+	snapshot, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// собрать событие истории; автор берется из контекста запроса
+func newEvent(ctx context.Context, eventType string, subscriptionID uuid.UUID, before, after interface{}) (*model.SubscriptionEvent, error) {
+	event := &model.SubscriptionEvent{SubscriptionID: subscriptionID, Type: eventType, Actor: requestctx.Actor(ctx)}
+	var err error
+	if event.Before, err = eventSnapshot(before); err != nil {
+		return nil, err
+	}
+	if event.After, err = eventSnapshot(after); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// записать событие истории; вызывается внутри транзакции изменения подписки
+func (r *SubscriptionRepository) recordEvent(ctx context.Context, eventType string, subscriptionID uuid.UUID, before, after interface{}) error {
+	event, err := newEvent(ctx, eventType, subscriptionID, before, after)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO subscription_events (subscription_id, event_type, actor, before, after)
+		VALUES ($1, $2, $3, $4, $5)`
+	_, err = r.DB.ExecContext(ctx, query, event.SubscriptionID, event.Type, event.Actor, nullableJSON(event.Before), nullableJSON(event.After))
+	if err != nil {
+		log.Printf("ERROR: Failed to record %s event for subscription %s: %v", eventType, event.SubscriptionID, err)
+		return fmt.Errorf("error recording subscription event: %w", err)
+	}
+	return nil
+}
+
+// значение jsonb-параметра: NULL для пустого снимка
+func nullableJSON(raw json.RawMessage) interface{} {
+	if raw == nil {
+		return nil
+	}
+	return string(raw)
+}
+
+// получить историю подписки в порядке возникновения событий
+func (r *SubscriptionRepository) ListEvents(ctx context.Context, subscriptionID uuid.UUID) ([]model.SubscriptionEvent, error) {
+	query := `SELECT id, subscription_id, event_type, actor, before, after, created_at
+		FROM subscription_events
+		WHERE subscription_id = $1
+		ORDER BY id`
+	rows, err := r.DB.QueryContext(ctx, query, subscriptionID)
+	if err != nil {
+		log.Printf("ERROR: Failed to execute history query for ID %s: %v", subscriptionID, err)
+		return nil, fmt.Errorf("failed to fetch subscription history from DB: %w", err)
+	}
+	defer rows.Close()
+	events := make([]model.SubscriptionEvent, 0)
+	for rows.Next() {
+		event := model.SubscriptionEvent{}
+		var before, after []byte
+		if err := rows.Scan(&event.ID, &event.SubscriptionID, &event.Type, &event.Actor, &before, &after, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("subscription event scanning error: %w", err)
+		}
+		event.Before, event.After = before, after
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after iterating rows: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"effective-mobile-subscriptions/internal/model"
+)
+
+// сколько строк выбирается из курсора выгрузки за один FETCH
+const exportFetchSize = 500
+
+// передать в fn все подписки по фильтрам списка в порядке сортировки (без курсора и лимита).
+// строки читаются из серверного курсора порциями по exportFetchSize, поэтому выгрузка не держит в памяти весь результат
+func (r *SubscriptionRepository) Export(ctx context.Context, filters model.ListFilter, fn func(sub *model.Subscription) error) error {
+	column, ok := sortColumns[filters.SortBy]
+	if !ok {
+		return fmt.Errorf("unsupported sort column %q", filters.SortBy)
+	}
+	direction := "ASC"
+	if filters.Desc {
+		direction = "DESC"
+	}
+	args := &queryArgs{}
+	query := fmt.Sprintf(`DECLARE subscriptions_export NO SCROLL CURSOR FOR
+		SELECT %s
+		FROM subscriptions s
+		%s
+		ORDER BY %s %s, s.id %s`, subscriptionColumns, listWhere(filters, args), column.expr, direction, direction)
+	// курсор живет только внутри транзакции
+	return r.withTx(ctx, func(tx *SubscriptionRepository) error {
+		if _, err := tx.DB.ExecContext(ctx, query, args.values...); err != nil {
+			log.Printf("ERROR: Failed to declare export cursor: %v", err)
+			return fmt.Errorf("failed to start subscription export: %w", err)
+		}
+		fetch := fmt.Sprintf(`FETCH FORWARD %d FROM subscriptions_export`, exportFetchSize)
+		for {
+			fetched, err := tx.fetchExport(ctx, fetch, fn)
+			if err != nil {
+				return err
+			}
+			if fetched < exportFetchSize {
+				return nil
+			}
+		}
+	})
+}
+
+// выбрать очередную порцию строк курсора выгрузки и передать их в fn; возвращает число строк
+func (r *SubscriptionRepository) fetchExport(ctx context.Context, fetch string, fn func(sub *model.Subscription) error) (int, error) {
+	rows, err := r.DB.QueryContext(ctx, fetch)
+	if err != nil {
+		log.Printf("ERROR: Failed to fetch from export cursor: %v", err)
+		return 0, fmt.Errorf("failed to fetch exported subscriptions from DB: %w", err)
+	}
+	defer rows.Close()
+	fetched := 0
+	for rows.Next() {
+		sub := model.Subscription{}
+		if err := scanSubscription(rows, &sub); err != nil {
+			return 0, fmt.Errorf("subscription string scanning error: %w", err)
+		}
+		fetched++
+		if err := fn(&sub); err != nil {
+			return 0, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error after iterating rows: %w", err)
+	}
+	return fetched, nil
+}
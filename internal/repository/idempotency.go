@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"effective-mobile-subscriptions/internal/model"
+)
+
+// занять ключ идемпотентности. Если ключ уже занят и не истек, возвращает его запись, иначе nil.
+// вызывается внутри WithTx: до конца транзакции параллельный запрос с тем же ключом ждет на уникальном индексе
+func (r *SubscriptionRepository) ReserveIdempotencyKey(ctx context.Context, key model.IdempotencyKey) (*model.IdempotencyKey, error) {
+	// истекший ключ занимается заново
+	query := `INSERT INTO idempotency_keys (key, request_hash, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash,
+			response = NULL,
+			created_at = NOW(),
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+		RETURNING key`
+	var reserved string
+	err := r.DB.QueryRowContext(ctx, query, key.Key, key.RequestHash, key.ExpiresAt).Scan(&reserved)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("ERROR: Failed to reserve idempotency key: %v", err)
+		return nil, fmt.Errorf("error reserving idempotency key: %w", err)
+	}
+	existing := &model.IdempotencyKey{}
+	var response []byte
+	err = r.DB.QueryRowContext(ctx, `SELECT key, request_hash, response, created_at, expires_at
+		FROM idempotency_keys
+		WHERE key = $1`, key.Key).Scan(&existing.Key, &existing.RequestHash, &response, &existing.CreatedAt, &existing.ExpiresAt)
+	if err != nil {
+		log.Printf("ERROR: Failed to read idempotency key: %v", err)
+		return nil, fmt.Errorf("error reading idempotency key: %w", err)
+	}
+	if response != nil {
+		existing.Response = json.RawMessage(response)
+	}
+	return existing, nil
+}
+
+// сохранить ответ на запрос, занявший ключ
+func (r *SubscriptionRepository) CompleteIdempotencyKey(ctx context.Context, key string, response json.RawMessage) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE idempotency_keys SET response = $2 WHERE key = $1`, key, string(response))
+	if err != nil {
+		log.Printf("ERROR: Failed to save idempotent response: %v", err)
+		return fmt.Errorf("error saving idempotent response: %w", err)
+	}
+	return nil
+}
+
+// удалить ключи, истекшие раньше before
+func (r *SubscriptionRepository) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.DB.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < $1`, before)
+	if err != nil {
+		log.Printf("ERROR: Failed to purge expired idempotency keys: %v", err)
+		return 0, fmt.Errorf("error purging idempotency keys: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"effective-mobile-subscriptions/internal/model"
+)
+
+// выражение сортировки и тип значения курсора для каждого допустимого столбца;
+// бессрочная end_date сортируется как 'infinity', чтобы keyset-сравнение не спотыкалось о NULL
+var sortColumns = map[string]struct {
+	expr     string
+	castType string
+}{
+	model.SortByID:          {"s.id", "uuid"},
+	model.SortByUserID:      {"s.user_id", "uuid"},
+	model.SortByServiceName: {"s.service_name", "text"},
+	model.SortByPrice:       {"s.price", "integer"},
+	model.SortByStartDate:   {"s.start_date", "date"},
+	model.SortByEndDate:     {"COALESCE(s.end_date, 'infinity'::date)", "date"},
+	model.SortByCreatedAt:   {"s.created_at", "timestamptz"},
+}
+
+// значение бессрочной end_date в курсоре
+const infinityDate = "infinity"
+
+// строковое значение столбца сортировки подписки для курсора
+func sortValue(sub model.Subscription, column string) string {
+	switch column {
+	case model.SortByID:
+		return sub.ID.String()
+	case model.SortByUserID:
+		return sub.UserID.String()
+	case model.SortByServiceName:
+		return sub.ServiceName
+	case model.SortByPrice:
+		return strconv.Itoa(sub.Price)
+	case model.SortByStartDate:
+		return sub.StartDate.Format("2006-01-02")
+	case model.SortByEndDate:
+		if sub.EndDate == nil {
+			return infinityDate
+		}
+		return sub.EndDate.Format("2006-01-02")
+	default:
+		return sub.CreatedAt.Format(time.RFC3339Nano)
+	}
+}
+
+// условия WHERE для фильтров списка (без курсора)
+func listWhere(filters model.ListFilter, args *queryArgs) string {
+	where := "WHERE 1=1"
+	if filters.UserID != nil {
+		where += " AND s.user_id = " + args.add(*filters.UserID)
+	}
+	if filters.ServiceName != "" {
+		where += " AND s.service_name = " + args.add(filters.ServiceName)
+	}
+	if filters.PriceMin != nil {
+		where += " AND s.price >= " + args.add(*filters.PriceMin)
+	}
+	if filters.PriceMax != nil {
+		where += " AND s.price <= " + args.add(*filters.PriceMax)
+	}
+	if filters.ActiveAt != nil {
+		month := args.add(*filters.ActiveAt)
+		where += fmt.Sprintf(" AND date_trunc('month', s.start_date)::date <= %s::date AND (s.end_date IS NULL OR s.end_date >= %s::date)", month, month)
+	}
+	if !filters.IncludeDeleted {
+		where += " AND s.deleted_at IS NULL"
+	}
+	if filters.OpenEnded != nil {
+		if *filters.OpenEnded {
+			where += " AND s.end_date IS NULL"
+		} else {
+			where += " AND s.end_date IS NOT NULL"
+		}
+	}
+	return where
+}
+
+// предоставить страницу подписок с фильтрами, сортировкой и keyset-пагинацией
+func (r *SubscriptionRepository) List(ctx context.Context, filters model.ListFilter) (*model.SubscriptionPage, error) {
+	column, ok := sortColumns[filters.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort column %q", filters.SortBy)
+	}
+	args := &queryArgs{}
+	where := listWhere(filters, args)
+	page := &model.SubscriptionPage{Items: make([]model.Subscription, 0, filters.Limit)}
+	countQuery := `SELECT COUNT(*) FROM subscriptions s ` + where
+	if err := r.DB.QueryRowContext(ctx, countQuery, args.values...).Scan(&page.Total); err != nil {
+		log.Printf("ERROR: Failed to execute LIST count query: %v", err)
+		return nil, fmt.Errorf("failed to count subscriptions in DB: %w", err)
+	}
+	direction, comparison := "ASC", ">"
+	if filters.Desc {
+		direction, comparison = "DESC", "<"
+	}
+	if filters.Cursor != nil {
+		where += fmt.Sprintf(" AND (%s, s.id) %s (%s::%s, %s::uuid)",
+			column.expr, comparison, args.add(filters.Cursor.Value), column.castType, args.add(filters.Cursor.ID))
+	}
+	query := fmt.Sprintf(`SELECT %s
+		FROM subscriptions s
+		%s
+		ORDER BY %s %s, s.id %s
+		LIMIT %s`, subscriptionColumns, where, column.expr, direction, direction, args.add(filters.Limit+1))
+	rows, err := r.DB.QueryContext(ctx, query, args.values...)
+	if err != nil {
+		log.Printf("ERROR: Failed to execute LIST query: %v", err)
+		return nil, fmt.Errorf("failed to fetch subscription list from DB: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		sub := model.Subscription{}
+		if err := scanSubscription(rows, &sub); err != nil {
+			return nil, fmt.Errorf("subscription string scanning error: %w", err)
+		}
+		page.Items = append(page.Items, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after iterating rows: %w", err)
+	}
+	finishPage(page, filters)
+	return page, nil
+}
+
+// обрезать лишнюю (limit+1) запись и сформировать курсор следующей страницы
+func finishPage(page *model.SubscriptionPage, filters model.ListFilter) {
+	if len(page.Items) <= filters.Limit {
+		return
+	}
+	page.Items = page.Items[:filters.Limit]
+	last := page.Items[len(page.Items)-1]
+	page.Next = &model.ListCursor{
+		SortBy: filters.SortBy,
+		Desc:   filters.Desc,
+		Value:  sortValue(last, filters.SortBy),
+		ID:     last.ID,
+	}
+}
+
+// сравнить значения столбца сортировки с учетом их типа (как это делает PostgreSQL)
+func compareSortValues(column, a, b string) int {
+	switch column {
+	case model.SortByPrice:
+		x, _ := strconv.Atoi(a)
+		y, _ := strconv.Atoi(b)
+		return x - y
+	case model.SortByEndDate:
+		if a == infinityDate || b == infinityDate {
+			switch {
+			case a == b:
+				return 0
+			case a == infinityDate:
+				return 1
+			default:
+				return -1
+			}
+		}
+	case model.SortByCreatedAt:
+		x, _ := time.Parse(time.RFC3339Nano, a)
+		y, _ := time.Parse(time.RFC3339Nano, b)
+		return x.Compare(y)
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,1120 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"effective-mobile-subscriptions/internal/model"
+	"github.com/google/uuid"
+)
+
+// потокобезопасное хранилище подписок в памяти (для демо и тестов без PostgreSQL)
+type MemorySubscriptionRepository struct {
+	mu    *sync.RWMutex
+	state *memoryState
+	// true для представления внутри WithTx: блокировка уже удерживается транзакцией
+	inTx bool
+}
+
+// данные in-memory хранилища; клонируется целиком для отката транзакции
+type memoryState struct {
+	subscriptions map[uuid.UUID]model.Subscription
+	events        []model.SubscriptionEvent
+	// изменения цены каждой подписки, упорядоченные по EffectiveFrom
+	prices map[uuid.UUID][]model.PriceChange
+	// ключи идемпотентности по значению ключа
+	idempotency map[string]model.IdempotencyKey
+	// приостановки каждой подписки, упорядоченные по PausedFrom
+	pauses map[uuid.UUID][]model.Pause
+	// каталог сервисов и ID записи по нормализованному названию или синониму (ServiceKey)
+	services   map[uuid.UUID]model.Service
+	serviceIDs map[string]uuid.UUID
+}
+
+func NewMemorySubscriptionRepository() *MemorySubscriptionRepository {
+	return &MemorySubscriptionRepository{
+		mu: &sync.RWMutex{},
+		state: &memoryState{
+			subscriptions: make(map[uuid.UUID]model.Subscription),
+			prices:        make(map[uuid.UUID][]model.PriceChange),
+			idempotency:   make(map[string]model.IdempotencyKey),
+			pauses:        make(map[uuid.UUID][]model.Pause),
+			services:      make(map[uuid.UUID]model.Service),
+			serviceIDs:    make(map[string]uuid.UUID),
+		},
+	}
+}
+
+// глубокая копия состояния
+func (s *memoryState) clone() *memoryState {
+	cloned := &memoryState{
+		subscriptions: make(map[uuid.UUID]model.Subscription, len(s.subscriptions)),
+		prices:        make(map[uuid.UUID][]model.PriceChange, len(s.prices)),
+		idempotency:   make(map[string]model.IdempotencyKey, len(s.idempotency)),
+		pauses:        make(map[uuid.UUID][]model.Pause, len(s.pauses)),
+		services:      make(map[uuid.UUID]model.Service, len(s.services)),
+		serviceIDs:    make(map[string]uuid.UUID, len(s.serviceIDs)),
+	}
+	for id, sub := range s.subscriptions {
+		cloned.subscriptions[id] = copySubscription(sub)
+	}
+	// события неизменяемы, достаточно скопировать срез
+	cloned.events = append([]model.SubscriptionEvent(nil), s.events...)
+	for id, changes := range s.prices {
+		cloned.prices[id] = append([]model.PriceChange(nil), changes...)
+	}
+	// сохраненный ответ не изменяется после записи
+	for key, record := range s.idempotency {
+		cloned.idempotency[key] = record
+	}
+	// приостановки заменяются целиком, поэтому достаточно скопировать срезы
+	for id, pauses := range s.pauses {
+		cloned.pauses[id] = append([]model.Pause(nil), pauses...)
+	}
+	for id, service := range s.services {
+		cloned.services[id] = copyService(service)
+	}
+	for key, id := range s.serviceIDs {
+		cloned.serviceIDs[key] = id
+	}
+	return cloned
+}
+
+// захватить блокировку на запись (внутри транзакции она уже захвачена); возвращает функцию освобождения
+func (r *MemorySubscriptionRepository) lock() func() {
+	if r.inTx {
+		return func() {}
+	}
+	r.mu.Lock()
+	return r.mu.Unlock
+}
+
+// захватить блокировку на чтение; возвращает функцию освобождения
+func (r *MemorySubscriptionRepository) rlock() func() {
+	if r.inTx {
+		return func() {}
+	}
+	r.mu.RLock()
+	return r.mu.RUnlock
+}
+
+// выполнить fn атомарно: транзакция держит эксклюзивную блокировку, при ошибке состояние откатывается
+func (r *MemorySubscriptionRepository) WithTx(ctx context.Context, fn func(tx Store) error) (err error) {
+	if r.inTx {
+		return fn(r)
+	}
+	defer r.lock()()
+	snapshot := r.state.clone()
+	defer func() {
+		if p := recover(); p != nil {
+			*r.state = *snapshot
+			panic(p)
+		}
+		if err != nil {
+			*r.state = *snapshot
+		}
+	}()
+	return fn(&MemorySubscriptionRepository{mu: r.mu, state: r.state, inTx: true})
+}
+
+// в памяти чтение внутри транзакции уже защищено эксклюзивной блокировкой
+func (r *MemorySubscriptionRepository) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
+	return r.GetByID(ctx, id)
+}
+
+// сохранить новую подписку и заполнить сгенерированные ID и CreatedAt
+func (r *MemorySubscriptionRepository) Create(ctx context.Context, sub *model.Subscription) error {
+	if sub.EndDate != nil && sub.EndDate.Before(sub.StartDate) {
+		return fmt.Errorf("error creating subscription: %w", ErrEndBeforeStart)
+	}
+	defer r.lock()()
+	if r.violatesOverlapConstraint(*sub) {
+		return fmt.Errorf("error creating subscription: %w", ErrOverlap)
+	}
+	sub.ID = uuid.New()
+	sub.CreatedAt = time.Now()
+	sub.Version = 1
+	sub.Status = subscriptionStatus(*sub, false)
+	r.state.subscriptions[sub.ID] = copySubscription(*sub)
+	return r.recordEvent(ctx, model.EventCreated, sub.ID, nil, sub)
+}
+
+// сохранить несколько подписок; при ошибке любой из них ни одна не сохраняется
+func (r *MemorySubscriptionRepository) CreateBatch(ctx context.Context, subs []*model.Subscription) error {
+	for _, sub := range subs {
+		if sub.EndDate != nil && sub.EndDate.Before(sub.StartDate) {
+			return fmt.Errorf("error creating subscriptions: %w", ErrEndBeforeStart)
+		}
+	}
+	defer r.lock()()
+	for i, sub := range subs {
+		if r.violatesOverlapConstraint(*sub) {
+			return fmt.Errorf("error creating subscriptions: %w", ErrOverlap)
+		}
+		for _, previous := range subs[:i] {
+			if overlapExcluded(*previous, *sub) {
+				return fmt.Errorf("error creating subscriptions: %w", ErrOverlap)
+			}
+		}
+	}
+	for _, sub := range subs {
+		sub.ID = uuid.New()
+		sub.CreatedAt = time.Now()
+		sub.Version = 1
+		sub.Status = subscriptionStatus(*sub, false)
+		r.state.subscriptions[sub.ID] = copySubscription(*sub)
+		if err := r.recordEvent(ctx, model.EventCreated, sub.ID, nil, sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// занять ключ идемпотентности; возвращает запись, если ключ уже занят и не истек
+func (r *MemorySubscriptionRepository) ReserveIdempotencyKey(ctx context.Context, key model.IdempotencyKey) (*model.IdempotencyKey, error) {
+	defer r.lock()()
+	if existing, ok := r.state.idempotency[key.Key]; ok && existing.ExpiresAt.After(time.Now()) {
+		return &existing, nil
+	}
+	key.Response = nil
+	key.CreatedAt = time.Now()
+	r.state.idempotency[key.Key] = key
+	return nil, nil
+}
+
+// сохранить ответ для занятого ключа
+func (r *MemorySubscriptionRepository) CompleteIdempotencyKey(ctx context.Context, key string, response json.RawMessage) error {
+	defer r.lock()()
+	record, ok := r.state.idempotency[key]
+	if !ok {
+		return fmt.Errorf("idempotency key %q is not reserved", key)
+	}
+	record.Response = append(json.RawMessage(nil), response...)
+	r.state.idempotency[key] = record
+	return nil
+}
+
+// удалить ключи идемпотентности, истекшие раньше before
+func (r *MemorySubscriptionRepository) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	defer r.lock()()
+	var purged int64
+	for key, record := range r.state.idempotency {
+		if record.ExpiresAt.Before(before) {
+			delete(r.state.idempotency, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// извлечь подписку по её UUID; nil, если подписки нет или она мягко удалена
+func (r *MemorySubscriptionRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
+	defer r.rlock()()
+	sub, ok := r.state.subscriptions[id]
+	if !ok || sub.DeletedAt != nil {
+		return nil, nil
+	}
+	found := copySubscription(sub)
+	r.setStatus(&found)
+	return &found, nil
+}
+
+// обновить изменяемые поля существующей подписки
+func (r *MemorySubscriptionRepository) Update(ctx context.Context, sub *model.Subscription) error {
+	if sub.EndDate != nil && sub.EndDate.Before(sub.StartDate) {
+		return fmt.Errorf("error updating subscription: %w", ErrEndBeforeStart)
+	}
+	defer r.lock()()
+	existing, ok := r.state.subscriptions[sub.ID]
+	if !ok || existing.DeletedAt != nil {
+		return fmt.Errorf("update record not found: %w", sql.ErrNoRows)
+	}
+	if existing.Version != sub.Version {
+		return fmt.Errorf("subscription %s was modified concurrently: %w", sub.ID, ErrVersionConflict)
+	}
+	before := copySubscription(existing)
+	existing.ServiceName = sub.ServiceName
+	existing.Price = sub.Price
+	existing.Currency = sub.Currency
+	existing.BillingPeriod = sub.BillingPeriod
+	existing.StartDate = sub.StartDate
+	existing.EndDate = sub.EndDate
+	existing.DatePrecision = sub.DatePrecision
+	existing.OverlapAllowed = sub.OverlapAllowed
+	existing.ServiceID = sub.ServiceID
+	if r.violatesOverlapConstraint(existing) {
+		return fmt.Errorf("error updating subscription: %w", ErrOverlap)
+	}
+	existing.Version++
+	r.state.subscriptions[sub.ID] = copySubscription(existing)
+	sub.CreatedAt = existing.CreatedAt
+	sub.Version = existing.Version
+	r.setStatus(sub)
+	return r.recordEvent(ctx, model.EventUpdated, sub.ID, &before, sub)
+}
+
+// мягко удалить подписку по её ID; если expectedVersion задан, удаление выполняется только при совпадении версии
+func (r *MemorySubscriptionRepository) Delete(ctx context.Context, id uuid.UUID, expectedVersion *int) (bool, error) {
+	defer r.lock()()
+	existing, ok := r.state.subscriptions[id]
+	if !ok || existing.DeletedAt != nil {
+		return false, nil
+	}
+	if expectedVersion != nil && existing.Version != *expectedVersion {
+		return false, fmt.Errorf("subscription %s was modified concurrently: %w", id, ErrVersionConflict)
+	}
+	before := copySubscription(existing)
+	deletedAt := time.Now()
+	existing.DeletedAt = &deletedAt
+	existing.Version++
+	r.state.subscriptions[id] = existing
+	if err := r.recordEvent(ctx, model.EventDeleted, id, &before, &existing); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// восстановить мягко удаленную подписку; nil, если удаленной подписки с таким ID нет
+func (r *MemorySubscriptionRepository) Restore(ctx context.Context, id uuid.UUID, check func(sub *model.Subscription) error) (*model.Subscription, error) {
+	defer r.lock()()
+	existing, ok := r.state.subscriptions[id]
+	if !ok || existing.DeletedAt == nil {
+		return nil, nil
+	}
+	before := copySubscription(existing)
+	candidate := copySubscription(existing)
+	if err := check(&candidate); err != nil {
+		return nil, err
+	}
+	existing.OverlapAllowed = candidate.OverlapAllowed
+	existing.DeletedAt = nil
+	if r.violatesOverlapConstraint(existing) {
+		return nil, fmt.Errorf("error restoring subscription: %w", ErrOverlap)
+	}
+	existing.Version++
+	r.state.subscriptions[id] = existing
+	restored := copySubscription(existing)
+	r.setStatus(&restored)
+	if err := r.recordEvent(ctx, model.EventRestored, id, &before, &restored); err != nil {
+		return nil, err
+	}
+	return &restored, nil
+}
+
+// найти действующие подписки того же пользователя на тот же сервис с пересекающимся периодом (кроме самой sub)
+func (r *MemorySubscriptionRepository) FindOverlapping(ctx context.Context, sub *model.Subscription) ([]model.Subscription, error) {
+	defer r.rlock()()
+	overlapping := make([]model.Subscription, 0)
+	for _, other := range r.state.subscriptions {
+		if other.ID == sub.ID || other.DeletedAt != nil || other.UserID != sub.UserID || other.ServiceName != sub.ServiceName {
+			continue
+		}
+		if periodsOverlap(other, *sub) {
+			found := copySubscription(other)
+			r.setStatus(&found)
+			overlapping = append(overlapping, found)
+		}
+	}
+	sort.Slice(overlapping, func(i, j int) bool {
+		if !overlapping[i].StartDate.Equal(overlapping[j].StartDate) {
+			return overlapping[i].StartDate.Before(overlapping[j].StartDate)
+		}
+		return overlapping[i].ID.String() < overlapping[j].ID.String()
+	})
+	return overlapping, nil
+}
+
+// найти пары пересекающихся действующих подписок в том же порядке, что и ListOverlaps PostgreSQL
+func (r *MemorySubscriptionRepository) ListOverlaps(ctx context.Context, filters model.OverlapFilter) ([]model.SubscriptionOverlap, error) {
+	defer r.rlock()()
+	active := make([]model.Subscription, 0, len(r.state.subscriptions))
+	for _, sub := range r.state.subscriptions {
+		if sub.DeletedAt != nil || (filters.UserID != nil && sub.UserID != *filters.UserID) ||
+			(filters.ServiceName != "" && sub.ServiceName != filters.ServiceName) {
+			continue
+		}
+		active = append(active, sub)
+	}
+	// порядок (start_date, id) задает, какая подписка пары первая
+	sort.Slice(active, func(i, j int) bool {
+		if !active[i].StartDate.Equal(active[j].StartDate) {
+			return active[i].StartDate.Before(active[j].StartDate)
+		}
+		return active[i].ID.String() < active[j].ID.String()
+	})
+	overlaps := make([]model.SubscriptionOverlap, 0)
+	for i, first := range active {
+		for _, second := range active[i+1:] {
+			if first.UserID != second.UserID || first.ServiceName != second.ServiceName || !periodsOverlap(first, second) {
+				continue
+			}
+			overlap := model.SubscriptionOverlap{
+				UserID:         first.UserID,
+				ServiceName:    first.ServiceName,
+				SubscriptionID: first.ID,
+				OverlappingID:  second.ID,
+				OverlapStart:   second.StartDate,
+			}
+			until := periodEnd(first)
+			if secondEnd := periodEnd(second); until == nil || (secondEnd != nil && secondEnd.Before(*until)) {
+				until = secondEnd
+			}
+			if until != nil {
+				lastDay := until.AddDate(0, 0, -1)
+				overlap.OverlapEnd = &lastDay
+			}
+			overlaps = append(overlaps, overlap)
+		}
+	}
+	sort.SliceStable(overlaps, func(i, j int) bool {
+		a, b := overlaps[i], overlaps[j]
+		if a.UserID != b.UserID {
+			return a.UserID.String() < b.UserID.String()
+		}
+		if a.ServiceName != b.ServiceName {
+			return a.ServiceName < b.ServiceName
+		}
+		if !a.OverlapStart.Equal(b.OverlapStart) {
+			return a.OverlapStart.Before(b.OverlapStart)
+		}
+		if a.SubscriptionID != b.SubscriptionID {
+			return a.SubscriptionID.String() < b.SubscriptionID.String()
+		}
+		return a.OverlappingID.String() < b.OverlappingID.String()
+	})
+	if len(overlaps) > filters.Limit {
+		overlaps = overlaps[:filters.Limit]
+	}
+	return overlaps, nil
+}
+
+// нарушит ли sub ограничение excl_subscriptions_overlap; вызывается под блокировкой на запись
+func (r *MemorySubscriptionRepository) violatesOverlapConstraint(sub model.Subscription) bool {
+	for _, other := range r.state.subscriptions {
+		if other.ID != sub.ID && overlapExcluded(other, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// пара подписок, которую запрещает excl_subscriptions_overlap: обе действующие, пересечение не допущено,
+// один пользователь и сервис, периоды пересекаются
+func overlapExcluded(a, b model.Subscription) bool {
+	if a.DeletedAt != nil || b.DeletedAt != nil || a.OverlapAllowed || b.OverlapAllowed {
+		return false
+	}
+	return a.UserID == b.UserID && a.ServiceName == b.ServiceName && periodsOverlap(a, b)
+}
+
+// пересекаются ли периоды действия подписок (по тем же правилам, что и subscription_period)
+func periodsOverlap(a, b model.Subscription) bool {
+	aEnd, bEnd := periodEnd(a), periodEnd(b)
+	return (bEnd == nil || a.StartDate.Before(*bEnd)) && (aEnd == nil || b.StartDate.Before(*aEnd))
+}
+
+// первый день после окончания подписки: end_date при точности month означает весь месяц,
+// при точности day — включительно; nil у бессрочной. Некорректный период пуст
+func periodEnd(sub model.Subscription) *time.Time {
+	if sub.EndDate == nil {
+		return nil
+	}
+	end := monthStart(*sub.EndDate).AddDate(0, 1, 0)
+	if sub.DatePrecision == model.DatePrecisionDay {
+		end = sub.EndDate.AddDate(0, 0, 1)
+	}
+	if sub.EndDate.Before(sub.StartDate) {
+		end = sub.StartDate
+	}
+	return &end
+}
+
+// окончательно удалить подписки, мягко удаленные раньше before
+func (r *MemorySubscriptionRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	defer r.lock()()
+	var purged int64
+	for id, sub := range r.state.subscriptions {
+		if sub.DeletedAt != nil && sub.DeletedAt.Before(before) {
+			delete(r.state.subscriptions, id)
+			delete(r.state.prices, id)
+			delete(r.state.pauses, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// добавить событие истории; вызывается под блокировкой на запись
+func (r *MemorySubscriptionRepository) recordEvent(ctx context.Context, eventType string, subscriptionID uuid.UUID, before, after interface{}) error {
+	event, err := newEvent(ctx, eventType, subscriptionID, before, after)
+	if err != nil {
+		return err
+	}
+	event.ID = int64(len(r.state.events) + 1)
+	event.CreatedAt = time.Now()
+	r.state.events = append(r.state.events, *event)
+	return nil
+}
+
+// получить историю подписки в порядке возникновения событий
+func (r *MemorySubscriptionRepository) ListEvents(ctx context.Context, subscriptionID uuid.UUID) ([]model.SubscriptionEvent, error) {
+	defer r.rlock()()
+	events := make([]model.SubscriptionEvent, 0)
+	for _, event := range r.state.events {
+		if event.SubscriptionID == subscriptionID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// сохранить изменение цены с месяца change.EffectiveFrom (заменяет изменение с того же месяца)
+func (r *MemorySubscriptionRepository) SetPriceChange(ctx context.Context, subscriptionID uuid.UUID, change model.PriceChange) error {
+	defer r.lock()()
+	changes := r.state.prices[subscriptionID]
+	var before interface{}
+	i := sort.Search(len(changes), func(i int) bool { return !changes[i].EffectiveFrom.Before(change.EffectiveFrom) })
+	if i < len(changes) && changes[i].EffectiveFrom.Equal(change.EffectiveFrom) {
+		before = changes[i]
+		changes[i] = change
+	} else {
+		changes = append(changes, model.PriceChange{})
+		copy(changes[i+1:], changes[i:])
+		changes[i] = change
+	}
+	r.state.prices[subscriptionID] = changes
+	return r.recordEvent(ctx, model.EventPriceChanged, subscriptionID, before, change)
+}
+
+// получить изменения цены подписки в порядке effective_from
+func (r *MemorySubscriptionRepository) ListPriceChanges(ctx context.Context, subscriptionID uuid.UUID) ([]model.PriceChange, error) {
+	defer r.rlock()()
+	return append(make([]model.PriceChange, 0), r.state.prices[subscriptionID]...), nil
+}
+
+// начать приостановку подписки с месяца pausedFrom; версия подписки увеличивается
+func (r *MemorySubscriptionRepository) CreatePause(ctx context.Context, subscriptionID uuid.UUID, pausedFrom time.Time) error {
+	defer r.lock()()
+	pauses := r.state.pauses[subscriptionID]
+	if len(pauses) > 0 && pauses[len(pauses)-1].ResumedFrom == nil {
+		return fmt.Errorf("subscription %s already has an open pause", subscriptionID)
+	}
+	pause := model.Pause{PausedFrom: pausedFrom}
+	r.state.pauses[subscriptionID] = append(pauses, pause)
+	r.bumpVersion(subscriptionID)
+	return r.recordEvent(ctx, model.EventPaused, subscriptionID, nil, pause)
+}
+
+// завершить незавершенную приостановку подписки: подписка снова действует с месяца resumedFrom;
+// версия подписки увеличивается
+func (r *MemorySubscriptionRepository) ClosePause(ctx context.Context, subscriptionID uuid.UUID, resumedFrom time.Time) error {
+	defer r.lock()()
+	pauses := r.state.pauses[subscriptionID]
+	if len(pauses) == 0 || pauses[len(pauses)-1].ResumedFrom != nil {
+		return fmt.Errorf("subscription %s has no open pause: %w", subscriptionID, sql.ErrNoRows)
+	}
+	before := pauses[len(pauses)-1]
+	after := model.Pause{PausedFrom: before.PausedFrom, ResumedFrom: &resumedFrom}
+	pauses[len(pauses)-1] = after
+	r.bumpVersion(subscriptionID)
+	return r.recordEvent(ctx, model.EventResumed, subscriptionID, before, after)
+}
+
+// увеличить версию подписки после приостановки или возобновления; вызывается под блокировкой на запись
+func (r *MemorySubscriptionRepository) bumpVersion(subscriptionID uuid.UUID) {
+	if sub, ok := r.state.subscriptions[subscriptionID]; ok {
+		sub.Version++
+		r.state.subscriptions[subscriptionID] = sub
+	}
+}
+
+// получить приостановки подписки в порядке PausedFrom
+func (r *MemorySubscriptionRepository) ListPauses(ctx context.Context, subscriptionID uuid.UUID) ([]model.Pause, error) {
+	defer r.rlock()()
+	return append(make([]model.Pause, 0), r.state.pauses[subscriptionID]...), nil
+}
+
+// приостановлена ли подписка в месяце month; вызывается под блокировкой
+func (r *MemorySubscriptionRepository) pausedAt(subscriptionID uuid.UUID, month time.Time) bool {
+	for _, pause := range r.state.pauses[subscriptionID] {
+		if !pause.PausedFrom.After(month) && (pause.ResumedFrom == nil || pause.ResumedFrom.After(month)) {
+			return true
+		}
+	}
+	return false
+}
+
+// вычислить статус подписки на сегодня; вызывается под блокировкой
+func (r *MemorySubscriptionRepository) setStatus(sub *model.Subscription) {
+	sub.Status = subscriptionStatus(*sub, r.pausedAt(sub.ID, monthStart(time.Now())))
+}
+
+// цена подписки, действующая в месяце month (по тем же правилам, что и costRowsQuery)
+func (r *MemorySubscriptionRepository) priceAt(sub model.Subscription, month time.Time) int {
+	price := sub.Price
+	for _, change := range r.state.prices[sub.ID] {
+		if change.EffectiveFrom.After(month) {
+			break
+		}
+		if change.EffectiveFrom.After(monthStart(sub.StartDate)) {
+			price = change.Price
+		}
+	}
+	return price
+}
+
+// предоставить страницу подписок с фильтрами, сортировкой и keyset-пагинацией
+func (r *MemorySubscriptionRepository) List(ctx context.Context, filters model.ListFilter) (*model.SubscriptionPage, error) {
+	if _, ok := sortColumns[filters.SortBy]; !ok {
+		return nil, fmt.Errorf("unsupported sort column %q", filters.SortBy)
+	}
+	matched := r.sortedMatches(filters)
+	compare := listComparator(filters)
+	page := &model.SubscriptionPage{Items: make([]model.Subscription, 0, filters.Limit), Total: len(matched)}
+	for _, sub := range matched {
+		if filters.Cursor != nil && compare(sortValue(sub, filters.SortBy), sub.ID, filters.Cursor.Value, filters.Cursor.ID) <= 0 {
+			continue
+		}
+		page.Items = append(page.Items, sub)
+		if len(page.Items) > filters.Limit {
+			break
+		}
+	}
+	finishPage(page, filters)
+	return page, nil
+}
+
+// копии подписок, подходящих под фильтры списка, в порядке сортировки
+func (r *MemorySubscriptionRepository) sortedMatches(filters model.ListFilter) []model.Subscription {
+	unlock := r.rlock()
+	matched := make([]model.Subscription, 0, len(r.state.subscriptions))
+	for _, sub := range r.state.subscriptions {
+		if matchesListFilter(sub, filters) {
+			found := copySubscription(sub)
+			r.setStatus(&found)
+			matched = append(matched, found)
+		}
+	}
+	unlock()
+	compare := listComparator(filters)
+	sort.Slice(matched, func(i, j int) bool {
+		return compare(sortValue(matched[i], filters.SortBy), matched[i].ID, sortValue(matched[j], filters.SortBy), matched[j].ID) < 0
+	})
+	return matched
+}
+
+// сравнение пары (значение сортировки, id) в направлении сортировки
+func listComparator(filters model.ListFilter) func(value string, id uuid.UUID, otherValue string, otherID uuid.UUID) int {
+	return func(value string, id uuid.UUID, otherValue string, otherID uuid.UUID) int {
+		result := compareSortValues(filters.SortBy, value, otherValue)
+		if result == 0 {
+			result = strings.Compare(id.String(), otherID.String())
+		}
+		if filters.Desc {
+			result = -result
+		}
+		return result
+	}
+}
+
+// передать в fn все подписки по фильтрам списка в порядке сортировки
+func (r *MemorySubscriptionRepository) Export(ctx context.Context, filters model.ListFilter, fn func(sub *model.Subscription) error) error {
+	if _, ok := sortColumns[filters.SortBy]; !ok {
+		return fmt.Errorf("unsupported sort column %q", filters.SortBy)
+	}
+	for _, sub := range r.sortedMatches(filters) {
+		if err := fn(&sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// проверить подписку на соответствие фильтрам списка
+func matchesListFilter(sub model.Subscription, filters model.ListFilter) bool {
+	if sub.DeletedAt != nil && !filters.IncludeDeleted {
+		return false
+	}
+	if filters.UserID != nil && sub.UserID != *filters.UserID {
+		return false
+	}
+	if filters.ServiceName != "" && sub.ServiceName != filters.ServiceName {
+		return false
+	}
+	if filters.PriceMin != nil && sub.Price < *filters.PriceMin {
+		return false
+	}
+	if filters.PriceMax != nil && sub.Price > *filters.PriceMax {
+		return false
+	}
+	if filters.ActiveAt != nil {
+		if monthStart(sub.StartDate).After(*filters.ActiveAt) || (sub.EndDate != nil && sub.EndDate.Before(*filters.ActiveAt)) {
+			return false
+		}
+	}
+	if filters.OpenEnded != nil && *filters.OpenEnded != (sub.EndDate == nil) {
+		return false
+	}
+	return true
+}
+
+// подсчитать суммарную стоимость подписок по заданным фильтрам
+func (r *MemorySubscriptionRepository) GetTotalCost(ctx context.Context, filters model.CostFilter) (int, error) {
+	rows, err := r.costRows(filters)
+	if err != nil {
+		return 0, err
+	}
+	total := 0.0
+	for _, row := range rows {
+		total += row.cost
+	}
+	return roundCost(total), nil
+}
+
+// получить помесячную стоимость и счетчики подписок; месяцы без активных подписок не возвращаются
+func (r *MemorySubscriptionRepository) GetCostTimeSeries(ctx context.Context, filters model.CostFilter) ([]model.CostBucket, error) {
+	byMonth := make(map[time.Time]*model.CostBucket)
+	costs := make(map[time.Time]float64)
+	rows, err := r.costRows(filters)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		bucket, ok := byMonth[row.month]
+		if !ok {
+			bucket = &model.CostBucket{Period: row.month}
+			byMonth[row.month] = bucket
+		}
+		costs[row.month] += row.cost
+		bucket.ActiveCount++
+		if row.month.Equal(monthStart(row.sub.StartDate)) {
+			bucket.NewCount++
+		}
+		if row.sub.EndDate != nil && row.month.Equal(monthStart(*row.sub.EndDate)) {
+			bucket.EndedCount++
+		}
+	}
+	buckets := make([]model.CostBucket, 0, len(byMonth))
+	for month, bucket := range byMonth {
+		bucket.TotalCost = roundCost(costs[month])
+		buckets = append(buckets, *bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Period.Before(buckets[j].Period) })
+	return buckets, nil
+}
+
+// подсчитать стоимость и число подписок в разрезе измерений groupBy
+func (r *MemorySubscriptionRepository) GetGroupedCost(ctx context.Context, filters model.CostFilter, groupBy []string) ([]model.CostGroup, error) {
+	type groupState struct {
+		group model.CostGroup
+		cost  float64
+		ids   map[uuid.UUID]bool
+	}
+	states := make(map[string]*groupState)
+	rows, err := r.costRows(filters)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		key := make(map[string]string, len(groupBy))
+		composite := ""
+		for _, dimension := range groupBy {
+			var value string
+			switch dimension {
+			case model.GroupByServiceName:
+				value = row.sub.ServiceName
+			case model.GroupByUserID:
+				value = row.sub.UserID.String()
+			case model.GroupByMonth:
+				value = row.month.Format("01-2006")
+			default:
+				return nil, fmt.Errorf("unsupported group by dimension %q", dimension)
+			}
+			key[dimension] = value
+			composite += value + "\x00"
+		}
+		state, ok := states[composite]
+		if !ok {
+			state = &groupState{group: model.CostGroup{Key: key}, ids: make(map[uuid.UUID]bool)}
+			states[composite] = state
+		}
+		state.cost += row.cost
+		if !state.ids[row.sub.ID] {
+			state.ids[row.sub.ID] = true
+			state.group.Count++
+		}
+	}
+	groups := make([]model.CostGroup, 0, len(states))
+	for _, state := range states {
+		state.group.TotalCost = roundCost(state.cost)
+		groups = append(groups, state.group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		for _, dimension := range groupBy {
+			a, b := groups[i].Key[dimension], groups[j].Key[dimension]
+			if dimension == model.GroupByMonth {
+				a, b = a[3:]+a[:2], b[3:]+b[:2]
+			}
+			if a != b {
+				return a < b
+			}
+		}
+		return false
+	})
+	return groups, nil
+}
+
+// строка стоимости: вклад подписки в конкретный месяц
+type memoryCostRow struct {
+	sub   model.Subscription
+	month time.Time
+	cost  float64
+}
+
+// развернуть подписки в строки стоимости по тем же правилам, что и costRowsQuery;
+// ErrMissingRate, если строки дает подписка в валюте без курса пересчета
+func (r *MemorySubscriptionRepository) costRows(filters model.CostFilter) ([]memoryCostRow, error) {
+	defer r.rlock()()
+	currentMonth := monthStart(time.Now())
+	from, to := periodBounds(filters)
+	rows := make([]memoryCostRow, 0)
+	missing := make(map[string]bool)
+	for _, sub := range r.state.subscriptions {
+		if sub.DeletedAt != nil {
+			continue
+		}
+		rate, hasRate := 1.0, true
+		if filters.Rates != nil {
+			rate, hasRate = filters.Rates[sub.Currency]
+		}
+		if filters.UserID != nil && sub.UserID != *filters.UserID {
+			continue
+		}
+		if filters.ServiceName != "" && sub.ServiceName != filters.ServiceName {
+			continue
+		}
+		if filters.Mode == model.CostModeStartDate {
+			if from != nil && sub.StartDate.Before(*from) {
+				continue
+			}
+			if to != nil && sub.StartDate.After(*to) {
+				continue
+			}
+			factor := 1.0
+			if filters.Basis != model.BasisCharged {
+				factor = billingFactor(sub, monthStart(sub.StartDate), filters.Basis)
+			}
+			if r.pausedAt(sub.ID, monthStart(sub.StartDate)) {
+				continue
+			}
+			if !hasRate {
+				missing[sub.Currency] = true
+				continue
+			}
+			rows = append(rows, memoryCostRow{sub: sub, month: monthStart(sub.StartDate), cost: float64(sub.Price) * factor * rate})
+			continue
+		}
+		lower := monthStart(sub.StartDate)
+		if filters.From != nil && filters.From.After(lower) {
+			lower = *filters.From
+		}
+		upper := currentMonth
+		if sub.EndDate != nil {
+			upper = monthStart(*sub.EndDate)
+		}
+		if filters.To != nil && filters.To.Before(upper) {
+			upper = *filters.To
+		}
+		for month := lower; !month.After(upper); month = month.AddDate(0, 1, 0) {
+			if r.pausedAt(sub.ID, month) {
+				continue
+			}
+			factor := billingFactor(sub, month, filters.Basis)
+			if filters.Proration == model.ProrationDay {
+				factor *= dayFraction(sub, month, from, to)
+			}
+			if !hasRate {
+				missing[sub.Currency] = true
+				continue
+			}
+			rows = append(rows, memoryCostRow{sub: sub, month: month, cost: float64(r.priceAt(sub, month)) * factor * rate})
+		}
+	}
+	if len(missing) > 0 {
+		currencies := make([]string, 0, len(missing))
+		for currency := range missing {
+			currencies = append(currencies, currency)
+		}
+		return nil, missingRateError(currencies)
+	}
+	return rows, nil
+}
+
+// множитель цены подписки для месяца month (по тем же правилам, что и billingFactorExpr)
+func billingFactor(sub model.Subscription, month time.Time, basis string) float64 {
+	if basis != model.BasisCharged {
+		switch sub.BillingPeriod {
+		case model.BillingWeekly:
+			return 52.0 / 12
+		case model.BillingQuarterly:
+			return 1.0 / 3
+		case model.BillingYearly:
+			return 1.0 / 12
+		}
+		return 1
+	}
+	monthsFromStart := (month.Year()-sub.StartDate.Year())*12 + int(month.Month()) - int(sub.StartDate.Month())
+	switch sub.BillingPeriod {
+	case model.BillingWeekly:
+		start := time.Date(sub.StartDate.Year(), sub.StartDate.Month(), sub.StartDate.Day(), 0, 0, 0, 0, time.UTC)
+		d0 := int(month.Sub(start).Hours() / 24)
+		d1 := int(month.AddDate(0, 1, 0).Sub(start).Hours() / 24)
+		first := 0
+		if d0 > 0 {
+			first = (d0 + 6) / 7
+		}
+		last := int(math.Floor(float64(d1-1) / 7))
+		if last < first {
+			return 0
+		}
+		return float64(last - first + 1)
+	case model.BillingQuarterly:
+		if monthsFromStart%3 != 0 {
+			return 0
+		}
+	case model.BillingYearly:
+		if monthsFromStart%12 != 0 {
+			return 0
+		}
+	}
+	return 1
+}
+
+// доля дней месяца month, в которые подписка активна внутри [from, to] (по тем же правилам, что и dayFractionExpr)
+func dayFraction(sub model.Subscription, month time.Time, from, to *time.Time) float64 {
+	monthEnd := month.AddDate(0, 1, -1)
+	first, last := month, monthEnd
+	if sub.StartDate.After(first) {
+		first = sub.StartDate
+	}
+	if from != nil && from.After(first) {
+		first = *from
+	}
+	if sub.DatePrecision == model.DatePrecisionDay && sub.EndDate != nil && sub.EndDate.Before(last) {
+		last = *sub.EndDate
+	}
+	if to != nil && to.Before(last) {
+		last = *to
+	}
+	days := int(last.Sub(first).Hours()/24) + 1
+	if days <= 0 {
+		return 0
+	}
+	return float64(days) / float64(monthEnd.Day())
+}
+
+// сохранить новую запись каталога; название и синонимы не должны быть заняты другой записью
+func (r *MemorySubscriptionRepository) CreateService(ctx context.Context, service *model.Service) error {
+	defer r.lock()()
+	if r.serviceNamesTaken(*service) {
+		return fmt.Errorf("error creating service: %w", ErrServiceNameTaken)
+	}
+	service.ID = uuid.New()
+	service.CreatedAt = time.Now()
+	service.UpdatedAt = service.CreatedAt
+	r.saveService(*service)
+	return nil
+}
+
+// получить запись каталога по ID; nil, если её нет
+func (r *MemorySubscriptionRepository) GetService(ctx context.Context, id uuid.UUID) (*model.Service, error) {
+	defer r.rlock()()
+	service, ok := r.state.services[id]
+	if !ok {
+		return nil, nil
+	}
+	found := copyService(service)
+	return &found, nil
+}
+
+// найти запись каталога по названию или синониму; nil, если не найдена
+func (r *MemorySubscriptionRepository) ResolveService(ctx context.Context, name string) (*model.Service, error) {
+	defer r.rlock()()
+	id, ok := r.state.serviceIDs[ServiceKey(name)]
+	if !ok {
+		return nil, nil
+	}
+	found := copyService(r.state.services[id])
+	return &found, nil
+}
+
+// получить записи каталога в порядке названия; пустая category — все категории
+func (r *MemorySubscriptionRepository) ListServices(ctx context.Context, category string) ([]model.Service, error) {
+	defer r.rlock()()
+	services := make([]model.Service, 0, len(r.state.services))
+	for _, service := range r.state.services {
+		if category == "" || service.Category == category {
+			services = append(services, copyService(service))
+		}
+	}
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Name != services[j].Name {
+			return services[i].Name < services[j].Name
+		}
+		return services[i].ID.String() < services[j].ID.String()
+	})
+	return services, nil
+}
+
+// заменить поля и синонимы записи каталога
+func (r *MemorySubscriptionRepository) UpdateService(ctx context.Context, service *model.Service) error {
+	defer r.lock()()
+	existing, ok := r.state.services[service.ID]
+	if !ok {
+		return fmt.Errorf("update record not found: %w", sql.ErrNoRows)
+	}
+	if r.serviceNamesTaken(*service) {
+		return fmt.Errorf("error updating service: %w", ErrServiceNameTaken)
+	}
+	r.deleteServiceKeys(service.ID)
+	service.CreatedAt = existing.CreatedAt
+	service.UpdatedAt = time.Now()
+	r.saveService(*service)
+	return nil
+}
+
+// удалить запись каталога; подписки сохраняют название, но теряют ссылку на запись
+func (r *MemorySubscriptionRepository) DeleteService(ctx context.Context, id uuid.UUID) (bool, error) {
+	defer r.lock()()
+	if _, ok := r.state.services[id]; !ok {
+		return false, nil
+	}
+	delete(r.state.services, id)
+	r.deleteServiceKeys(id)
+	for subID, sub := range r.state.subscriptions {
+		if sub.ServiceID != nil && *sub.ServiceID == id {
+			sub.ServiceID = nil
+			r.state.subscriptions[subID] = sub
+		}
+	}
+	return true, nil
+}
+
+// получить названия сервисов из подписок с числом подписок
+func (r *MemorySubscriptionRepository) ListServiceNames(ctx context.Context) ([]model.ServiceMapping, error) {
+	defer r.rlock()()
+	counts := make(map[string]int)
+	for _, sub := range r.state.subscriptions {
+		counts[sub.ServiceName]++
+	}
+	names := make([]model.ServiceMapping, 0, len(counts))
+	for name, count := range counts {
+		names = append(names, model.ServiceMapping{ServiceName: name, Subscriptions: count})
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i].ServiceName < names[j].ServiceName })
+	return names, nil
+}
+
+// перевести подписки с названием serviceName на запись каталога; подписка, которая под каноническим
+// названием нарушила бы ограничение пересечений, помечается как допущенное пересечение
+func (r *MemorySubscriptionRepository) RemapSubscriptions(ctx context.Context, serviceName string, service *model.Service) (int64, int64, error) {
+	defer r.lock()()
+	var remapped, overlapAllowed int64
+	for id, sub := range r.state.subscriptions {
+		if sub.ServiceName != serviceName || (sub.ServiceName == service.Name && sub.ServiceID != nil && *sub.ServiceID == service.ID) {
+			continue
+		}
+		before := copySubscription(sub)
+		sub.ServiceName = service.Name
+		serviceID := service.ID
+		sub.ServiceID = &serviceID
+		if r.violatesOverlapConstraint(sub) {
+			sub.OverlapAllowed = true
+			overlapAllowed++
+		}
+		sub.Version++
+		r.state.subscriptions[id] = sub
+		after := copySubscription(sub)
+		r.setStatus(&after)
+		if err := r.recordEvent(ctx, model.EventUpdated, id, &before, &after); err != nil {
+			return 0, 0, err
+		}
+		remapped++
+	}
+	return remapped, overlapAllowed, nil
+}
+
+// заняты ли название или синонимы записи другой записью каталога; вызывается под блокировкой
+func (r *MemorySubscriptionRepository) serviceNamesTaken(service model.Service) bool {
+	for _, name := range append([]string{service.Name}, service.Aliases...) {
+		if id, ok := r.state.serviceIDs[ServiceKey(name)]; ok && id != service.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// сохранить запись каталога и её ключи поиска; вызывается под блокировкой на запись
+func (r *MemorySubscriptionRepository) saveService(service model.Service) {
+	service = copyService(service)
+	sort.Strings(service.Aliases)
+	r.state.services[service.ID] = service
+	for _, name := range append([]string{service.Name}, service.Aliases...) {
+		r.state.serviceIDs[ServiceKey(name)] = service.ID
+	}
+}
+
+// удалить ключи поиска записи каталога; вызывается под блокировкой на запись
+func (r *MemorySubscriptionRepository) deleteServiceKeys(id uuid.UUID) {
+	for key, serviceID := range r.state.serviceIDs {
+		if serviceID == id {
+			delete(r.state.serviceIDs, key)
+		}
+	}
+}
+
+// копия записи каталога без общих указателей и срезов с исходной
+func copyService(service model.Service) model.Service {
+	service.Aliases = append([]string{}, service.Aliases...)
+	if service.DefaultPrice != nil {
+		price := *service.DefaultPrice
+		service.DefaultPrice = &price
+	}
+	return service
+}
+
+// округлить стоимость так же, как ROUND(numeric) в PostgreSQL (половина — от нуля)
+func roundCost(cost float64) int {
+	return int(math.Round(cost))
+}
+
+// первое число месяца указанной даты
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// копия подписки без общих указателей с исходной
+func copySubscription(sub model.Subscription) model.Subscription {
+	if sub.EndDate != nil {
+		endDate := *sub.EndDate
+		sub.EndDate = &endDate
+	}
+	if sub.DeletedAt != nil {
+		deletedAt := *sub.DeletedAt
+		sub.DeletedAt = &deletedAt
+	}
+	if sub.ServiceID != nil {
+		serviceID := *sub.ServiceID
+		sub.ServiceID = &serviceID
+	}
+	// пересечения вычисляются при записи и не хранятся
+	sub.Overlaps = nil
+	return sub
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"effective-mobile-subscriptions/internal/model"
+)
+
+// найти действующие подписки того же пользователя на тот же сервис, период которых пересекается с периодом sub;
+// сама sub (по ID) не учитывается
+func (r *SubscriptionRepository) FindOverlapping(ctx context.Context, sub *model.Subscription) ([]model.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + `
+		FROM subscriptions s
+		WHERE s.user_id = $1
+			AND s.service_name = $2
+			AND s.id <> $3
+			AND s.deleted_at IS NULL
+			AND subscription_period(s.start_date, s.end_date, s.date_precision) && subscription_period($4::date, $5::date, $6)
+		ORDER BY s.start_date, s.id`
+	rows, err := r.DB.QueryContext(ctx, query, sub.UserID, sub.ServiceName, sub.ID, sub.StartDate, sub.EndDate, sub.DatePrecision)
+	if err != nil {
+		log.Printf("ERROR: Failed to execute overlapping subscriptions query: %v", err)
+		return nil, fmt.Errorf("failed to fetch overlapping subscriptions: %w", err)
+	}
+	defer rows.Close()
+	overlapping := make([]model.Subscription, 0)
+	for rows.Next() {
+		other := model.Subscription{}
+		if err := scanSubscription(rows, &other); err != nil {
+			return nil, fmt.Errorf("subscription string scanning error: %w", err)
+		}
+		overlapping = append(overlapping, other)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after iterating rows: %w", err)
+	}
+	return overlapping, nil
+}
+
+// найти пары пересекающихся действующих подписок; в паре первой идет подписка, начавшаяся раньше
+func (r *SubscriptionRepository) ListOverlaps(ctx context.Context, filters model.OverlapFilter) ([]model.SubscriptionOverlap, error) {
+	args := &queryArgs{}
+	where := ""
+	if filters.UserID != nil {
+		where += " AND a.user_id = " + args.add(*filters.UserID)
+	}
+	if filters.ServiceName != "" {
+		where += " AND a.service_name = " + args.add(filters.ServiceName)
+	}
+	query := fmt.Sprintf(`SELECT a.user_id, a.service_name, a.id, b.id, lower(ov.period), upper(ov.period)
+		FROM subscriptions a
+		JOIN subscriptions b ON b.user_id = a.user_id
+			AND b.service_name = a.service_name
+			AND (a.start_date, a.id) < (b.start_date, b.id)
+			AND subscription_period(a.start_date, a.end_date, a.date_precision) && subscription_period(b.start_date, b.end_date, b.date_precision)
+		CROSS JOIN LATERAL (
+			SELECT subscription_period(a.start_date, a.end_date, a.date_precision) * subscription_period(b.start_date, b.end_date, b.date_precision) AS period
+		) ov
+		WHERE a.deleted_at IS NULL AND b.deleted_at IS NULL%s
+		ORDER BY a.user_id, a.service_name, lower(ov.period), a.id, b.id
+		LIMIT %s`, where, args.add(filters.Limit))
+	rows, err := r.DB.QueryContext(ctx, query, args.values...)
+	if err != nil {
+		log.Printf("ERROR: Failed to execute overlaps report query: %v", err)
+		return nil, fmt.Errorf("failed to fetch subscription overlaps: %w", err)
+	}
+	defer rows.Close()
+	overlaps := make([]model.SubscriptionOverlap, 0)
+	for rows.Next() {
+		var overlap model.SubscriptionOverlap
+		var until *time.Time
+		if err := rows.Scan(&overlap.UserID, &overlap.ServiceName, &overlap.SubscriptionID, &overlap.OverlappingID, &overlap.OverlapStart, &until); err != nil {
+			return nil, fmt.Errorf("overlap string scanning error: %w", err)
+		}
+		if until != nil {
+			// верхняя граница диапазона не входит в него
+			lastDay := until.AddDate(0, 0, -1)
+			overlap.OverlapEnd = &lastDay
+		}
+		overlaps = append(overlaps, overlap)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after iterating rows: %w", err)
+	}
+	return overlaps, nil
+}
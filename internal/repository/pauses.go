@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"effective-mobile-subscriptions/internal/model"
+	"github.com/google/uuid"
+)
+
+// приостановлена ли подписка (таблица под алиасом s) в текущем месяце
+const pausedNowExpr = `EXISTS (SELECT 1 FROM subscription_pauses ps
+	WHERE ps.subscription_id = s.id AND ps.paused_from <= CURRENT_DATE AND (ps.resumed_from IS NULL OR ps.resumed_from > CURRENT_DATE))`
+
+// условие "месяц month не попадает в приостановку подписки s" для строк стоимости
+func notPausedExpr(month string) string {
+	return fmt.Sprintf(`NOT EXISTS (SELECT 1 FROM subscription_pauses ps
+		WHERE ps.subscription_id = s.id AND ps.paused_from <= %[1]s AND (ps.resumed_from IS NULL OR ps.resumed_from > %[1]s))`, month)
+}
+
+// статус подписки на сегодня: scheduled — еще не началась, ended — период закончился,
+// paused — приостановлена в текущем месяце, иначе active
+func subscriptionStatus(sub model.Subscription, paused bool) string {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if today.Before(sub.StartDate) {
+		return model.StatusScheduled
+	}
+	if end := periodEnd(sub); end != nil && !today.Before(*end) {
+		return model.StatusEnded
+	}
+	if paused {
+		return model.StatusPaused
+	}
+	return model.StatusActive
+}
+
+// начать приостановку подписки с месяца pausedFrom; версия подписки увеличивается,
+// событие paused пишется в той же транзакции
+func (r *SubscriptionRepository) CreatePause(ctx context.Context, subscriptionID uuid.UUID, pausedFrom time.Time) error {
+	return r.withTx(ctx, func(tx *SubscriptionRepository) error {
+		query := `INSERT INTO subscription_pauses (subscription_id, paused_from) VALUES ($1, $2)`
+		if _, err := tx.DB.ExecContext(ctx, query, subscriptionID, pausedFrom); err != nil {
+			log.Printf("ERROR: Failed to save pause for subscription %s: %v", subscriptionID, err)
+			return fmt.Errorf("error saving pause in DB: %w", err)
+		}
+		if err := tx.bumpVersion(ctx, subscriptionID); err != nil {
+			return err
+		}
+		return tx.recordEvent(ctx, model.EventPaused, subscriptionID, nil, model.Pause{PausedFrom: pausedFrom})
+	})
+}
+
+// завершить незавершенную приостановку подписки: подписка снова действует с месяца resumedFrom;
+// версия подписки увеличивается, событие resumed пишется в той же транзакции
+func (r *SubscriptionRepository) ClosePause(ctx context.Context, subscriptionID uuid.UUID, resumedFrom time.Time) error {
+	return r.withTx(ctx, func(tx *SubscriptionRepository) error {
+		before := model.Pause{}
+		query := `UPDATE subscription_pauses SET resumed_from = $2
+			WHERE subscription_id = $1 AND resumed_from IS NULL
+			RETURNING paused_from`
+		err := tx.DB.QueryRowContext(ctx, query, subscriptionID, resumedFrom).Scan(&before.PausedFrom)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("subscription %s has no open pause: %w", subscriptionID, err)
+			}
+			log.Printf("ERROR: Failed to close pause for subscription %s: %v", subscriptionID, err)
+			return fmt.Errorf("error closing pause in DB: %w", err)
+		}
+		if err := tx.bumpVersion(ctx, subscriptionID); err != nil {
+			return err
+		}
+		after := model.Pause{PausedFrom: before.PausedFrom, ResumedFrom: &resumedFrom}
+		return tx.recordEvent(ctx, model.EventResumed, subscriptionID, before, after)
+	})
+}
+
+// увеличить версию подписки: приостановка меняет её статус и стоимость, поэтому выданные ETag устаревают
+func (r *SubscriptionRepository) bumpVersion(ctx context.Context, subscriptionID uuid.UUID) error {
+	if _, err := r.DB.ExecContext(ctx, `UPDATE subscriptions SET version = version + 1 WHERE id = $1`, subscriptionID); err != nil {
+		log.Printf("ERROR: Failed to bump version of subscription %s: %v", subscriptionID, err)
+		return fmt.Errorf("error updating subscription version in DB: %w", err)
+	}
+	return nil
+}
+
+// получить приостановки подписки в порядке paused_from
+func (r *SubscriptionRepository) ListPauses(ctx context.Context, subscriptionID uuid.UUID) ([]model.Pause, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT paused_from, resumed_from
+		FROM subscription_pauses
+		WHERE subscription_id = $1
+		ORDER BY paused_from`, subscriptionID)
+	if err != nil {
+		log.Printf("ERROR: Failed to execute pauses query for ID %s: %v", subscriptionID, err)
+		return nil, fmt.Errorf("failed to fetch pauses from DB: %w", err)
+	}
+	defer rows.Close()
+	pauses := make([]model.Pause, 0)
+	for rows.Next() {
+		pause := model.Pause{}
+		if err := rows.Scan(&pause.PausedFrom, &pause.ResumedFrom); err != nil {
+			return nil, fmt.Errorf("pause scanning error: %w", err)
+		}
+		pauses = append(pauses, pause)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after iterating rows: %w", err)
+	}
+	return pauses, nil
+}
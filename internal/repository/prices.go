@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"effective-mobile-subscriptions/internal/model"
+	"github.com/google/uuid"
+)
+
+// сохранить изменение цены с месяца change.EffectiveFrom (заменяет изменение с того же месяца);
+// событие price_changed пишется в той же транзакции
+func (r *SubscriptionRepository) SetPriceChange(ctx context.Context, subscriptionID uuid.UUID, change model.PriceChange) error {
+	return r.withTx(ctx, func(tx *SubscriptionRepository) error {
+		var before interface{}
+		previous := model.PriceChange{}
+		err := tx.DB.QueryRowContext(ctx, `SELECT effective_from, price
+			FROM subscription_prices
+			WHERE subscription_id = $1 AND effective_from = $2
+			FOR UPDATE`, subscriptionID, change.EffectiveFrom).Scan(&previous.EffectiveFrom, &previous.Price)
+		switch {
+		case err == nil:
+			before = previous
+		case !errors.Is(err, sql.ErrNoRows):
+			log.Printf("ERROR: Failed to read price change for subscription %s: %v", subscriptionID, err)
+			return fmt.Errorf("error reading price change from DB: %w", err)
+		}
+		query := `INSERT INTO subscription_prices (subscription_id, effective_from, price)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (subscription_id, effective_from) DO UPDATE SET price = EXCLUDED.price, created_at = NOW()`
+		if _, err := tx.DB.ExecContext(ctx, query, subscriptionID, change.EffectiveFrom, change.Price); err != nil {
+			log.Printf("ERROR: Failed to save price change for subscription %s: %v", subscriptionID, err)
+			return fmt.Errorf("error saving price change in DB: %w", err)
+		}
+		return tx.recordEvent(ctx, model.EventPriceChanged, subscriptionID, before, change)
+	})
+}
+
+// получить изменения цены подписки в порядке effective_from
+func (r *SubscriptionRepository) ListPriceChanges(ctx context.Context, subscriptionID uuid.UUID) ([]model.PriceChange, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT effective_from, price
+		FROM subscription_prices
+		WHERE subscription_id = $1
+		ORDER BY effective_from`, subscriptionID)
+	if err != nil {
+		log.Printf("ERROR: Failed to execute price changes query for ID %s: %v", subscriptionID, err)
+		return nil, fmt.Errorf("failed to fetch price changes from DB: %w", err)
+	}
+	defer rows.Close()
+	changes := make([]model.PriceChange, 0)
+	for rows.Next() {
+		change := model.PriceChange{}
+		if err := rows.Scan(&change.EffectiveFrom, &change.Price); err != nil {
+			return nil, fmt.Errorf("price change scanning error: %w", err)
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after iterating rows: %w", err)
+	}
+	return changes, nil
+}
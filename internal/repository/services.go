@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"effective-mobile-subscriptions/internal/model"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// запись каталога с синонимами; каноническое название хранится среди синонимов, но в Aliases не попадает
+const serviceSelect = `SELECT sv.id, sv.name, sv.category, sv.default_price, sv.currency, sv.created_at, sv.updated_at,
+		COALESCE(array_agg(a.alias ORDER BY a.alias) FILTER (WHERE a.alias <> sv.name), '{}')
+	FROM services sv
+	LEFT JOIN service_aliases a ON a.service_id = sv.id`
+
+// нормализованное название сервиса, по которому ищется запись каталога: нижний регистр, одиночные пробелы
+func ServiceKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// прочитать строку serviceSelect
+func scanService(row rowScanner, service *model.Service) error {
+	var currency sql.NullString
+	var aliases []string
+	if err := row.Scan(&service.ID, &service.Name, &service.Category, &service.DefaultPrice, &currency,
+		&service.CreatedAt, &service.UpdatedAt, pq.Array(&aliases)); err != nil {
+		return err
+	}
+	service.Currency = currency.String
+	service.Aliases = aliases
+	return nil
+}
+
+// сохранить новую запись каталога с синонимами и заполнить её ID и время создания
+func (r *SubscriptionRepository) CreateService(ctx context.Context, service *model.Service) error {
+	return r.withTx(ctx, func(tx *SubscriptionRepository) error {
+		query := `INSERT INTO services (name, category, default_price, currency)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, created_at, updated_at`
+		err := tx.DB.QueryRowContext(ctx, query, service.Name, service.Category, service.DefaultPrice, nullString(service.Currency)).
+			Scan(&service.ID, &service.CreatedAt, &service.UpdatedAt)
+		if err != nil {
+			log.Printf("ERROR: Failed to execute INSERT query for service %q: %v", service.Name, err)
+			return fmt.Errorf("error creating service in DB: %w", err)
+		}
+		return tx.saveServiceAliases(ctx, service)
+	})
+}
+
+// записать каноническое название и синонимы записи каталога
+func (r *SubscriptionRepository) saveServiceAliases(ctx context.Context, service *model.Service) error {
+	names := append([]string{service.Name}, service.Aliases...)
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = ServiceKey(name)
+	}
+	query := `INSERT INTO service_aliases (alias_key, service_id, alias)
+		SELECT t.alias_key, $1, t.alias FROM unnest($2::text[], $3::text[]) AS t(alias_key, alias)`
+	if _, err := r.DB.ExecContext(ctx, query, service.ID, pq.Array(keys), pq.Array(names)); err != nil {
+		log.Printf("ERROR: Failed to save aliases of service %s: %v", service.ID, err)
+		return fmt.Errorf("error saving service aliases in DB: %w", translateError(err))
+	}
+	return nil
+}
+
+// получить запись каталога по ID; nil, если её нет
+func (r *SubscriptionRepository) GetService(ctx context.Context, id uuid.UUID) (*model.Service, error) {
+	return r.queryService(ctx, serviceSelect+` WHERE sv.id = $1 GROUP BY sv.id`, id)
+}
+
+// найти запись каталога по названию или синониму; nil, если не найдена
+func (r *SubscriptionRepository) ResolveService(ctx context.Context, name string) (*model.Service, error) {
+	return r.queryService(ctx, serviceSelect+`
+		WHERE sv.id = (SELECT service_id FROM service_aliases WHERE alias_key = $1)
+		GROUP BY sv.id`, ServiceKey(name))
+}
+
+// выполнить запрос одной записи каталога
+func (r *SubscriptionRepository) queryService(ctx context.Context, query string, arg interface{}) (*model.Service, error) {
+	service := &model.Service{}
+	if err := scanService(r.DB.QueryRowContext(ctx, query, arg), service); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		log.Printf("ERROR: Failed to execute service query for %v: %v", arg, err)
+		return nil, fmt.Errorf("error receiving service from DB: %w", err)
+	}
+	return service, nil
+}
+
+// получить записи каталога в порядке названия; пустая category — все категории
+func (r *SubscriptionRepository) ListServices(ctx context.Context, category string) ([]model.Service, error) {
+	args := &queryArgs{}
+	where := ""
+	if category != "" {
+		where = " WHERE sv.category = " + args.add(category)
+	}
+	rows, err := r.DB.QueryContext(ctx, serviceSelect+where+` GROUP BY sv.id ORDER BY sv.name, sv.id`, args.values...)
+	if err != nil {
+		log.Printf("ERROR: Failed to execute services list query: %v", err)
+		return nil, fmt.Errorf("failed to fetch services from DB: %w", err)
+	}
+	defer rows.Close()
+	services := make([]model.Service, 0)
+	for rows.Next() {
+		service := model.Service{}
+		if err := scanService(rows, &service); err != nil {
+			return nil, fmt.Errorf("service string scanning error: %w", err)
+		}
+		services = append(services, service)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after iterating rows: %w", err)
+	}
+	return services, nil
+}
+
+// заменить поля и синонимы записи каталога
+func (r *SubscriptionRepository) UpdateService(ctx context.Context, service *model.Service) error {
+	return r.withTx(ctx, func(tx *SubscriptionRepository) error {
+		query := `UPDATE services SET
+				name = $2,
+				category = $3,
+				default_price = $4,
+				currency = $5,
+				updated_at = NOW()
+			WHERE id = $1
+			RETURNING created_at, updated_at`
+		err := tx.DB.QueryRowContext(ctx, query, service.ID, service.Name, service.Category, service.DefaultPrice, nullString(service.Currency)).
+			Scan(&service.CreatedAt, &service.UpdatedAt)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("update record not found: %w", err)
+			}
+			log.Printf("ERROR: Failed to execute UPDATE query for service %s: %v", service.ID, err)
+			return fmt.Errorf("error updating service in DB: %w", err)
+		}
+		if _, err := tx.DB.ExecContext(ctx, `DELETE FROM service_aliases WHERE service_id = $1`, service.ID); err != nil {
+			log.Printf("ERROR: Failed to delete aliases of service %s: %v", service.ID, err)
+			return fmt.Errorf("error replacing service aliases in DB: %w", err)
+		}
+		return tx.saveServiceAliases(ctx, service)
+	})
+}
+
+// удалить запись каталога; подписки сохраняют название, но теряют ссылку на запись
+func (r *SubscriptionRepository) DeleteService(ctx context.Context, id uuid.UUID) (bool, error) {
+	result, err := r.DB.ExecContext(ctx, `DELETE FROM services WHERE id = $1`, id)
+	if err != nil {
+		log.Printf("ERROR: Failed to execute DELETE query for service %s: %v", id, err)
+		return false, fmt.Errorf("error deleting service from DB: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error checking deleted service: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// получить названия сервисов из подписок с числом подписок
+func (r *SubscriptionRepository) ListServiceNames(ctx context.Context) ([]model.ServiceMapping, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT service_name, COUNT(*)
+		FROM subscriptions
+		GROUP BY service_name
+		ORDER BY service_name`)
+	if err != nil {
+		log.Printf("ERROR: Failed to execute service names query: %v", err)
+		return nil, fmt.Errorf("failed to fetch service names from DB: %w", err)
+	}
+	defer rows.Close()
+	names := make([]model.ServiceMapping, 0)
+	for rows.Next() {
+		var mapping model.ServiceMapping
+		if err := rows.Scan(&mapping.ServiceName, &mapping.Subscriptions); err != nil {
+			return nil, fmt.Errorf("service name scanning error: %w", err)
+		}
+		names = append(names, mapping)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after iterating rows: %w", err)
+	}
+	return names, nil
+}
+
+// перевести подписки с названием serviceName на запись каталога. Подписка, которая под каноническим названием
+// нарушила бы excl_subscriptions_overlap, помечается как допущенное пересечение (как в миграции V11).
+// Подписки одного исходного названия не пересекаются между собой, поэтому одного запроса достаточно.
+// для каждой переведенной подписки в той же транзакции пишется событие updated
+func (r *SubscriptionRepository) RemapSubscriptions(ctx context.Context, serviceName string, service *model.Service) (int64, int64, error) {
+	var remapped, overlapAllowed int64
+	err := r.withTx(ctx, func(tx *SubscriptionRepository) error {
+		// прежние состояния для истории; строки блокируются до конца транзакции
+		query := `SELECT ` + subscriptionColumns + `
+			FROM subscriptions s
+			WHERE s.service_name = $1 AND (s.service_name <> $2 OR s.service_id IS DISTINCT FROM $3)
+			FOR UPDATE`
+		before, err := tx.querySubscriptions(ctx, query, serviceName, service.Name, service.ID)
+		if err != nil {
+			return err
+		}
+		if len(before) == 0 {
+			return nil
+		}
+		query = `UPDATE subscriptions s SET
+				service_name = $2,
+				service_id = $3,
+				overlap_allowed = s.overlap_allowed OR c.conflict,
+				version = s.version + 1
+			FROM (
+				SELECT x.id, x.deleted_at IS NULL AND NOT x.overlap_allowed AND EXISTS (
+					SELECT 1 FROM subscriptions o
+					WHERE o.user_id = x.user_id
+						AND o.service_name = $2
+						AND o.id <> x.id
+						AND o.deleted_at IS NULL
+						AND NOT o.overlap_allowed
+						AND subscription_period(o.start_date, o.end_date, o.date_precision) && subscription_period(x.start_date, x.end_date, x.date_precision)
+				) AS conflict
+				FROM subscriptions x
+				WHERE x.service_name = $1 AND (x.service_name <> $2 OR x.service_id IS DISTINCT FROM $3)
+			) c
+			WHERE s.id = c.id
+			RETURNING ` + subscriptionColumns
+		after, err := tx.querySubscriptions(ctx, query, serviceName, service.Name, service.ID)
+		if err != nil {
+			return err
+		}
+		previous := make(map[uuid.UUID]model.Subscription, len(before))
+		for _, sub := range before {
+			previous[sub.ID] = sub
+		}
+		events := make([]*model.SubscriptionEvent, 0, len(after))
+		for _, sub := range after {
+			prev := previous[sub.ID]
+			if sub.OverlapAllowed && !prev.OverlapAllowed {
+				overlapAllowed++
+			}
+			event, err := newEvent(ctx, model.EventUpdated, sub.ID, prev, sub)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		remapped = int64(len(after))
+		return tx.recordEvents(ctx, events)
+	})
+	if err != nil {
+		log.Printf("ERROR: Failed to remap subscriptions of %q to service %s: %v", serviceName, service.ID, err)
+		return 0, 0, fmt.Errorf("error remapping subscriptions in DB: %w", translateError(err))
+	}
+	return remapped, overlapAllowed, nil
+}
+
+// выполнить запрос, возвращающий столбцы subscriptionColumns, и прочитать все подписки
+func (r *SubscriptionRepository) querySubscriptions(ctx context.Context, query string, args ...interface{}) ([]model.Subscription, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	subs := make([]model.Subscription, 0)
+	for rows.Next() {
+		sub := model.Subscription{}
+		if err := scanSubscription(rows, &sub); err != nil {
+			return nil, fmt.Errorf("subscription string scanning error: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after iterating rows: %w", err)
+	}
+	return subs, nil
+}
+
+// пустая строка как NULL
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
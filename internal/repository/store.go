@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"effective-mobile-subscriptions/internal/model"
+	"github.com/google/uuid"
+)
+
+// основные операции с подписками: ядро, которое реализует любое хранилище
+type SubscriptionStore interface {
+	Create(ctx context.Context, sub *model.Subscription) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error)
+	// как GetByID, но блокирует подписку до конца транзакции
+	GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*model.Subscription, error)
+	Update(ctx context.Context, sub *model.Subscription) error
+	// мягкое удаление: подписка скрывается, но остается в хранилище до Purge
+	Delete(ctx context.Context, id uuid.UUID, expectedVersion *int) (bool, error)
+	List(ctx context.Context, filters model.ListFilter) (*model.SubscriptionPage, error)
+}
+
+// создание нескольких подписок одной операцией
+type BatchStore interface {
+	// создать несколько подписок: все или ни одной
+	CreateBatch(ctx context.Context, subs []*model.Subscription) error
+}
+
+// восстановление и окончательное удаление мягко удаленных подписок
+type SoftDeleteStore interface {
+	// вернуть мягко удаленную подписку; nil, если удаленной подписки с таким ID нет.
+	// check получает заблокированную подписку до восстановления: ошибка отменяет восстановление,
+	// а выставленный OverlapAllowed сохраняется
+	Restore(ctx context.Context, id uuid.UUID, check func(sub *model.Subscription) error) (*model.Subscription, error)
+	// окончательно удалить подписки, мягко удаленные раньше before
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// история изменений подписок
+type EventStore interface {
+	// история изменений подписки, включая удаленные
+	ListEvents(ctx context.Context, subscriptionID uuid.UUID) ([]model.SubscriptionEvent, error)
+}
+
+// изменения цены с указанного месяца
+type PriceStore interface {
+	// сохранить изменение цены с месяца change.EffectiveFrom
+	SetPriceChange(ctx context.Context, subscriptionID uuid.UUID, change model.PriceChange) error
+	ListPriceChanges(ctx context.Context, subscriptionID uuid.UUID) ([]model.PriceChange, error)
+}
+
+// приостановки подписок
+type PauseStore interface {
+	// начать приостановку подписки с месяца pausedFrom
+	CreatePause(ctx context.Context, subscriptionID uuid.UUID, pausedFrom time.Time) error
+	// завершить незавершенную приостановку: подписка снова действует с месяца resumedFrom
+	ClosePause(ctx context.Context, subscriptionID uuid.UUID, resumedFrom time.Time) error
+	// приостановки подписки в порядке paused_from
+	ListPauses(ctx context.Context, subscriptionID uuid.UUID) ([]model.Pause, error)
+}
+
+// поиск пересекающихся подписок
+type OverlapStore interface {
+	// действующие подписки того же пользователя на тот же сервис, период которых пересекается с периодом sub
+	FindOverlapping(ctx context.Context, sub *model.Subscription) ([]model.Subscription, error)
+	// пары пересекающихся действующих подписок для отчета
+	ListOverlaps(ctx context.Context, filters model.OverlapFilter) ([]model.SubscriptionOverlap, error)
+}
+
+// каталог сервисов; название и синонимы записи уникальны во всем каталоге (ErrServiceNameTaken)
+type ServiceCatalogStore interface {
+	CreateService(ctx context.Context, service *model.Service) error
+	GetService(ctx context.Context, id uuid.UUID) (*model.Service, error)
+	// записи каталога по названию; пустая category — все категории
+	ListServices(ctx context.Context, category string) ([]model.Service, error)
+	// заменить название, синонимы, категорию и цену по умолчанию записи каталога
+	UpdateService(ctx context.Context, service *model.Service) error
+	DeleteService(ctx context.Context, id uuid.UUID) (bool, error)
+	// найти запись каталога по названию или синониму (без учета регистра и лишних пробелов); nil, если не найдена
+	ResolveService(ctx context.Context, name string) (*model.Service, error)
+	// названия сервисов из подписок, включая удаленные, с числом подписок
+	ListServiceNames(ctx context.Context) ([]model.ServiceMapping, error)
+	// перевести подписки с названием serviceName на каноническое название и ID записи service;
+	// возвращает число переведенных подписок и число помеченных как допущенное пересечение
+	RemapSubscriptions(ctx context.Context, serviceName string, service *model.Service) (int64, int64, error)
+}
+
+// потоковая выгрузка подписок
+type ExportStore interface {
+	// передать в fn все подписки по фильтрам списка, не собирая их в память; ошибка fn прерывает выгрузку
+	Export(ctx context.Context, filters model.ListFilter, fn func(sub *model.Subscription) error) error
+}
+
+// аналитика стоимости подписок
+type AnalyticsStore interface {
+	GetTotalCost(ctx context.Context, filters model.CostFilter) (int, error)
+	GetCostTimeSeries(ctx context.Context, filters model.CostFilter) ([]model.CostBucket, error)
+	GetGroupedCost(ctx context.Context, filters model.CostFilter, groupBy []string) ([]model.CostGroup, error)
+}
+
+// ключи идемпотентности POST /subscriptions
+type IdempotencyStore interface {
+	// занять ключ идемпотентности внутри WithTx; возвращает запись, если ключ уже занят и не истек
+	ReserveIdempotencyKey(ctx context.Context, key model.IdempotencyKey) (*model.IdempotencyKey, error)
+	// сохранить ответ для занятого ключа
+	CompleteIdempotencyKey(ctx context.Context, key string, response json.RawMessage) error
+	// удалить ключи идемпотентности, истекшие раньше before
+	PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int64, error)
+}
+
+// хранилище со всеми возможностями, которые использует сервисный слой
+type Store interface {
+	SubscriptionStore
+	BatchStore
+	SoftDeleteStore
+	EventStore
+	PriceStore
+	PauseStore
+	OverlapStore
+	ServiceCatalogStore
+	ExportStore
+	AnalyticsStore
+	IdempotencyStore
+	// выполнить fn атомарно; все операции через tx входят в одну транзакцию
+	WithTx(ctx context.Context, fn func(tx Store) error) error
+}
+
+var (
+	_ Store = (*SubscriptionRepository)(nil)
+	_ Store = (*MemorySubscriptionRepository)(nil)
+)
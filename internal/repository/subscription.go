@@ -6,56 +6,143 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"effective-mobile-subscriptions/internal/model"
 	"github.com/google/uuid"
 )
 
+// общие методы *sql.DB и *sql.Tx, через которые репозиторий выполняет запросы
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
 // определяет методы для работы с бд
 type SubscriptionRepository struct {
-	DB *sql.DB
+	// *sql.DB или *sql.Tx внутри WithTx
+	DB DBTX
+	// пул соединений для открытия транзакций; nil у репозитория, привязанного к транзакции
+	pool *sql.DB
 }
 
 func NewSubscriptionRepository(db *sql.DB) *SubscriptionRepository {
-	return &SubscriptionRepository{DB: db}
+	return &SubscriptionRepository{DB: db, pool: db}
 }
 
-// сохранить новую подписку в бд и возвратить сгенерированные ID и CreatedAt
-func (r *SubscriptionRepository) Create(ctx context.Context, sub *model.Subscription) error {
-	query := `INSERT INTO subscriptions (user_id, service_name, price, start_date, end_date)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, created_at`
-	err := r.DB.QueryRowContext(
-		ctx,
-		query,
-		sub.UserID,
-		sub.ServiceName,
-		sub.Price,
-		sub.StartDate,
-		sub.EndDate,
-	).Scan(&sub.ID, &sub.CreatedAt)
+// выполнить fn в транзакции: fn получает репозиторий, привязанный к транзакции.
+// ошибка или паника в fn откатывает транзакцию; вложенный вызов выполняется в уже открытой транзакции
+func (r *SubscriptionRepository) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	return r.withTx(ctx, func(tx *SubscriptionRepository) error {
+		return fn(tx)
+	})
+}
+
+// WithTx для внутренних многошаговых операций репозитория (запись вместе с событием истории)
+func (r *SubscriptionRepository) withTx(ctx context.Context, fn func(tx *SubscriptionRepository) error) (err error) {
+	if r.pool == nil {
+		return fn(r)
+	}
+	tx, err := r.pool.BeginTx(ctx, nil)
 	if err != nil {
-		log.Printf("FATAL DB ERROR: Failed to execute INSERT query for new subscription: %v", err)
-		return fmt.Errorf("error creating subscription in DB: %w", err)
+		log.Printf("ERROR: Failed to begin transaction: %v", err)
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				log.Printf("ERROR: Failed to roll back transaction: %v", rollbackErr)
+			}
+		}
+	}()
+	if err = fn(&SubscriptionRepository{DB: tx}); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("ERROR: Failed to commit transaction: %v", err)
+		return fmt.Errorf("error committing transaction: %w", err)
 	}
 	return nil
 }
 
-// извлечь подписку из бд по её UUID
-func (r *SubscriptionRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
-	query := `SELECT id, user_id, service_name, price, start_date, end_date, created_at
-	          FROM subscriptions
-		      WHERE id = $1`
-	sub := &model.Subscription{}
-	err := r.DB.QueryRowContext(ctx, query, id).Scan(
+// столбцы подписки (таблица под алиасом s) и признак приостановки для статуса в порядке, который ожидает scanSubscription
+const subscriptionColumns = `s.id, s.user_id, s.service_name, s.price, s.currency, s.billing_period, s.start_date, s.end_date, s.date_precision, s.created_at, s.version, s.deleted_at, s.overlap_allowed, s.service_id, ` + pausedNowExpr
+
+// общий интерфейс *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// прочитать строку со столбцами subscriptionColumns и вычислить статус
+func scanSubscription(row rowScanner, sub *model.Subscription) error {
+	var paused bool
+	err := row.Scan(
 		&sub.ID,
 		&sub.UserID,
 		&sub.ServiceName,
 		&sub.Price,
+		&sub.Currency,
+		&sub.BillingPeriod,
 		&sub.StartDate,
 		&sub.EndDate,
+		&sub.DatePrecision,
 		&sub.CreatedAt,
+		&sub.Version,
+		&sub.DeletedAt,
+		&sub.OverlapAllowed,
+		&sub.ServiceID,
+		&paused,
 	)
+	if err != nil {
+		return err
+	}
+	sub.Status = subscriptionStatus(*sub, paused)
+	return nil
+}
+
+// сохранить новую подписку в бд и возвратить сгенерированные ID, CreatedAt и Version;
+// событие created пишется в той же транзакции
+func (r *SubscriptionRepository) Create(ctx context.Context, sub *model.Subscription) error {
+	return r.withTx(ctx, func(tx *SubscriptionRepository) error {
+		query := `INSERT INTO subscriptions (user_id, service_name, price, currency, billing_period, start_date, end_date, date_precision, overlap_allowed, service_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING id, created_at, version`
+		err := tx.DB.QueryRowContext(
+			ctx,
+			query,
+			sub.UserID,
+			sub.ServiceName,
+			sub.Price,
+			sub.Currency,
+			sub.BillingPeriod,
+			sub.StartDate,
+			sub.EndDate,
+			sub.DatePrecision,
+			sub.OverlapAllowed,
+			sub.ServiceID,
+		).Scan(&sub.ID, &sub.CreatedAt, &sub.Version)
+		if err != nil {
+			log.Printf("FATAL DB ERROR: Failed to execute INSERT query for new subscription: %v", err)
+			return fmt.Errorf("error creating subscription in DB: %w", translateError(err))
+		}
+		// у новой подписки нет приостановок
+		sub.Status = subscriptionStatus(*sub, false)
+		return tx.recordEvent(ctx, model.EventCreated, sub.ID, nil, sub)
+	})
+}
+
+// извлечь подписку из бд по её UUID; мягко удаленные подписки не возвращаются
+func (r *SubscriptionRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + `
+	          FROM subscriptions s
+		      WHERE s.id = $1 AND s.deleted_at IS NULL`
+	sub := &model.Subscription{}
+	err := scanSubscription(r.DB.QueryRowContext(ctx, query, id), sub)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -66,75 +153,185 @@ func (r *SubscriptionRepository) GetByID(ctx context.Context, id uuid.UUID) (*mo
 	return sub, nil
 }
 
-// обновить существующую подписку в бд
-func (r *SubscriptionRepository) Update(ctx context.Context, sub *model.Subscription) error {
-	query := `UPDATE subscriptions SET
-		    service_name = $2,
-			price = $3,
-			start_date = $4,
-			end_date = $5
-		    WHERE id = $1
-		    RETURNING created_at, user_id`
-	var tempUserID uuid.UUID
-	err := r.DB.QueryRowContext(
-		ctx,
-		query,
-		sub.ID,
-		sub.ServiceName,
-		sub.Price,
-		sub.StartDate,
-		sub.EndDate,
-	).Scan(&sub.CreatedAt, &tempUserID)
+// извлечь подписку и заблокировать её строку до конца транзакции (SELECT ... FOR UPDATE);
+// имеет смысл только внутри WithTx
+func (r *SubscriptionRepository) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + `
+		FROM subscriptions s
+		WHERE s.id = $1 AND s.deleted_at IS NULL
+		FOR UPDATE`
+	sub := &model.Subscription{}
+	err := scanSubscription(r.DB.QueryRowContext(ctx, query, id), sub)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("update record not found: %w", err)
+			return nil, nil
 		}
-		log.Printf("ERROR: Failed to execute UPDATE query for ID %s: %v", sub.ID, err)
-		return fmt.Errorf("error updating subscription in DB: %w", err)
+		log.Printf("ERROR: Failed to execute SELECT FOR UPDATE query for ID %s: %v", id, err)
+		return nil, fmt.Errorf("error locking subscription in DB: %w", err)
 	}
-	return nil
+	return sub, nil
+}
+
+// обновить существующую подписку в бд, если её версия не изменилась с момента чтения (sub.Version);
+// при успехе sub.Version увеличивается, а в историю пишется событие updated с состоянием до и после
+func (r *SubscriptionRepository) Update(ctx context.Context, sub *model.Subscription) error {
+	return r.withTx(ctx, func(tx *SubscriptionRepository) error {
+		before, err := tx.lockForWrite(ctx, sub.ID, &sub.Version)
+		if err != nil {
+			return err
+		}
+		if before == nil {
+			return fmt.Errorf("update record not found: %w", sql.ErrNoRows)
+		}
+		query := `UPDATE subscriptions s SET
+			    service_name = $2,
+				price = $3,
+				start_date = $4,
+				end_date = $5,
+				currency = $6,
+				billing_period = $7,
+				date_precision = $8,
+				overlap_allowed = $9,
+				service_id = $10,
+				version = version + 1
+			    WHERE s.id = $1
+			    RETURNING s.created_at, s.version, ` + pausedNowExpr
+		var paused bool
+		err = tx.DB.QueryRowContext(
+			ctx,
+			query,
+			sub.ID,
+			sub.ServiceName,
+			sub.Price,
+			sub.StartDate,
+			sub.EndDate,
+			sub.Currency,
+			sub.BillingPeriod,
+			sub.DatePrecision,
+			sub.OverlapAllowed,
+			sub.ServiceID,
+		).Scan(&sub.CreatedAt, &sub.Version, &paused)
+		if err != nil {
+			log.Printf("ERROR: Failed to execute UPDATE query for ID %s: %v", sub.ID, err)
+			return fmt.Errorf("error updating subscription in DB: %w", translateError(err))
+		}
+		sub.Status = subscriptionStatus(*sub, paused)
+		return tx.recordEvent(ctx, model.EventUpdated, sub.ID, before, sub)
+	})
+}
+
+// мягко удалить подписку: проставить deleted_at, строка остается в бд до очистки Purge.
+// если expectedVersion задан, удаление выполняется только при совпадении версии
+func (r *SubscriptionRepository) Delete(ctx context.Context, id uuid.UUID, expectedVersion *int) (bool, error) {
+	deleted := false
+	err := r.withTx(ctx, func(tx *SubscriptionRepository) error {
+		before, err := tx.lockForWrite(ctx, id, expectedVersion)
+		if err != nil || before == nil {
+			return err
+		}
+		query := `UPDATE subscriptions s SET
+				deleted_at = NOW(),
+				version = s.version + 1
+			WHERE s.id = $1
+			RETURNING ` + subscriptionColumns
+		after := &model.Subscription{}
+		if err := scanSubscription(tx.DB.QueryRowContext(ctx, query, id), after); err != nil {
+			log.Printf("ERROR: Failed to execute soft DELETE query for ID %s: %v", id, err)
+			return fmt.Errorf("error deleting subscription from DB: %w", err)
+		}
+		deleted = true
+		return tx.recordEvent(ctx, model.EventDeleted, id, before, after)
+	})
+	if err != nil {
+		return false, err
+	}
+	return deleted, nil
+}
+
+// восстановить мягко удаленную подписку; nil, если удаленной подписки с таким ID нет.
+// check вызывается с заблокированной подпиской до восстановления, признак overlap_allowed берется из неё
+func (r *SubscriptionRepository) Restore(ctx context.Context, id uuid.UUID, check func(sub *model.Subscription) error) (*model.Subscription, error) {
+	var restored *model.Subscription
+	err := r.withTx(ctx, func(tx *SubscriptionRepository) error {
+		before := &model.Subscription{}
+		query := `SELECT ` + subscriptionColumns + `
+			FROM subscriptions s
+			WHERE s.id = $1 AND s.deleted_at IS NOT NULL
+			FOR UPDATE`
+		if err := scanSubscription(tx.DB.QueryRowContext(ctx, query, id), before); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			log.Printf("ERROR: Failed to lock deleted subscription %s: %v", id, err)
+			return fmt.Errorf("error restoring subscription in DB: %w", err)
+		}
+		candidate := *before
+		if err := check(&candidate); err != nil {
+			return err
+		}
+		query = `UPDATE subscriptions s SET
+				deleted_at = NULL,
+				overlap_allowed = $2,
+				version = s.version + 1
+			WHERE s.id = $1
+			RETURNING ` + subscriptionColumns
+		after := &model.Subscription{}
+		if err := scanSubscription(tx.DB.QueryRowContext(ctx, query, id, candidate.OverlapAllowed), after); err != nil {
+			log.Printf("ERROR: Failed to execute RESTORE query for ID %s: %v", id, err)
+			return fmt.Errorf("error restoring subscription in DB: %w", translateError(err))
+		}
+		restored = after
+		return tx.recordEvent(ctx, model.EventRestored, id, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
 }
 
-// удалить подписку из бд по её ID
-func (r *SubscriptionRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
-	query := `DELETE FROM subscriptions WHERE id = $1`
-	result, err := r.DB.ExecContext(ctx, query, id)
+// окончательно удалить подписки, мягко удаленные раньше before; возвращает число удаленных строк.
+// история удаленных подписок сохраняется
+func (r *SubscriptionRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.DB.ExecContext(ctx, `DELETE FROM subscriptions WHERE deleted_at < $1`, before)
 	if err != nil {
-		log.Printf("ERROR: Failed to execute DELETE query for ID %s: %v", id, err)
-		return false, fmt.Errorf("error deleting subscription from DB: %w", err)
+		log.Printf("ERROR: Failed to execute PURGE query: %v", err)
+		return 0, fmt.Errorf("error purging deleted subscriptions from DB: %w", err)
 	}
-	rowsAffected, err := result.RowsAffected()
+	purged, err := result.RowsAffected()
 	if err != nil {
-		log.Printf("ERROR: Failed to check rows affected after DELETE for ID %s: %v", id, err)
-		return false, fmt.Errorf("error checking the number of deleted rows: %w", err)
+		return 0, fmt.Errorf("error checking the number of purged rows: %w", err)
+	}
+	return purged, nil
+}
+
+// заблокировать действующую подписку перед изменением и сверить версию; nil, если подписки нет
+func (r *SubscriptionRepository) lockForWrite(ctx context.Context, id uuid.UUID, expectedVersion *int) (*model.Subscription, error) {
+	current, err := r.GetByIDForUpdate(ctx, id)
+	if err != nil || current == nil {
+		return nil, err
+	}
+	if expectedVersion != nil && current.Version != *expectedVersion {
+		return nil, fmt.Errorf("subscription %s was modified concurrently: %w", id, ErrVersionConflict)
 	}
-	return rowsAffected > 0, nil
+	return current, nil
 }
 
-// предоставить весь список существующих подписок
-func (r *SubscriptionRepository) List(ctx context.Context) ([]model.Subscription, error) {
-	query := `SELECT id, user_id, service_name, price, start_date, end_date, created_at
-		FROM subscriptions
-		ORDER BY created_at DESC`
+// найти подписки, у которых end_date раньше start_date (нарушают chk_subscriptions_end_date)
+func (r *SubscriptionRepository) FindInvalidPeriods(ctx context.Context) ([]model.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + `
+		FROM subscriptions s
+		WHERE s.end_date < s.start_date
+		ORDER BY s.created_at`
 	rows, err := r.DB.QueryContext(ctx, query)
 	if err != nil {
-		log.Printf("ERROR: Failed to execute LIST query: %v", err)
-		return nil, fmt.Errorf("failed to fetch subscription list from DB: %w", err)
+		log.Printf("ERROR: Failed to execute invalid periods query: %v", err)
+		return nil, fmt.Errorf("failed to fetch subscriptions with invalid periods: %w", err)
 	}
 	defer rows.Close()
 	subscriptions := make([]model.Subscription, 0)
 	for rows.Next() {
 		sub := model.Subscription{}
-		err := rows.Scan(
-			&sub.ID,
-			&sub.UserID,
-			&sub.ServiceName,
-			&sub.Price,
-			&sub.StartDate,
-			&sub.EndDate,
-			&sub.CreatedAt,
-		)
-		if err != nil {
+		if err := scanSubscription(rows, &sub); err != nil {
 			return nil, fmt.Errorf("subscription string scanning error: %w", err)
 		}
 		subscriptions = append(subscriptions, sub)
@@ -144,44 +341,3 @@ func (r *SubscriptionRepository) List(ctx context.Context) ([]model.Subscription
 	}
 	return subscriptions, nil
 }
-
-// подсчитать суммарную стоимость подписок по заданным фильтрам
-func (r *SubscriptionRepository) GetTotalCost(ctx context.Context, filters model.CostAnalyticsRequest) (int, error) {
-	baseQuery := `SELECT SUM(price) FROM subscriptions WHERE 1=1`
-	args := []interface{}{}
-	argCounter := 1
-	if filters.UserID != "" {
-		baseQuery += fmt.Sprintf(" AND user_id = $%d", argCounter)
-		args = append(args, filters.UserID)
-		argCounter++
-	}
-	if filters.ServiceName != "" {
-		baseQuery += fmt.Sprintf(" AND service_name = $%d", argCounter)
-		args = append(args, filters.ServiceName)
-		argCounter++
-	}
-	if filters.StartDateStr != "" && filters.EndDateStr != "" {
-		baseQuery += fmt.Sprintf(" AND start_date BETWEEN $%d AND $%d", argCounter, argCounter+1)
-		args = append(args, filters.StartDateStr)
-		args = append(args, filters.EndDateStr)
-		argCounter += 2
-	} else if filters.StartDateStr != "" {
-		baseQuery += fmt.Sprintf(" AND start_date >= $%d", argCounter)
-		args = append(args, filters.StartDateStr)
-		argCounter++
-	} else if filters.EndDateStr != "" {
-		baseQuery += fmt.Sprintf(" AND start_date <= $%d", argCounter)
-		args = append(args, filters.EndDateStr)
-		argCounter++
-	}
-	var totalCost sql.NullInt64
-	err := r.DB.QueryRowContext(ctx, baseQuery, args...).Scan(&totalCost)
-	if err != nil {
-		log.Printf("ERROR: Failed to execute GetTotalCost analytics query: %v", err)
-		return 0, fmt.Errorf("error when executing an analytics request: %w", err)
-	}
-	if !totalCost.Valid {
-		return 0, nil
-	}
-	return int(totalCost.Int64), nil
-}
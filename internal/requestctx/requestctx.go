@@ -0,0 +1,38 @@
+// Package requestctx хранит в контексте данные текущего HTTP-запроса.
+package requestctx
+
+import "context"
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	actorKey
+)
+
+// автор изменений, если запрос его не указал
+const UnknownActor = "unknown"
+
+// вернуть контекст с идентификатором запроса
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// идентификатор текущего запроса или пустая строка
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// вернуть контекст с автором изменений (пользователь или система), который попадает в историю
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// автор изменений текущего запроса или UnknownActor
+func Actor(ctx context.Context) string {
+	if actor, _ := ctx.Value(actorKey).(string); actor != "" {
+		return actor
+	}
+	return UnknownActor
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"effective-mobile-subscriptions/internal/model"
+	"effective-mobile-subscriptions/internal/rates"
+	"effective-mobile-subscriptions/internal/repository"
+	"github.com/google/uuid"
+)
+
+// получить суммарную стоимость по фильтрам в валюте req.Currency (по умолчанию базовой); возвращает сумму и валюту
+func (s *SubscriptionService) GetCostAnalytics(ctx context.Context, req model.CostAnalyticsRequest) (int, string, error) {
+	filters, err := ParseCostFilter(req)
+	if err != nil {
+		return 0, "", err
+	}
+	currency, err := s.applyConversion(ctx, &filters, req.Currency)
+	if err != nil {
+		return 0, "", err
+	}
+	totalCost, err := s.Repo.GetTotalCost(ctx, filters)
+	if errors.Is(err, repository.ErrMissingRate) {
+		return 0, "", errMissingRate(currency, err)
+	}
+	if err != nil {
+		log.Printf("ERROR: GetCostAnalytics failed to execute total cost query in repository: %v", err)
+		return 0, "", fmt.Errorf("service error while receiving analytics: %w", err)
+	}
+	return totalCost, currency, nil
+}
+
+// множители пересчета в валюту target; ошибка валидации поля field, если курса для валюты нет
+func (s *SubscriptionService) conversionRates(ctx context.Context, field, target string) (map[string]float64, error) {
+	multipliers, err := s.Rates.Rates(ctx, target)
+	if errors.Is(err, rates.ErrUnknownCurrency) {
+		return nil, ValidationError(CodeUnsupportedCurrency, field, fmt.Sprintf("no exchange rate for currency %s", target))
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to get exchange rates for %s: %v", target, err)
+		return nil, fmt.Errorf("service error while receiving exchange rates: %w", err)
+	}
+	return multipliers, nil
+}
+
+// ошибка unsupported_currency: стоимость подписок в валютах без курса нельзя пересчитать в валюту результата,
+// а пропуск таких подписок исказил бы сумму
+func errMissingRate(currency string, err error) error {
+	return &Error{Kind: ErrUnprocessable, Code: CodeUnsupportedCurrency, Field: "currency",
+		Message: fmt.Sprintf("cannot convert cost to %s: %v", currency, err)}
+}
+
+// выбрать валюту результата аналитики (по умолчанию базовую) и добавить в фильтры курсы пересчета
+func (s *SubscriptionService) applyConversion(ctx context.Context, filters *model.CostFilter, currencyParam string) (string, error) {
+	currency := s.Rates.Base()
+	if currencyParam != "" {
+		var err error
+		if currency, err = parseCurrency("currency", currencyParam); err != nil {
+			return "", err
+		}
+	}
+	multipliers, err := s.conversionRates(ctx, "currency", currency)
+	if err != nil {
+		return "", err
+	}
+	filters.Rates = multipliers
+	return currency, nil
+}
+
+// провалидировать параметры аналитики и привести их к фильтрам репозитория
+func ParseCostFilter(req model.CostAnalyticsRequest) (model.CostFilter, error) {
+	filters := model.CostFilter{
+		ServiceName: req.ServiceName,
+		Mode:        req.Mode,
+		Basis:       req.Basis,
+		Proration:   req.Proration,
+	}
+	switch filters.Mode {
+	case "":
+		filters.Mode = model.CostModeProrated
+	case model.CostModeProrated, model.CostModeStartDate:
+	default:
+		return model.CostFilter{}, ValidationError(CodeInvalidParameter, "mode", fmt.Sprintf("incorrect mode (expected %s or %s)", model.CostModeProrated, model.CostModeStartDate))
+	}
+	switch filters.Basis {
+	case "":
+		filters.Basis = model.BasisMonthlyEquivalent
+	case model.BasisMonthlyEquivalent, model.BasisCharged:
+	default:
+		return model.CostFilter{}, ValidationError(CodeInvalidParameter, "basis", fmt.Sprintf("incorrect basis (expected %s or %s)", model.BasisMonthlyEquivalent, model.BasisCharged))
+	}
+	switch filters.Proration {
+	case "":
+		filters.Proration = model.ProrationMonth
+	case model.ProrationMonth:
+	case model.ProrationDay:
+		if filters.Mode != model.CostModeProrated || filters.Basis != model.BasisMonthlyEquivalent {
+			return model.CostFilter{}, ValidationError(CodeInvalidParameter, "proration", fmt.Sprintf("proration %s requires mode %s and basis %s", model.ProrationDay, model.CostModeProrated, model.BasisMonthlyEquivalent))
+		}
+	default:
+		return model.CostFilter{}, ValidationError(CodeInvalidParameter, "proration", fmt.Sprintf("incorrect proration (expected %s or %s)", model.ProrationMonth, model.ProrationDay))
+	}
+	if req.StartDateStr != "" {
+		startDate, _, err := ParseDate("start_date_from", req.StartDateStr)
+		if err != nil {
+			return model.CostFilter{}, err
+		}
+		from := monthStart(startDate)
+		filters.From = &from
+		filters.FromDate = &startDate
+	}
+	if req.EndDateStr != "" {
+		endDate, precision, err := ParseDate("start_date_to", req.EndDateStr)
+		if err != nil {
+			return model.CostFilter{}, err
+		}
+		if precision == model.DatePrecisionMonth {
+			endDate = monthEnd(endDate)
+		}
+		to := monthStart(endDate)
+		filters.To = &to
+		filters.ToDate = &endDate
+	}
+	if filters.FromDate != nil && filters.ToDate != nil && filters.FromDate.After(*filters.ToDate) {
+		return model.CostFilter{}, ValidationError(CodeInvalidPeriod, "start_date_from", "start_date_from cannot be after start_date_to")
+	}
+	if req.UserID != "" {
+		userID, err := uuid.Parse(req.UserID)
+		if err != nil {
+			return model.CostFilter{}, ValidationError(CodeInvalidUUID, "user_id", "incorrect format user_id (expected UUID)")
+		}
+		filters.UserID = &userID
+	}
+	return filters, nil
+}
+
+// максимальная длина временного ряда в месяцах
+const maxTimeSeriesMonths = 120
+
+// получить помесячный ряд стоимости с заполнением пустых месяцев.
+// по умолчанию ряд заканчивается текущим месяцем и охватывает последние 12 месяцев
+func (s *SubscriptionService) GetCostTimeSeries(ctx context.Context, req model.CostAnalyticsRequest) ([]model.CostBucket, error) {
+	req.Mode = model.CostModeProrated
+	filters, err := ParseCostFilter(req)
+	if err != nil {
+		return nil, err
+	}
+	if filters.To == nil {
+		now := time.Now().UTC()
+		to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if filters.From != nil && filters.From.After(to) {
+			to = *filters.From
+		}
+		filters.To = &to
+	}
+	if filters.From == nil {
+		from := filters.To.AddDate(0, -11, 0)
+		filters.From = &from
+	}
+	if monthsBetween(*filters.From, *filters.To) > maxTimeSeriesMonths {
+		return nil, ValidationError(CodeInvalidPeriod, "start_date_from", fmt.Sprintf("time series period cannot exceed %d months", maxTimeSeriesMonths))
+	}
+	currency, err := s.applyConversion(ctx, &filters, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+	found, err := s.Repo.GetCostTimeSeries(ctx, filters)
+	if errors.Is(err, repository.ErrMissingRate) {
+		return nil, errMissingRate(currency, err)
+	}
+	if err != nil {
+		log.Printf("ERROR: GetCostTimeSeries failed to execute time series query in repository: %v", err)
+		return nil, fmt.Errorf("service error while receiving time series: %w", err)
+	}
+	byMonth := make(map[string]model.CostBucket, len(found))
+	for _, bucket := range found {
+		byMonth[bucket.Period.Format(monthYearLayout)] = bucket
+	}
+	buckets := make([]model.CostBucket, 0, monthsBetween(*filters.From, *filters.To))
+	for month := *filters.From; !month.After(*filters.To); month = month.AddDate(0, 1, 0) {
+		label := month.Format(monthYearLayout)
+		bucket, ok := byMonth[label]
+		if !ok {
+			bucket = model.CostBucket{Period: month}
+		}
+		bucket.Month = label
+		bucket.Currency = currency
+		buckets = append(buckets, bucket)
+	}
+	return buckets, nil
+}
+
+// число месяцев в периоде [from, to] включительно
+func monthsBetween(from, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month()) + 1
+}
+
+// получить стоимость в разрезе измерений из group_by
+func (s *SubscriptionService) GetGroupedCostAnalytics(ctx context.Context, req model.CostAnalyticsRequest) ([]model.CostGroup, error) {
+	filters, err := ParseCostFilter(req)
+	if err != nil {
+		return nil, err
+	}
+	groupBy, err := ParseGroupBy(req.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+	currency, err := s.applyConversion(ctx, &filters, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.Repo.GetGroupedCost(ctx, filters, groupBy)
+	if errors.Is(err, repository.ErrMissingRate) {
+		return nil, errMissingRate(currency, err)
+	}
+	if err != nil {
+		log.Printf("ERROR: GetGroupedCostAnalytics failed to execute grouped query in repository: %v", err)
+		return nil, fmt.Errorf("service error while receiving grouped analytics: %w", err)
+	}
+	for i := range groups {
+		groups[i].Currency = currency
+	}
+	return groups, nil
+}
+
+// разобрать список измерений группировки через запятую
+func ParseGroupBy(value string) ([]string, error) {
+	groupBy := make([]string, 0, 3)
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ",") {
+		dimension := strings.TrimSpace(part)
+		switch dimension {
+		case model.GroupByServiceName, model.GroupByUserID, model.GroupByMonth:
+		default:
+			return nil, ValidationError(CodeInvalidParameter, "group_by", fmt.Sprintf("incorrect group_by %q (expected %s, %s or %s)",
+				dimension, model.GroupByServiceName, model.GroupByUserID, model.GroupByMonth))
+		}
+		if !seen[dimension] {
+			seen[dimension] = true
+			groupBy = append(groupBy, dimension)
+		}
+	}
+	return groupBy, nil
+}
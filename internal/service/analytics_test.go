@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"effective-mobile-subscriptions/internal/model"
+)
+
+func TestAnalyticsFailsOnCurrencyWithoutRate(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	svc.Rates = staticRates{"USD": 90, "EUR": 100}
+	mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Netflix", Price: 10, Currency: "EUR", StartDate: "01-2024", EndDate: strPtr("01-2024")})
+	mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Yandex Plus", Price: 400, StartDate: "01-2024", EndDate: strPtr("01-2024")})
+	// курс EUR убран из источника после создания подписки
+	svc.Rates = staticRates{"USD": 90}
+
+	req := model.CostAnalyticsRequest{StartDateStr: "01-2024", EndDateStr: "01-2024"}
+	_, _, err := svc.GetCostAnalytics(ctx, req)
+	if code := errorCode(err); code != CodeUnsupportedCurrency || !errors.Is(err, ErrUnprocessable) {
+		t.Fatalf("GetCostAnalytics: error %v (code %q), want %s", err, code, CodeUnsupportedCurrency)
+	}
+	if !strings.Contains(err.Error(), "EUR") {
+		t.Errorf("error %q does not name the currency without rate", err)
+	}
+	if _, err := svc.GetCostTimeSeries(ctx, req); errorCode(err) != CodeUnsupportedCurrency {
+		t.Errorf("GetCostTimeSeries: error %v, want %s", err, CodeUnsupportedCurrency)
+	}
+	req.GroupBy = model.GroupByServiceName
+	if _, err := svc.GetGroupedCostAnalytics(ctx, req); errorCode(err) != CodeUnsupportedCurrency {
+		t.Errorf("GetGroupedCostAnalytics: error %v, want %s", err, CodeUnsupportedCurrency)
+	}
+
+	// подписки без курса вне периода не мешают подсчету
+	total, _, err := svc.GetCostAnalytics(ctx, model.CostAnalyticsRequest{StartDateStr: "02-2024", EndDateStr: "02-2024"})
+	if err != nil || total != 0 {
+		t.Fatalf("GetCostAnalytics outside the period = %d, %v; want 0, nil", total, err)
+	}
+	total, _, err = svc.GetCostAnalytics(ctx, model.CostAnalyticsRequest{StartDateStr: "01-2024", EndDateStr: "01-2024", ServiceName: "Yandex Plus"})
+	if err != nil || total != 400 {
+		t.Fatalf("GetCostAnalytics for RUB subscription = %d, %v; want 400, nil", total, err)
+	}
+}
+
+// подписки для проверки аналитики: периоды в прошлом, чтобы результат не зависел от текущей даты
+func seedAnalytics(t *testing.T, svc *SubscriptionService) {
+	t.Helper()
+	mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Netflix", Price: 500, StartDate: "01-2024", EndDate: strPtr("03-2024")})
+	mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Spotify", Price: 10, Currency: "USD", StartDate: "02-2024", EndDate: strPtr("02-2024")})
+	mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Yandex Plus", Price: 1200, BillingPeriod: model.BillingYearly, StartDate: "01-2024", EndDate: strPtr("12-2025")})
+	mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Kinopoisk", Price: 300, BillingPeriod: model.BillingQuarterly, StartDate: "01-2024", EndDate: strPtr("06-2024")})
+	mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Gym", Price: 3100, StartDate: "2024-01-17", EndDate: strPtr("2024-03-10")})
+}
+
+func TestCostAnalytics(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	seedAnalytics(t, svc)
+
+	tests := []struct {
+		name string
+		req  model.CostAnalyticsRequest
+		want int
+	}{
+		{
+			name: "monthly subscription prorated by month",
+			req:  model.CostAnalyticsRequest{ServiceName: "Netflix", StartDateStr: "01-2024", EndDateStr: "12-2024"},
+			want: 3 * 500,
+		},
+		{
+			name: "partial months counted whole",
+			req:  model.CostAnalyticsRequest{ServiceName: "Gym", StartDateStr: "01-2024", EndDateStr: "12-2024"},
+			want: 3 * 3100,
+		},
+		{
+			// январь 17..31 (15/31), февраль целиком, март 1..10 (10/31)
+			name: "partial months prorated by day",
+			req:  model.CostAnalyticsRequest{ServiceName: "Gym", StartDateStr: "01-2024", EndDateStr: "12-2024", Proration: model.ProrationDay},
+			want: 1500 + 3100 + 1000,
+		},
+		{
+			name: "day proration clipped by the period",
+			req:  model.CostAnalyticsRequest{ServiceName: "Gym", StartDateStr: "2024-03-01", EndDateStr: "2024-03-05", Proration: model.ProrationDay},
+			want: 500,
+		},
+		{
+			name: "foreign currency converted to base",
+			req:  model.CostAnalyticsRequest{StartDateStr: "02-2024", EndDateStr: "02-2024", ServiceName: "Spotify"},
+			want: 900,
+		},
+		{
+			// (500 + 100 + 100 + 3100) RUB / 90 + 10 USD
+			name: "result in requested currency",
+			req:  model.CostAnalyticsRequest{StartDateStr: "02-2024", EndDateStr: "02-2024", Currency: "USD"},
+			want: 52,
+		},
+		{
+			name: "yearly price spread over months",
+			req:  model.CostAnalyticsRequest{ServiceName: "Yandex Plus", StartDateStr: "01-2024", EndDateStr: "06-2024"},
+			want: 600,
+		},
+		{
+			name: "yearly price charged in the start month",
+			req:  model.CostAnalyticsRequest{ServiceName: "Yandex Plus", StartDateStr: "01-2024", EndDateStr: "06-2024", Basis: model.BasisCharged},
+			want: 1200,
+		},
+		{
+			name: "no yearly charge outside the start month",
+			req:  model.CostAnalyticsRequest{ServiceName: "Yandex Plus", StartDateStr: "02-2024", EndDateStr: "12-2024", Basis: model.BasisCharged},
+			want: 0,
+		},
+		{
+			name: "quarterly price charged every third month",
+			req:  model.CostAnalyticsRequest{ServiceName: "Kinopoisk", StartDateStr: "01-2024", EndDateStr: "06-2024", Basis: model.BasisCharged},
+			want: 600,
+		},
+		{
+			name: "start_date mode counts subscriptions started in the period once",
+			req:  model.CostAnalyticsRequest{StartDateStr: "01-2024", EndDateStr: "01-2024", Mode: model.CostModeStartDate},
+			want: 500 + 100 + 100 + 3100,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, _, err := svc.GetCostAnalytics(ctx, tt.req)
+			if err != nil {
+				t.Fatalf("GetCostAnalytics: %v", err)
+			}
+			if total != tt.want {
+				t.Errorf("GetCostAnalytics = %d, want %d", total, tt.want)
+			}
+		})
+	}
+}
+
+func TestCostAnalyticsViewsAgree(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	seedAnalytics(t, svc)
+
+	for _, basis := range []string{model.BasisMonthlyEquivalent, model.BasisCharged} {
+		req := model.CostAnalyticsRequest{StartDateStr: "01-2024", EndDateStr: "06-2024", Basis: basis}
+		total, _, err := svc.GetCostAnalytics(ctx, req)
+		if err != nil {
+			t.Fatalf("GetCostAnalytics(%s): %v", basis, err)
+		}
+		buckets, err := svc.GetCostTimeSeries(ctx, req)
+		if err != nil {
+			t.Fatalf("GetCostTimeSeries(%s): %v", basis, err)
+		}
+		if len(buckets) != 6 {
+			t.Fatalf("GetCostTimeSeries(%s) returned %d months, want 6", basis, len(buckets))
+		}
+		seriesTotal := 0
+		for _, bucket := range buckets {
+			seriesTotal += bucket.TotalCost
+		}
+		req.GroupBy = model.GroupByServiceName
+		groups, err := svc.GetGroupedCostAnalytics(ctx, req)
+		if err != nil {
+			t.Fatalf("GetGroupedCostAnalytics(%s): %v", basis, err)
+		}
+		groupsTotal := 0
+		for _, group := range groups {
+			groupsTotal += group.TotalCost
+		}
+		if seriesTotal != total || groupsTotal != total {
+			t.Errorf("basis %s: total %d, time series %d, groups %d", basis, total, seriesTotal, groupsTotal)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"effective-mobile-subscriptions/internal/model"
+	"effective-mobile-subscriptions/internal/repository"
+)
+
+// максимальное число подписок в одном пакетном запросе
+const maxBatchSize = 1000
+
+// результат создания одной подписки из пакета
+type BatchResult struct {
+	// созданная подписка; nil, если элемент не создан
+	Subscription *model.Subscription
+	// ошибка валидации или сохранения элемента
+	Err error
+	// элемент корректен, но не сохранен: atomic-пакет отменен из-за ошибок в других элементах
+	Skipped bool
+}
+
+// создать несколько подписок. Каждый элемент валидируется отдельно, корректные сохраняются одной операцией.
+// с atomic=true подписки создаются, только если корректны все элементы; иначе ни одна
+func (s *SubscriptionService) CreateBatch(ctx context.Context, reqs []model.CreateSubscriptionRequest, atomic bool) ([]BatchResult, error) {
+	if len(reqs) == 0 {
+		return nil, ValidationError(CodeRequiredField, "", "at least one subscription is required")
+	}
+	if len(reqs) > maxBatchSize {
+		return nil, ValidationError(CodeInvalidParameter, "", fmt.Sprintf("batch cannot contain more than %d subscriptions", maxBatchSize))
+	}
+	results := make([]BatchResult, len(reqs))
+	valid := make([]*model.Subscription, 0, len(reqs))
+	positions := make([]int, 0, len(reqs))
+	for i, req := range reqs {
+		sub, err := s.prepareCreate(ctx, req)
+		if err == nil {
+			err = s.checkOverlaps(ctx, s.Repo, sub)
+		}
+		if err != nil {
+			if !isClientError(err) {
+				return nil, err
+			}
+			results[i].Err = err
+			continue
+		}
+		valid = append(valid, sub)
+		positions = append(positions, i)
+	}
+	if atomic && len(valid) < len(reqs) {
+		for _, i := range positions {
+			results[i].Skipped = true
+		}
+		return results, nil
+	}
+	err := s.Repo.CreateBatch(ctx, valid)
+	if err == nil {
+		for j, i := range positions {
+			results[i].Subscription = valid[j]
+		}
+		return results, nil
+	}
+	if atomic {
+		if !errors.Is(err, repository.ErrOverlap) {
+			log.Printf("ERROR: Failed to create batch of %d subscriptions in repository: %v", len(valid), err)
+			return nil, fmt.Errorf("failed to save subscriptions: %w", err)
+		}
+		// элементы пакета пересекаются между собой: сохраняем их по одному в одной транзакции, чтобы такие
+		// пересечения тоже прошли через политику; ошибка любого элемента отменяет весь пакет
+		return s.createBatchInTx(ctx, valid, positions, results)
+	}
+	// без atomic ошибку пакета локализуем, сохраняя элементы по одному; пересечения перепроверяются,
+	// чтобы учесть элементы этого же пакета, сохраненные раньше
+	log.Printf("WARN: Batch insert of %d subscriptions failed, retrying one by one: %v", len(valid), err)
+	for j, i := range positions {
+		if err := s.saveNew(ctx, s.Repo, valid[j]); err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Subscription = valid[j]
+	}
+	return results, nil
+}
+
+// сохранить корректные элементы atomic-пакета по одному в одной транзакции; при ошибке элемента
+// он получает ошибку, остальные помечаются пропущенными
+func (s *SubscriptionService) createBatchInTx(ctx context.Context, valid []*model.Subscription, positions []int, results []BatchResult) ([]BatchResult, error) {
+	failed := -1
+	err := s.Repo.WithTx(ctx, func(tx repository.Store) error {
+		for j, sub := range valid {
+			if err := s.saveNew(ctx, tx, sub); err != nil {
+				failed = j
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && (failed < 0 || !isClientError(err)) {
+		return nil, err
+	}
+	for j, i := range positions {
+		switch {
+		case err == nil:
+			results[i].Subscription = valid[j]
+		case j == failed:
+			results[i].Err = err
+		default:
+			results[i].Skipped = true
+		}
+	}
+	return results, nil
+}
+
+// ошибка вызвана данными запроса (валидация, пересечение), а не сбоем хранилища или источника курсов
+func isClientError(err error) bool {
+	return errors.Is(err, ErrValidation) || errors.Is(err, ErrConflict)
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"effective-mobile-subscriptions/internal/model"
+)
+
+// ограничения числа предстоящих списаний в ответе
+const (
+	defaultChargesLimit = 12
+	maxChargesLimit     = 100
+	// предел перебора дат списаний: защита от бесконечного цикла, если подходящих дат нет
+	maxChargeSteps = 100000
+)
+
+// получить ближайшие даты списаний по подписке начиная с сегодняшнего дня.
+// даты отсчитываются от start_date с шагом периода списания, сумма — цена, действующая в месяце списания;
+// списания в месяцы приостановки пропускаются
+func (s *SubscriptionService) UpcomingCharges(ctx context.Context, idStr, limitStr string) ([]model.Charge, error) {
+	limit := defaultChargesLimit
+	if limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > maxChargesLimit {
+			return nil, ValidationError(CodeInvalidParameter, "limit", fmt.Sprintf("limit must be between 1 and %d", maxChargesLimit))
+		}
+		limit = parsed
+	}
+	sub, err := s.GetByID(ctx, idStr)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := priceSchedule(ctx, s.Repo, sub)
+	if err != nil {
+		return nil, err
+	}
+	pauses, err := listPauses(ctx, s.Repo, sub.ID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	// первый день после окончания подписки: end_date включительно, а при точности month — до конца её месяца
+	var until *time.Time
+	if sub.EndDate != nil {
+		next := sub.EndDate.AddDate(0, 0, 1)
+		if sub.DatePrecision != model.DatePrecisionDay {
+			next = monthStart(*sub.EndDate).AddDate(0, 1, 0)
+		}
+		until = &next
+	}
+	// незавершенная приостановка действует бессрочно: после её начала списаний нет
+	if n := len(pauses); n > 0 && pauses[n-1].ResumedFrom == nil {
+		if pausedFrom := pauses[n-1].PausedFrom; until == nil || pausedFrom.Before(*until) {
+			until = &pausedFrom
+		}
+	}
+	charges := make([]model.Charge, 0, limit)
+	for k := 0; len(charges) < limit && k < maxChargeSteps; k++ {
+		date := chargeDate(sub, k)
+		if until != nil && !date.Before(*until) {
+			break
+		}
+		if date.Before(today) || pausedAt(pauses, date) {
+			continue
+		}
+		charges = append(charges, model.Charge{Date: date, Amount: priceAt(schedule, date), Currency: sub.Currency})
+	}
+	return charges, nil
+}
+
+// дата k-го списания; считается от start_date, чтобы сдвиги по коротким месяцам не накапливались.
+// если дня списания (29-31) нет в месяце, списание приходится на его последний день
+func chargeDate(sub *model.Subscription, k int) time.Time {
+	start := sub.StartDate.UTC()
+	months := k
+	switch sub.BillingPeriod {
+	case model.BillingWeekly:
+		return start.AddDate(0, 0, 7*k)
+	case model.BillingQuarterly:
+		months = 3 * k
+	case model.BillingYearly:
+		months = 12 * k
+	}
+	month := time.Date(start.Year(), start.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	day := start.Day()
+	if last := monthEnd(month).Day(); day > last {
+		day = last
+	}
+	return month.AddDate(0, 0, day-1)
+}
+
+// цена из графика, действующая в месяце даты date
+func priceAt(schedule []model.PriceChange, date time.Time) int {
+	price := schedule[0].Price
+	for _, change := range schedule[1:] {
+		if change.EffectiveFrom.After(date) {
+			break
+		}
+		price = change.Price
+	}
+	return price
+}
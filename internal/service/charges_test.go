@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"effective-mobile-subscriptions/internal/model"
+)
+
+func TestChargeDateClampsToMonthEnd(t *testing.T) {
+	tests := []struct {
+		start  string
+		period string
+		want   []string
+	}{
+		{"2025-01-31", model.BillingMonthly, []string{"2025-01-31", "2025-02-28", "2025-03-31", "2025-04-30"}},
+		{"2024-01-31", model.BillingMonthly, []string{"2024-01-31", "2024-02-29", "2024-03-31"}},
+		{"2024-11-30", model.BillingQuarterly, []string{"2024-11-30", "2025-02-28", "2025-05-30", "2025-08-30"}},
+		{"2024-02-29", model.BillingYearly, []string{"2024-02-29", "2025-02-28", "2026-02-28", "2027-02-28", "2028-02-29"}},
+		{"2025-01-31", model.BillingWeekly, []string{"2025-01-31", "2025-02-07", "2025-02-14"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.period+" "+tt.start, func(t *testing.T) {
+			start, err := time.Parse(dateLayout, tt.start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sub := &model.Subscription{StartDate: start, BillingPeriod: tt.period}
+			for k, want := range tt.want {
+				if got := chargeDate(sub, k).Format(dateLayout); got != want {
+					t.Errorf("charge %d on %s, want %s", k, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestChargedBasisMatchesChargeDates(t *testing.T) {
+	tests := []struct {
+		start, end string
+		period     string
+	}{
+		{"2025-01-31", "2025-12-31", model.BillingMonthly},
+		{"2024-11-30", "2025-11-30", model.BillingQuarterly},
+		{"2024-02-29", "2028-03-31", model.BillingYearly},
+		{"2025-01-31", "2025-06-30", model.BillingWeekly},
+	}
+	for name, svc := range testServices(t) {
+		for _, tt := range tests {
+			t.Run(name+" "+tt.period+" "+tt.start, func(t *testing.T) {
+				ctx := context.Background()
+				sub := mustCreate(t, svc, model.CreateSubscriptionRequest{
+					ServiceName: "Charges " + tt.period + " " + tt.start, Price: 100, BillingPeriod: tt.period,
+					StartDate: tt.start, EndDate: &tt.end,
+				})
+				// ожидаемое число списаний по месяцам из дат списаний
+				want := make(map[string]int)
+				for k := 0; ; k++ {
+					date := chargeDate(sub, k)
+					if date.After(*sub.EndDate) {
+						break
+					}
+					want[date.Format(monthYearLayout)]++
+				}
+				buckets, err := svc.GetCostTimeSeries(ctx, model.CostAnalyticsRequest{
+					UserID: testUserID, ServiceName: sub.ServiceName, Basis: model.BasisCharged,
+					StartDateStr: sub.StartDate.Format(monthYearLayout), EndDateStr: sub.EndDate.Format(monthYearLayout),
+				})
+				if err != nil {
+					t.Fatalf("GetCostTimeSeries: %v", err)
+				}
+				if len(buckets) == 0 {
+					t.Fatal("GetCostTimeSeries returned no months")
+				}
+				for _, bucket := range buckets {
+					if got := bucket.TotalCost / 100; got != want[bucket.Month] {
+						t.Errorf("month %s: %d charges in analytics, %d charge dates", bucket.Month, got, want[bucket.Month])
+					}
+					delete(want, bucket.Month)
+				}
+				for month, count := range want {
+					t.Errorf("month %s: %d charge dates missing from analytics", month, count)
+				}
+			})
+		}
+	}
+}
+
+func TestUpcomingChargesStopsAtOpenPause(t *testing.T) {
+	svc := newTestService(t)
+	ctx := context.Background()
+	sub := mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Yandex Plus", Price: 400, StartDate: "01-2020"})
+	next := monthStart(time.Now()).AddDate(0, 1, 0)
+	if _, err := svc.Pause(ctx, sub.ID.String(), model.PauseRequest{From: next.Format(monthYearLayout)}, nil); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+
+	type result struct {
+		charges []model.Charge
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		charges, err := svc.UpcomingCharges(ctx, sub.ID.String(), "")
+		done <- result{charges, err}
+	}()
+	select {
+	case res := <-done:
+		if res.err != nil {
+			t.Fatalf("UpcomingCharges: %v", res.err)
+		}
+		for _, charge := range res.charges {
+			if !charge.Date.Before(next) {
+				t.Errorf("charge on %s falls into the open pause from %s", charge.Date.Format(dateLayout), next.Format(dateLayout))
+			}
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("UpcomingCharges did not return for a subscription with an open pause")
+	}
+}
+
+func TestUpcomingChargesSkipsClosedPause(t *testing.T) {
+	svc := newTestService(t)
+	ctx := context.Background()
+	start := monthStart(time.Now()).AddDate(0, 1, 0)
+	sub := mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Yandex Plus", Price: 400, StartDate: start.Format(monthYearLayout)})
+	id := sub.ID.String()
+	if _, err := svc.Pause(ctx, id, model.PauseRequest{From: start.AddDate(0, 1, 0).Format(monthYearLayout)}, nil); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if _, err := svc.Resume(ctx, id, model.PauseRequest{From: start.AddDate(0, 3, 0).Format(monthYearLayout)}, nil); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+
+	charges, err := svc.UpcomingCharges(ctx, id, "3")
+	if err != nil {
+		t.Fatalf("UpcomingCharges: %v", err)
+	}
+	want := []time.Time{start, start.AddDate(0, 3, 0), start.AddDate(0, 4, 0)}
+	if len(charges) != len(want) {
+		t.Fatalf("got %d charges, want %d", len(charges), len(want))
+	}
+	for i, charge := range charges {
+		if !charge.Date.Equal(want[i]) {
+			t.Errorf("charge %d on %s, want %s", i, charge.Date.Format(dateLayout), want[i].Format(dateLayout))
+		}
+	}
+}
+
+func TestPauseLifecycle(t *testing.T) {
+	svc := newTestService(t)
+	ctx := context.Background()
+	sub := mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Yandex Plus", Price: 100, StartDate: "01-2024", EndDate: strPtr("12-2024")})
+	id := sub.ID.String()
+
+	if _, err := svc.Resume(ctx, id, model.PauseRequest{From: "02-2024", Retroactive: true}, nil); errorCode(err) != CodeInvalidStatusTransition {
+		t.Fatalf("Resume of an active subscription: error %v, want %s", err, CodeInvalidStatusTransition)
+	}
+	if _, err := svc.Pause(ctx, id, model.PauseRequest{From: "12-2023", Retroactive: true}, nil); errorCode(err) != CodeInvalidPeriod {
+		t.Fatalf("Pause before start_date: error %v, want %s", err, CodeInvalidPeriod)
+	}
+	if _, err := svc.Pause(ctx, id, model.PauseRequest{From: "03-2024", Retroactive: true}, nil); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if _, err := svc.Pause(ctx, id, model.PauseRequest{From: "04-2024", Retroactive: true}, nil); errorCode(err) != CodeInvalidStatusTransition {
+		t.Fatalf("second Pause: error %v, want %s", err, CodeInvalidStatusTransition)
+	}
+	if _, err := svc.Resume(ctx, id, model.PauseRequest{From: "03-2024", Retroactive: true}, nil); errorCode(err) != CodeInvalidPeriod {
+		t.Fatalf("Resume in the pause month: error %v, want %s", err, CodeInvalidPeriod)
+	}
+	if _, err := svc.Resume(ctx, id, model.PauseRequest{From: "06-2024", Retroactive: true}, nil); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+
+	// март-май не оплачиваются
+	req := model.CostAnalyticsRequest{StartDateStr: "01-2024", EndDateStr: "12-2024"}
+	total, _, err := svc.GetCostAnalytics(ctx, req)
+	if err != nil {
+		t.Fatalf("GetCostAnalytics: %v", err)
+	}
+	if total != 9*100 {
+		t.Errorf("GetCostAnalytics = %d, want %d", total, 9*100)
+	}
+	buckets, err := svc.GetCostTimeSeries(ctx, req)
+	if err != nil {
+		t.Fatalf("GetCostTimeSeries: %v", err)
+	}
+	for _, bucket := range buckets {
+		paused := bucket.Month == "03-2024" || bucket.Month == "04-2024" || bucket.Month == "05-2024"
+		if paused != (bucket.ActiveCount == 0) {
+			t.Errorf("month %s: active_count %d, paused %v", bucket.Month, bucket.ActiveCount, paused)
+		}
+	}
+	pauses, err := svc.Pauses(ctx, id)
+	if err != nil {
+		t.Fatalf("Pauses: %v", err)
+	}
+	if len(pauses) != 1 || pauses[0].ResumedFrom == nil {
+		t.Fatalf("Pauses = %+v, want one closed pause", pauses)
+	}
+}
+
+func TestPauseStatusAndCancel(t *testing.T) {
+	svc := newTestService(t)
+	ctx := context.Background()
+	current := monthStart(time.Now())
+	sub := mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Yandex Plus", Price: 100, StartDate: current.AddDate(0, -2, 0).Format(monthYearLayout)})
+	id := sub.ID.String()
+	if sub.Status != model.StatusActive {
+		t.Fatalf("status = %s, want %s", sub.Status, model.StatusActive)
+	}
+
+	paused, err := svc.Pause(ctx, id, model.PauseRequest{}, nil)
+	if err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if paused.Status != model.StatusPaused {
+		t.Fatalf("status after Pause = %s, want %s", paused.Status, model.StatusPaused)
+	}
+	if charges, err := svc.UpcomingCharges(ctx, id, ""); err != nil || len(charges) != 0 {
+		t.Fatalf("UpcomingCharges while paused = %v, %v; want no charges", charges, err)
+	}
+
+	resumed, err := svc.Resume(ctx, id, model.PauseRequest{From: current.AddDate(0, 1, 0).Format(monthYearLayout)}, nil)
+	if err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	// текущий месяц остается в приостановке, списания возобновляются со следующего
+	if resumed.Status != model.StatusPaused {
+		t.Fatalf("status after Resume from next month = %s, want %s", resumed.Status, model.StatusPaused)
+	}
+	charges, err := svc.UpcomingCharges(ctx, id, "2")
+	if err != nil {
+		t.Fatalf("UpcomingCharges: %v", err)
+	}
+	if len(charges) != 2 || !charges[0].Date.Equal(current.AddDate(0, 1, 0)) {
+		t.Fatalf("UpcomingCharges = %+v, want 2 charges from %s", charges, current.AddDate(0, 1, 0).Format(dateLayout))
+	}
+
+	cancelled, err := svc.Cancel(ctx, id, model.CancelRequest{EffectiveMonth: current.AddDate(0, 1, 0).Format(monthYearLayout)}, nil)
+	if err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if cancelled.EndDate == nil || !monthStart(*cancelled.EndDate).Equal(current.AddDate(0, 1, 0)) {
+		t.Fatalf("Cancel: end_date = %v, want %s", cancelled.EndDate, current.AddDate(0, 1, 0).Format(monthYearLayout))
+	}
+	if charges, err = svc.UpcomingCharges(ctx, id, ""); err != nil || len(charges) != 1 {
+		t.Fatalf("UpcomingCharges after Cancel = %+v, %v; want the single charge of the last month", charges, err)
+	}
+	if _, err := svc.Cancel(ctx, id, model.CancelRequest{EffectiveMonth: current.AddDate(0, 2, 0).Format(monthYearLayout)}, nil); errorCode(err) != CodeInvalidStatusTransition {
+		t.Fatalf("Cancel after the end: error %v, want %s", err, CodeInvalidStatusTransition)
+	}
+}
+
+func TestPauseRejectsPastMonthWithoutRetroactive(t *testing.T) {
+	svc := newTestService(t)
+	ctx := context.Background()
+	current := monthStart(time.Now())
+	sub := mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Yandex Plus", Price: 100, StartDate: current.AddDate(0, -6, 0).Format(monthYearLayout)})
+	id := sub.ID.String()
+	past := current.AddDate(0, -2, 0).Format(monthYearLayout)
+
+	if _, err := svc.Pause(ctx, id, model.PauseRequest{From: past}, nil); errorCode(err) != CodeRetroactiveNotConfirmed {
+		t.Fatalf("Pause from a past month: error %v, want %s", err, CodeRetroactiveNotConfirmed)
+	}
+	if pauses, err := svc.Pauses(ctx, id); err != nil || len(pauses) != 0 {
+		t.Fatalf("Pauses after rejected Pause = %+v, %v; want none", pauses, err)
+	}
+	if _, err := svc.Pause(ctx, id, model.PauseRequest{From: past, Retroactive: true}, nil); err != nil {
+		t.Fatalf("retroactive Pause: %v", err)
+	}
+	if _, err := svc.Resume(ctx, id, model.PauseRequest{From: current.AddDate(0, -1, 0).Format(monthYearLayout)}, nil); errorCode(err) != CodeRetroactiveNotConfirmed {
+		t.Fatalf("Resume from a past month: error %v, want %s", err, CodeRetroactiveNotConfirmed)
+	}
+	if _, err := svc.Resume(ctx, id, model.PauseRequest{}, nil); err != nil {
+		t.Fatalf("Resume from the current month: %v", err)
+	}
+}
+
+func TestPauseAndResumeBumpVersion(t *testing.T) {
+	svc := newTestService(t)
+	ctx := context.Background()
+	current := monthStart(time.Now())
+	sub := mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Yandex Plus", Price: 100, StartDate: current.AddDate(0, -1, 0).Format(monthYearLayout)})
+	id := sub.ID.String()
+
+	stale := sub.Version + 1
+	if _, err := svc.Pause(ctx, id, model.PauseRequest{}, &stale); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("Pause with a wrong If-Match: error %v, want %v", err, ErrVersionMismatch)
+	}
+	paused, err := svc.Pause(ctx, id, model.PauseRequest{}, &sub.Version)
+	if err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if paused.Version != sub.Version+1 {
+		t.Fatalf("version after Pause = %d, want %d", paused.Version, sub.Version+1)
+	}
+	// ETag, полученный до приостановки, больше не подходит
+	if _, err := svc.Resume(ctx, id, model.PauseRequest{From: current.AddDate(0, 1, 0).Format(monthYearLayout)}, &sub.Version); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("Resume with the pre-pause If-Match: error %v, want %v", err, ErrVersionMismatch)
+	}
+	resumed, err := svc.Resume(ctx, id, model.PauseRequest{From: current.AddDate(0, 1, 0).Format(monthYearLayout)}, &paused.Version)
+	if err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	if resumed.Version != paused.Version+1 {
+		t.Fatalf("version after Resume = %d, want %d", resumed.Version, paused.Version+1)
+	}
+	if _, err := svc.Update(ctx, id, model.UpdateSubscriptionRequest{ServiceName: strPtr("Kinopoisk")}, &paused.Version); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("Update with the pre-resume If-Match: error %v, want %v", err, ErrVersionMismatch)
+	}
+
+	events, err := svc.History(ctx, id)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	var types []string
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	if len(types) != 3 || types[1] != model.EventPaused || types[2] != model.EventResumed {
+		t.Fatalf("History = %v, want created, paused, resumed", types)
+	}
+}
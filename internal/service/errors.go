@@ -2,16 +2,107 @@ package service
 
 import (
 	"errors"
-	"fmt"
+	"strings"
 )
 
 var (
 	ErrValidation = errors.New("validation error")
 	ErrNotFound   = errors.New("resource not found")
+	// версия ресурса не совпала с ожидаемой клиентом (If-Match) или изменилась параллельно
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// запрос корректен синтаксически, но не может быть выполнен (например, ключ идемпотентности занят другим запросом)
+	ErrUnprocessable = errors.New("unprocessable request")
+	// запрос противоречит уже сохраненным данным (например, пересекающаяся подписка)
+	ErrConflict = errors.New("conflict")
 )
 
-func ValidationError(message string) error {
-	return fmt.Errorf("%w: %s", ErrValidation, message)
+// стабильные машиночитаемые коды ошибок, на которые могут опираться клиенты
+const (
+	CodeRequiredField    = "required_field"
+	CodeInvalidUUID      = "invalid_uuid"
+	CodeInvalidMonthYear = "invalid_month_year"
+	CodePriceNonPositive = "price_non_positive"
+	CodeInvalidCurrency  = "invalid_currency"
+	// для валюты нет курса у источника курсов
+	CodeUnsupportedCurrency  = "unsupported_currency"
+	CodeInvalidBillingPeriod = "invalid_billing_period"
+	CodeNoFieldsToUpdate     = "no_fields_to_update"
+	CodeEndBeforeStart       = "end_before_start"
+	CodeInvalidPeriod        = "invalid_period"
+	CodeInvalidParameter     = "invalid_parameter"
+	CodeInvalidCursor        = "invalid_cursor"
+	CodeSubscriptionNotFound = "subscription_not_found"
+	CodeServiceNotFound      = "service_not_found"
+	CodeVersionMismatch      = "version_mismatch"
+	// период подписки пересекается с другой подпиской пользователя на тот же сервис (политика reject)
+	CodeSubscriptionOverlap = "subscription_overlap"
+	// отмена, приостановка или возобновление недопустимы в текущем состоянии подписки
+	CodeInvalidStatusTransition = "invalid_status_transition"
+	// приостановка или возобновление с прошедшего месяца без подтверждения retroactive
+	CodeRetroactiveNotConfirmed = "retroactive_not_confirmed"
+	// название или синоним сервиса уже заняты другой записью каталога
+	CodeServiceNameTaken = "service_name_taken"
+	// ключ идемпотентности уже использован с другим телом запроса
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	// цену начавшейся подписки нельзя изменить через PUT, только изменением с указанного месяца
+	CodePriceChangeRequired = "price_change_required"
+	// валюту и период списания начавшейся подписки нельзя изменить: это переписало бы стоимость прошлых месяцев
+	CodeStartedTermsLocked = "started_terms_locked"
+	// изменение цены вне периода действия подписки
+	CodePriceChangeOutOfPeriod = "price_change_out_of_period"
+	// некорректный CSV: заголовок, кавычки или число полей в строке
+	CodeInvalidCSV = "invalid_csv"
+	// несколько ошибок валидации одновременно, подробности в ValidationErrors
+	CodeValidationFailed = "validation_failed"
+)
+
+// типизированная ошибка сервиса: вид (ErrValidation, ErrNotFound), код, поле и текст для клиента
+type Error struct {
+	Kind    error
+	Code    string
+	Field   string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Kind.Error() + ": " + e.Message
+}
+
+// позволяет проверять вид ошибки через errors.Is(err, ErrValidation)
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// ErrSubscriptionNotFound возвращается, когда подписки с указанным ID нет
+var ErrSubscriptionNotFound = &Error{Kind: ErrNotFound, Code: CodeSubscriptionNotFound, Message: "subscription not found"}
+
+// ErrServiceNotFound возвращается, когда записи каталога сервисов с указанным ID нет
+var ErrServiceNotFound = &Error{Kind: ErrNotFound, Code: CodeServiceNotFound, Message: "service not found"}
+
+// ErrVersionMismatch возвращается, когда подписка изменилась после того, как клиент её прочитал
+var ErrVersionMismatch = &Error{Kind: ErrPreconditionFailed, Code: CodeVersionMismatch, Message: "subscription has been modified, fetch it again and retry"}
+
+// ErrIdempotencyKeyReused возвращается, когда ключ идемпотентности пришел с телом, отличным от первого запроса
+var ErrIdempotencyKeyReused = &Error{Kind: ErrUnprocessable, Code: CodeIdempotencyKeyReused, Message: "Idempotency-Key has already been used with a different request body"}
+
+// ошибка валидации с кодом и полем, к которому она относится
+func ValidationError(code, field, message string) error {
+	return &Error{Kind: ErrValidation, Code: code, Field: field, Message: message}
 }
 
+// ошибки валидации сразу по нескольким полям запроса
+type ValidationErrors struct {
+	Errors []*Error
+}
+
+func (e *ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		messages = append(messages, fieldErr.Message)
+	}
+	return ErrValidation.Error() + ": " + strings.Join(messages, "; ")
+}
 
+func (e *ValidationErrors) Unwrap() error {
+	return ErrValidation
+}
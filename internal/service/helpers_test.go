@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+
+	"effective-mobile-subscriptions/internal/migrate"
+	"effective-mobile-subscriptions/internal/model"
+	"effective-mobile-subscriptions/internal/rates"
+	"effective-mobile-subscriptions/internal/repository"
+	"effective-mobile-subscriptions/migrations"
+	_ "github.com/lib/pq"
+)
+
+const testUserID = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+// курсы к базовой валюте RUB для тестов
+type staticRates map[string]float64
+
+func (r staticRates) Base() string {
+	return "RUB"
+}
+
+func (r staticRates) Rates(ctx context.Context, target string) (map[string]float64, error) {
+	all := map[string]float64{"RUB": 1}
+	for currency, rate := range r {
+		all[currency] = rate
+	}
+	targetRate, ok := all[target]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", rates.ErrUnknownCurrency, target)
+	}
+	multipliers := make(map[string]float64, len(all))
+	for currency, rate := range all {
+		multipliers[currency] = rate / targetRate
+	}
+	return multipliers, nil
+}
+
+// сервис над in-memory хранилищем с курсом USD = 90 RUB
+func newTestService(t *testing.T) *SubscriptionService {
+	t.Helper()
+	return NewSubscriptionService(repository.NewMemorySubscriptionRepository(), staticRates{"USD": 90})
+}
+
+// сервисы над всеми хранилищами: in-memory всегда, PostgreSQL — если задана TEST_DATABASE_URL.
+// база мигрируется до последней версии и очищается перед тестом
+func testServices(t *testing.T) map[string]*SubscriptionService {
+	t.Helper()
+	services := map[string]*SubscriptionService{"memory": newTestService(t)}
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Log("TEST_DATABASE_URL is not set, skipping PostgreSQL store")
+		return services
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrator, err := migrate.NewMigrator(db, migrations.FS)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	if _, err := db.Exec("TRUNCATE subscriptions CASCADE"); err != nil {
+		t.Fatalf("clean test database: %v", err)
+	}
+	services["postgres"] = NewSubscriptionService(repository.NewSubscriptionRepository(db), staticRates{"USD": 90})
+	return services
+}
+
+// создать подписку или прервать тест
+func mustCreate(t *testing.T, svc *SubscriptionService, req model.CreateSubscriptionRequest) *model.Subscription {
+	t.Helper()
+	if req.UserID == "" {
+		req.UserID = testUserID
+	}
+	sub, err := svc.Create(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Create(%+v): %v", req, err)
+	}
+	return sub
+}
+
+// код ошибки сервиса или пустая строка
+func errorCode(err error) string {
+	if serviceErr, ok := err.(*Error); ok {
+		return serviceErr.Code
+	}
+	if validationErrs, ok := err.(*ValidationErrors); ok && len(validationErrs.Errors) > 0 {
+		return validationErrs.Errors[0].Code
+	}
+	return ""
+}
+
+func strPtr(value string) *string {
+	return &value
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"effective-mobile-subscriptions/internal/model"
+	"effective-mobile-subscriptions/internal/repository"
+)
+
+// сколько ключ идемпотентности защищает от повторного создания, если не задано в конфигурации
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// максимальная длина ключа идемпотентности
+const maxIdempotencyKeyLength = 255
+
+// создать подписку не более одного раза для ключа idempotencyKey.
+// повтор с тем же телом возвращает подписку из первого ответа (replayed = true),
+// повтор с другим телом — ошибку idempotency_key_reused
+func (s *SubscriptionService) CreateIdempotent(ctx context.Context, idempotencyKey string, req model.CreateSubscriptionRequest) (sub *model.Subscription, replayed bool, err error) {
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		return nil, false, ValidationError(CodeInvalidParameter, "Idempotency-Key", fmt.Sprintf("Idempotency-Key cannot be longer than %d characters", maxIdempotencyKeyLength))
+	}
+	requestHash, err := hashCreateRequest(req)
+	if err != nil {
+		return nil, false, err
+	}
+	created, err := s.prepareCreate(ctx, req)
+	if err != nil {
+		return nil, false, err
+	}
+	err = s.Repo.WithTx(ctx, func(tx repository.Store) error {
+		existing, err := tx.ReserveIdempotencyKey(ctx, model.IdempotencyKey{
+			Key:         idempotencyKey,
+			RequestHash: requestHash,
+			ExpiresAt:   time.Now().Add(s.IdempotencyTTL),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to reserve idempotency key: %w", err)
+		}
+		if existing != nil {
+			if existing.RequestHash != requestHash {
+				return ErrIdempotencyKeyReused
+			}
+			if existing.Response == nil {
+				return fmt.Errorf("idempotency key %q has no saved response", idempotencyKey)
+			}
+			sub = &model.Subscription{}
+			if err := json.Unmarshal(existing.Response, sub); err != nil {
+				return fmt.Errorf("failed to decode saved idempotent response: %w", err)
+			}
+			replayed = true
+			return nil
+		}
+		// создание в той же транзакции: при ошибке ключ освобождается вместе с откатом
+		if err := s.saveNew(ctx, tx, created); err != nil {
+			return err
+		}
+		response, err := json.Marshal(created)
+		if err != nil {
+			return fmt.Errorf("failed to encode idempotent response: %w", err)
+		}
+		if err := tx.CompleteIdempotencyKey(ctx, idempotencyKey, response); err != nil {
+			return fmt.Errorf("failed to save idempotent response: %w", err)
+		}
+		sub = created
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return sub, replayed, nil
+}
+
+// удалить истекшие ключи идемпотентности
+func (s *SubscriptionService) PurgeExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	purged, err := s.Repo.PurgeIdempotencyKeys(ctx, time.Now())
+	if err != nil {
+		log.Printf("ERROR: PurgeExpiredIdempotencyKeys failed in repository: %v", err)
+		return 0, fmt.Errorf("service error when purging idempotency keys: %w", err)
+	}
+	return purged, nil
+}
+
+// sha256 запроса на создание в каноническом JSON: одинаковые по смыслу тела дают одинаковый хэш
+func hashCreateRequest(req model.CreateSubscriptionRequest) (string, error) {
+	canonical, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request for hashing: %w", err)
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
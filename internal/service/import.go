@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"effective-mobile-subscriptions/internal/model"
+)
+
+// столбцы CSV-импорта: обязательные, необязательные и служебные столбцы выгрузки, которые пропускаются
+var (
+	importRequiredColumns = []string{"service_name", "price", "user_id", "start_date"}
+	importOptionalColumns = []string{"end_date", "currency", "billing_period"}
+	importIgnoredColumns  = []string{"id", "date_precision", "created_at", "version", "deleted_at"}
+)
+
+const (
+	// сколько строк валидируется и сохраняется за один пакет
+	importChunkSize = 500
+	// сколько ошибок строк попадает в отчет; остальные только подсчитываются
+	maxImportErrors = 1000
+)
+
+// ошибка в строке CSV-файла
+type ImportLineError struct {
+	// номер строки файла, заголовок — строка 1
+	Line int
+	Err  error
+}
+
+// итог импорта
+type ImportReport struct {
+	DryRun bool
+	// строк данных без заголовка
+	Rows    int
+	Valid   int
+	Created int
+	Failed  int
+	Errors  []ImportLineError
+	// в Errors попали не все ошибочные строки
+	ErrorsTruncated bool
+}
+
+// строка файла, ожидающая сохранения в пакете
+type importRow struct {
+	line int
+	req  model.CreateSubscriptionRequest
+}
+
+// импортировать подписки из CSV с заголовком. Файл читается потоково, строки проверяются так же, как в Create,
+// и сохраняются пакетами по importChunkSize; ошибочные строки пропускаются и попадают в отчет, а сбой хранилища
+// прерывает импорт с ошибкой. с dryRun строки только проверяются
+func (s *SubscriptionService) ImportCSV(ctx context.Context, body io.Reader, dryRun bool) (*ImportReport, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ValidationError(CodeInvalidCSV, "", "CSV file is empty, header row is required")
+		}
+		return nil, ValidationError(CodeInvalidCSV, "", fmt.Sprintf("failed to read CSV header: %v", err))
+	}
+	columns, err := importColumns(header)
+	if err != nil {
+		return nil, err
+	}
+	report := &ImportReport{DryRun: dryRun, Errors: make([]ImportLineError, 0)}
+	chunk := make([]importRow, 0, importChunkSize)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			report.Rows++
+			report.addError(parseErr.StartLine, ValidationError(CodeInvalidCSV, "", parseErr.Err.Error()))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV body: %w", err)
+		}
+		report.Rows++
+		// позиция поля известна только у успешно прочитанной записи
+		line, _ := reader.FieldPos(0)
+		req, err := importRequest(record, columns)
+		if err != nil {
+			report.addError(line, err)
+			continue
+		}
+		chunk = append(chunk, importRow{line: line, req: req})
+		if len(chunk) == importChunkSize {
+			if err := s.importChunk(ctx, chunk, report); err != nil {
+				return nil, err
+			}
+			chunk = chunk[:0]
+		}
+	}
+	if err := s.importChunk(ctx, chunk, report); err != nil {
+		return nil, err
+	}
+	// ошибки пакетов добавляются после ошибок разбора следующих за ними строк
+	sort.SliceStable(report.Errors, func(i, j int) bool { return report.Errors[i].Line < report.Errors[j].Line })
+	return report, nil
+}
+
+// проверить и (без dry run) сохранить пакет строк
+func (s *SubscriptionService) importChunk(ctx context.Context, chunk []importRow, report *ImportReport) error {
+	if len(chunk) == 0 {
+		return nil
+	}
+	if report.DryRun {
+		for _, row := range chunk {
+			sub, err := s.prepareCreate(ctx, row.req)
+			if err == nil {
+				err = s.checkOverlaps(ctx, s.Repo, sub)
+			}
+			if err != nil {
+				if !isClientError(err) {
+					return err
+				}
+				report.addError(row.line, err)
+				continue
+			}
+			report.Valid++
+		}
+		return nil
+	}
+	reqs := make([]model.CreateSubscriptionRequest, len(chunk))
+	for i, row := range chunk {
+		reqs[i] = row.req
+	}
+	results, err := s.CreateBatch(ctx, reqs, false)
+	if err != nil {
+		return err
+	}
+	for i, result := range results {
+		if result.Subscription == nil {
+			// сбой хранилища прерывает импорт, а не попадает в отчет как ошибка строки
+			if !isClientError(result.Err) {
+				return result.Err
+			}
+			report.addError(chunk[i].line, result.Err)
+			continue
+		}
+		report.Valid++
+		report.Created++
+	}
+	return nil
+}
+
+// учесть ошибочную строку
+func (r *ImportReport) addError(line int, err error) {
+	r.Failed++
+	if len(r.Errors) >= maxImportErrors {
+		r.ErrorsTruncated = true
+		return
+	}
+	r.Errors = append(r.Errors, ImportLineError{Line: line, Err: err})
+}
+
+// позиции известных столбцов по заголовку; порядок столбцов произвольный, регистр не важен
+func importColumns(header []string) (map[string]int, error) {
+	known := make(map[string]bool, len(importRequiredColumns)+len(importOptionalColumns))
+	for _, name := range append(append([]string{}, importRequiredColumns...), importOptionalColumns...) {
+		known[name] = true
+	}
+	ignored := make(map[string]bool, len(importIgnoredColumns))
+	for _, name := range importIgnoredColumns {
+		ignored[name] = true
+	}
+	v := &validator{}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			// BOM, который добавляют табличные редакторы
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if ignored[name] {
+			continue
+		}
+		if !known[name] {
+			v.add(CodeInvalidCSV, name, fmt.Sprintf("unknown column %q", name))
+			continue
+		}
+		if _, ok := columns[name]; ok {
+			v.add(CodeInvalidCSV, name, fmt.Sprintf("duplicate column %q", name))
+			continue
+		}
+		columns[name] = i
+	}
+	for _, name := range importRequiredColumns {
+		if _, ok := columns[name]; !ok {
+			v.add(CodeInvalidCSV, name, fmt.Sprintf("required column %q is missing", name))
+		}
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// построить запрос на создание по строке CSV
+func importRequest(record []string, columns map[string]int) (model.CreateSubscriptionRequest, error) {
+	value := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	req := model.CreateSubscriptionRequest{
+		ServiceName:   value("service_name"),
+		UserID:        value("user_id"),
+		StartDate:     value("start_date"),
+		Currency:      value("currency"),
+		BillingPeriod: value("billing_period"),
+	}
+	if endDate := value("end_date"); endDate != "" {
+		req.EndDate = &endDate
+	}
+	if price := value("price"); price != "" {
+		parsed, err := strconv.Atoi(price)
+		if err != nil {
+			return req, ValidationError(CodeInvalidParameter, "price", "price must be an integer")
+		}
+		req.Price = parsed
+	}
+	return req, nil
+}
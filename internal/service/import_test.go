@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"effective-mobile-subscriptions/internal/model"
+	"effective-mobile-subscriptions/internal/repository"
+)
+
+func TestImportCSVReportsMalformedQuotes(t *testing.T) {
+	svc := newTestService(t)
+	body := strings.Join([]string{
+		"service_name,price,user_id,start_date",
+		"Yandex Plus,400," + testUserID + ",07-2025",
+		`Bad "quote,400,` + testUserID + ",07-2025",
+		`"Unterminated,400,` + testUserID + ",07-2025",
+	}, "\n")
+
+	report, err := svc.ImportCSV(context.Background(), strings.NewReader(body), false)
+	if err != nil {
+		t.Fatalf("ImportCSV: %v", err)
+	}
+	if report.Created != 1 {
+		t.Errorf("created %d rows, want 1", report.Created)
+	}
+	if len(report.Errors) == 0 {
+		t.Fatal("no errors reported for malformed quotes")
+	}
+	if line := report.Errors[0].Line; line != 3 {
+		t.Errorf("first error on line %d, want 3", line)
+	}
+	for _, lineErr := range report.Errors {
+		if code := errorCode(lineErr.Err); code != CodeInvalidCSV {
+			t.Errorf("line %d: code %q, want %q", lineErr.Line, code, CodeInvalidCSV)
+		}
+	}
+}
+
+func TestImportCSVReportsInvalidRows(t *testing.T) {
+	svc := newTestService(t)
+	body := strings.Join([]string{
+		"service_name,price,user_id,start_date",
+		"Yandex Plus,400," + testUserID + ",07-2025",
+		"Kinopoisk,abc," + testUserID + ",07-2025",
+		"Kinopoisk,300,not-a-uuid,07-2025",
+		"Kinopoisk,300," + testUserID + ",2025/07",
+	}, "\n")
+
+	report, err := svc.ImportCSV(context.Background(), strings.NewReader(body), true)
+	if err != nil {
+		t.Fatalf("ImportCSV: %v", err)
+	}
+	if report.Rows != 4 || report.Valid != 1 || report.Failed != 3 || report.Created != 0 {
+		t.Errorf("got rows=%d valid=%d failed=%d created=%d, want 4/1/3/0", report.Rows, report.Valid, report.Failed, report.Created)
+	}
+	wantCodes := map[int]string{3: CodeInvalidParameter, 4: CodeInvalidUUID, 5: CodeInvalidMonthYear}
+	for _, lineErr := range report.Errors {
+		if code := errorCode(lineErr.Err); code != wantCodes[lineErr.Line] {
+			t.Errorf("line %d: code %q, want %q", lineErr.Line, code, wantCodes[lineErr.Line])
+		}
+	}
+}
+
+func TestImportCSVRejectsBadHeader(t *testing.T) {
+	svc := newTestService(t)
+	tests := map[string]string{
+		"empty file":       "",
+		"missing column":   "service_name,price,user_id\nYandex Plus,400," + testUserID,
+		"unknown column":   "service_name,price,user_id,start_date,discount\n",
+		"duplicate column": "service_name,price,price,user_id,start_date\n",
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := svc.ImportCSV(context.Background(), strings.NewReader(body), true)
+			if code := errorCode(err); code != CodeInvalidCSV {
+				t.Fatalf("ImportCSV: error %v (code %q), want %q", err, code, CodeInvalidCSV)
+			}
+		})
+	}
+}
+
+// хранилище, которое не может сохранить подписки
+type failingCreateStore struct {
+	repository.Store
+}
+
+func (s failingCreateStore) Create(ctx context.Context, sub *model.Subscription) error {
+	return errStorageDown
+}
+
+func (s failingCreateStore) CreateBatch(ctx context.Context, subs []*model.Subscription) error {
+	return errStorageDown
+}
+
+var errStorageDown = errors.New("connection refused")
+
+func TestImportCSVReturnsStorageErrors(t *testing.T) {
+	svc := newTestService(t)
+	svc.Repo = failingCreateStore{Store: svc.Repo}
+	body := strings.Join([]string{
+		"service_name,price,user_id,start_date",
+		"Yandex Plus,400," + testUserID + ",07-2025",
+		"Kinopoisk,abc," + testUserID + ",07-2025",
+	}, "\n")
+
+	report, err := svc.ImportCSV(context.Background(), strings.NewReader(body), false)
+	if !errors.Is(err, errStorageDown) {
+		t.Fatalf("ImportCSV: report %+v, error %v, want %v", report, err, errStorageDown)
+	}
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"effective-mobile-subscriptions/internal/model"
+	"effective-mobile-subscriptions/internal/repository"
+	"github.com/google/uuid"
+)
+
+// ошибка invalid_status_transition: действие недопустимо в текущем состоянии подписки
+func errStatusTransition(message string) error {
+	return &Error{Kind: ErrConflict, Code: CodeInvalidStatusTransition, Message: message}
+}
+
+// отменить подписку: она действует до конца месяца effective_month (по умолчанию текущего).
+// повторная отмена с тем же месяцем ничего не меняет; expectedVersion — версия из If-Match (nil — без проверки)
+func (s *SubscriptionService) Cancel(ctx context.Context, idStr string, req model.CancelRequest, expectedVersion *int) (*model.Subscription, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, ValidationError(CodeInvalidUUID, "id", "incorrect format ID (expected UUID)")
+	}
+	month, err := lifecycleMonth("effective_month", req.EffectiveMonth)
+	if err != nil {
+		return nil, err
+	}
+	var cancelled *model.Subscription
+	err = s.Repo.WithTx(ctx, func(tx repository.Store) error {
+		sub, err := lockSubscription(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if expectedVersion != nil && sub.Version != *expectedVersion {
+			return ErrVersionMismatch
+		}
+		if month.Before(monthStart(sub.StartDate)) {
+			return ValidationError(CodeEndBeforeStart, "effective_month", "effective_month cannot be earlier than the start_date month")
+		}
+		// последний день действия: при точности day — последний день месяца
+		end := month
+		if sub.DatePrecision == model.DatePrecisionDay {
+			end = monthEnd(month)
+		}
+		if sub.EndDate != nil && !sub.EndDate.After(end) {
+			if monthStart(*sub.EndDate).Equal(month) {
+				cancelled = sub
+				return nil
+			}
+			return errStatusTransition(fmt.Sprintf("subscription already ends in %s", sub.EndDate.Format(monthYearLayout)))
+		}
+		sub.EndDate = &end
+		if err := tx.Update(ctx, sub); err != nil {
+			if errors.Is(err, repository.ErrVersionConflict) {
+				return ErrVersionMismatch
+			}
+			log.Printf("ERROR: Failed to cancel subscription %s in repository: %v", idStr, err)
+			return fmt.Errorf("failed to save cancelled subscription: %w", err)
+		}
+		cancelled = sub
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cancelled, nil
+}
+
+// приостановить подписку с месяца from (по умолчанию текущего) до возобновления.
+// приостановка увеличивает версию подписки; expectedVersion — версия из If-Match (nil — без проверки)
+func (s *SubscriptionService) Pause(ctx context.Context, idStr string, req model.PauseRequest, expectedVersion *int) (*model.Subscription, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, ValidationError(CodeInvalidUUID, "id", "incorrect format ID (expected UUID)")
+	}
+	from, err := pauseMonth(req)
+	if err != nil {
+		return nil, err
+	}
+	var paused *model.Subscription
+	err = s.Repo.WithTx(ctx, func(tx repository.Store) error {
+		sub, err := lockSubscription(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if expectedVersion != nil && sub.Version != *expectedVersion {
+			return ErrVersionMismatch
+		}
+		if from.Before(monthStart(sub.StartDate)) {
+			return ValidationError(CodeInvalidPeriod, "from", "pause cannot start before the start_date month")
+		}
+		if sub.EndDate != nil && from.After(monthStart(*sub.EndDate)) {
+			return errStatusTransition(fmt.Sprintf("subscription ends in %s, before the pause", sub.EndDate.Format(monthYearLayout)))
+		}
+		pauses, err := listPauses(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if len(pauses) > 0 {
+			last := pauses[len(pauses)-1]
+			if last.ResumedFrom == nil {
+				return errStatusTransition("subscription is already paused, resume it first")
+			}
+			if last.ResumedFrom.After(from) {
+				return ValidationError(CodeInvalidPeriod, "from", fmt.Sprintf("pause cannot start before the previous pause ends (%s)", last.ResumedFrom.Format(monthYearLayout)))
+			}
+		}
+		if err := tx.CreatePause(ctx, id, from); err != nil {
+			log.Printf("ERROR: Failed to save pause for subscription %s: %v", idStr, err)
+			return fmt.Errorf("failed to save pause: %w", err)
+		}
+		// статус зависит от приостановки, поэтому подписка перечитывается
+		paused, err = lockSubscription(ctx, tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paused, nil
+}
+
+// возобновить приостановленную подписку с месяца from (по умолчанию текущего).
+// возобновление увеличивает версию подписки; expectedVersion — версия из If-Match (nil — без проверки)
+func (s *SubscriptionService) Resume(ctx context.Context, idStr string, req model.PauseRequest, expectedVersion *int) (*model.Subscription, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, ValidationError(CodeInvalidUUID, "id", "incorrect format ID (expected UUID)")
+	}
+	from, err := pauseMonth(req)
+	if err != nil {
+		return nil, err
+	}
+	var resumed *model.Subscription
+	err = s.Repo.WithTx(ctx, func(tx repository.Store) error {
+		sub, err := lockSubscription(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if expectedVersion != nil && sub.Version != *expectedVersion {
+			return ErrVersionMismatch
+		}
+		pauses, err := listPauses(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if len(pauses) == 0 || pauses[len(pauses)-1].ResumedFrom != nil {
+			return errStatusTransition("subscription is not paused")
+		}
+		if pausedFrom := pauses[len(pauses)-1].PausedFrom; !from.After(pausedFrom) {
+			return ValidationError(CodeInvalidPeriod, "from", fmt.Sprintf("resume month must be later than the pause month (%s)", pausedFrom.Format(monthYearLayout)))
+		}
+		if err := tx.ClosePause(ctx, id, from); err != nil {
+			log.Printf("ERROR: Failed to close pause for subscription %s: %v", idStr, err)
+			return fmt.Errorf("failed to resume subscription: %w", err)
+		}
+		resumed, err = lockSubscription(ctx, tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resumed, nil
+}
+
+// получить приостановки подписки в порядке paused_from
+func (s *SubscriptionService) Pauses(ctx context.Context, idStr string) ([]model.Pause, error) {
+	sub, err := s.GetByID(ctx, idStr)
+	if err != nil {
+		return nil, err
+	}
+	return listPauses(ctx, s.Repo, sub.ID)
+}
+
+// месяц действия из запроса; пустое значение — текущий месяц
+func lifecycleMonth(fieldName, value string) (time.Time, error) {
+	if value == "" {
+		return monthStart(time.Now()), nil
+	}
+	return ParseMonthYear(fieldName, value)
+}
+
+// месяц приостановки или возобновления из запроса. Прошедший месяц меняет стоимость уже закрытых месяцев,
+// поэтому допускается только с явным retroactive
+func pauseMonth(req model.PauseRequest) (time.Time, error) {
+	from, err := lifecycleMonth("from", req.From)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if from.Before(monthStart(time.Now())) && !req.Retroactive {
+		return time.Time{}, ValidationError(CodeRetroactiveNotConfirmed, "from",
+			"from is earlier than the current month and would change costs of past months, set retroactive to true to confirm")
+	}
+	return from, nil
+}
+
+// заблокировать действующую подписку внутри транзакции
+func lockSubscription(ctx context.Context, tx repository.SubscriptionStore, id uuid.UUID) (*model.Subscription, error) {
+	sub, err := tx.GetByIDForUpdate(ctx, id)
+	if err != nil {
+		log.Printf("ERROR: Failed to lock subscription %s in repository: %v", id, err)
+		return nil, fmt.Errorf("failed to retrieve subscription: %w", err)
+	}
+	if sub == nil {
+		return nil, ErrSubscriptionNotFound
+	}
+	return sub, nil
+}
+
+// приостановки подписки из хранилища
+func listPauses(ctx context.Context, repo repository.PauseStore, id uuid.UUID) ([]model.Pause, error) {
+	pauses, err := repo.ListPauses(ctx, id)
+	if err != nil {
+		log.Printf("ERROR: Failed to fetch pauses for subscription %s: %v", id, err)
+		return nil, fmt.Errorf("failed to retrieve pauses: %w", err)
+	}
+	return pauses, nil
+}
+
+// попадает ли месяц даты date в одну из приостановок
+func pausedAt(pauses []model.Pause, date time.Time) bool {
+	month := monthStart(date)
+	for _, pause := range pauses {
+		if !pause.PausedFrom.After(month) && (pause.ResumedFrom == nil || pause.ResumedFrom.After(month)) {
+			return true
+		}
+	}
+	return false
+}
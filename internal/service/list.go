@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"effective-mobile-subscriptions/internal/model"
+	"github.com/google/uuid"
+)
+
+// размер страницы списка по умолчанию и максимальный
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// получить страницу подписок по фильтрам
+func (s *SubscriptionService) List(ctx context.Context, req model.ListSubscriptionsRequest) (*model.SubscriptionPage, error) {
+	filters, err := ParseListFilter(req)
+	if err != nil {
+		return nil, err
+	}
+	page, err := s.Repo.List(ctx, filters)
+	if err != nil {
+		log.Printf("ERROR: List failed to retrieve subscriptions from repository: %v", err)
+		return nil, fmt.Errorf("service error while retrieving list: %w", err)
+	}
+	if page.Next != nil {
+		page.NextCursor = encodeCursor(*page.Next)
+	}
+	return page, nil
+}
+
+// провалидировать параметры списка и привести их к фильтрам репозитория
+func ParseListFilter(req model.ListSubscriptionsRequest) (model.ListFilter, error) {
+	filters := model.ListFilter{
+		ServiceName: req.ServiceName,
+		SortBy:      model.SortByCreatedAt,
+		Desc:        true,
+		Limit:       defaultListLimit,
+	}
+	if req.UserID != "" {
+		userID, err := uuid.Parse(req.UserID)
+		if err != nil {
+			return model.ListFilter{}, ValidationError(CodeInvalidUUID, "user_id", "incorrect format user_id (expected UUID)")
+		}
+		filters.UserID = &userID
+	}
+	if req.PriceMin != "" {
+		priceMin, err := strconv.Atoi(req.PriceMin)
+		if err != nil || priceMin < 0 {
+			return model.ListFilter{}, ValidationError(CodeInvalidParameter, "price_min", "price_min must be a non-negative integer")
+		}
+		filters.PriceMin = &priceMin
+	}
+	if req.PriceMax != "" {
+		priceMax, err := strconv.Atoi(req.PriceMax)
+		if err != nil || priceMax < 0 {
+			return model.ListFilter{}, ValidationError(CodeInvalidParameter, "price_max", "price_max must be a non-negative integer")
+		}
+		filters.PriceMax = &priceMax
+	}
+	if filters.PriceMin != nil && filters.PriceMax != nil && *filters.PriceMin > *filters.PriceMax {
+		return model.ListFilter{}, ValidationError(CodeInvalidParameter, "price_min", "price_min cannot be greater than price_max")
+	}
+	if req.ActiveAt != "" {
+		activeAt, err := ParseMonthYear("active_at", req.ActiveAt)
+		if err != nil {
+			return model.ListFilter{}, err
+		}
+		filters.ActiveAt = &activeAt
+	}
+	if req.OpenEnded != "" {
+		openEnded, err := strconv.ParseBool(req.OpenEnded)
+		if err != nil {
+			return model.ListFilter{}, ValidationError(CodeInvalidParameter, "open_ended", "open_ended must be true or false")
+		}
+		filters.OpenEnded = &openEnded
+	}
+	if req.IncludeDeleted != "" {
+		includeDeleted, err := strconv.ParseBool(req.IncludeDeleted)
+		if err != nil {
+			return model.ListFilter{}, ValidationError(CodeInvalidParameter, "include_deleted", "include_deleted must be true or false")
+		}
+		filters.IncludeDeleted = includeDeleted
+	}
+	switch req.SortBy {
+	case "":
+	case model.SortByID, model.SortByUserID, model.SortByServiceName, model.SortByPrice,
+		model.SortByStartDate, model.SortByEndDate, model.SortByCreatedAt:
+		filters.SortBy = req.SortBy
+		filters.Desc = false
+	default:
+		return model.ListFilter{}, ValidationError(CodeInvalidParameter, "sort_by", fmt.Sprintf("incorrect sort_by %q", req.SortBy))
+	}
+	switch req.Order {
+	case "":
+	case "asc":
+		filters.Desc = false
+	case "desc":
+		filters.Desc = true
+	default:
+		return model.ListFilter{}, ValidationError(CodeInvalidParameter, "order", "order must be asc or desc")
+	}
+	if req.Limit != "" {
+		limit, err := strconv.Atoi(req.Limit)
+		if err != nil || limit <= 0 || limit > maxListLimit {
+			return model.ListFilter{}, ValidationError(CodeInvalidParameter, "limit", fmt.Sprintf("limit must be between 1 and %d", maxListLimit))
+		}
+		filters.Limit = limit
+	}
+	if req.Cursor != "" {
+		cursor, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return model.ListFilter{}, ValidationError(CodeInvalidCursor, "cursor", "incorrect cursor")
+		}
+		if cursor.SortBy != filters.SortBy || cursor.Desc != filters.Desc || !validCursorValue(cursor) {
+			return model.ListFilter{}, ValidationError(CodeInvalidCursor, "cursor", "cursor does not match sort_by and order")
+		}
+		filters.Cursor = cursor
+	}
+	return filters, nil
+}
+
+// проверить, что значение курсора приводится к типу столбца сортировки
+func validCursorValue(cursor *model.ListCursor) bool {
+	var err error
+	switch cursor.SortBy {
+	case model.SortByUserID:
+		_, err = uuid.Parse(cursor.Value)
+	case model.SortByPrice:
+		_, err = strconv.Atoi(cursor.Value)
+	case model.SortByStartDate:
+		_, err = time.Parse("2006-01-02", cursor.Value)
+	case model.SortByEndDate:
+		if cursor.Value != "infinity" {
+			_, err = time.Parse("2006-01-02", cursor.Value)
+		}
+	case model.SortByCreatedAt:
+		_, err = time.Parse(time.RFC3339Nano, cursor.Value)
+	case model.SortByID:
+		_, err = uuid.Parse(cursor.Value)
+	}
+	return err == nil
+}
+
+// закодировать курсор в непрозрачную строку
+func encodeCursor(cursor model.ListCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// раскодировать курсор, полученный от клиента
+func decodeCursor(value string) (*model.ListCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	cursor := &model.ListCursor{}
+	if err := json.Unmarshal(raw, cursor); err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+// передать в fn все подписки по фильтрам и сортировке списка; limit и cursor не учитываются
+func (s *SubscriptionService) Export(ctx context.Context, req model.ListSubscriptionsRequest, fn func(sub *model.Subscription) error) error {
+	req.Limit, req.Cursor = "", ""
+	filters, err := ParseListFilter(req)
+	if err != nil {
+		return err
+	}
+	if err := s.Repo.Export(ctx, filters, fn); err != nil {
+		log.Printf("ERROR: Export failed to stream subscriptions from repository: %v", err)
+		return fmt.Errorf("service error while exporting subscriptions: %w", err)
+	}
+	return nil
+}
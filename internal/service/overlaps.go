@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"effective-mobile-subscriptions/internal/model"
+	"effective-mobile-subscriptions/internal/repository"
+	"github.com/google/uuid"
+)
+
+// размер отчета о пересечениях по умолчанию и максимальный
+const (
+	defaultOverlapsLimit = 100
+	maxOverlapsLimit     = 1000
+)
+
+// применить политику пересечений к подписке перед записью: reject — вернуть ошибку subscription_overlap,
+// warn — допустить пересечение и перечислить пересекающиеся подписки в sub.Overlaps, allow — не проверять
+func (s *SubscriptionService) checkOverlaps(ctx context.Context, repo repository.OverlapStore, sub *model.Subscription) error {
+	if s.OverlapPolicy == model.OverlapPolicyAllow {
+		sub.OverlapAllowed = true
+		return nil
+	}
+	overlapping, err := repo.FindOverlapping(ctx, sub)
+	if err != nil {
+		log.Printf("ERROR: Failed to check subscription overlaps in repository: %v", err)
+		return fmt.Errorf("failed to check subscription overlaps: %w", err)
+	}
+	sub.OverlapAllowed = false
+	sub.Overlaps = nil
+	if len(overlapping) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(overlapping))
+	for i, other := range overlapping {
+		ids[i] = other.ID
+	}
+	if s.OverlapPolicy != model.OverlapPolicyWarn {
+		return errOverlap(ids)
+	}
+	log.Printf("WARN: Subscription of user %s to %q overlaps with %d existing subscriptions", sub.UserID, sub.ServiceName, len(ids))
+	sub.OverlapAllowed = true
+	sub.Overlaps = ids
+	return nil
+}
+
+// ошибка subscription_overlap; ids — пересекающиеся подписки, если они известны
+func errOverlap(ids []uuid.UUID) error {
+	message := "subscription period overlaps with another subscription of the same service"
+	if len(ids) > 0 {
+		overlapping := make([]string, len(ids))
+		for i, id := range ids {
+			overlapping[i] = id.String()
+		}
+		message = "subscription period overlaps with subscriptions of the same service: " + strings.Join(overlapping, ", ")
+	}
+	return &Error{Kind: ErrConflict, Code: CodeSubscriptionOverlap, Message: message}
+}
+
+// получить пары пересекающихся действующих подписок
+func (s *SubscriptionService) Overlaps(ctx context.Context, req model.OverlapsRequest) ([]model.SubscriptionOverlap, error) {
+	filters := model.OverlapFilter{ServiceName: req.ServiceName, Limit: defaultOverlapsLimit}
+	if req.UserID != "" {
+		userID, err := uuid.Parse(req.UserID)
+		if err != nil {
+			return nil, ValidationError(CodeInvalidUUID, "user_id", "incorrect format user_id (expected UUID)")
+		}
+		filters.UserID = &userID
+	}
+	if req.Limit != "" {
+		limit, err := strconv.Atoi(req.Limit)
+		if err != nil || limit <= 0 || limit > maxOverlapsLimit {
+			return nil, ValidationError(CodeInvalidParameter, "limit", fmt.Sprintf("limit must be between 1 and %d", maxOverlapsLimit))
+		}
+		filters.Limit = limit
+	}
+	overlaps, err := s.Repo.ListOverlaps(ctx, filters)
+	if err != nil {
+		log.Printf("ERROR: Overlaps failed to retrieve subscription overlaps from repository: %v", err)
+		return nil, fmt.Errorf("service error while retrieving overlaps: %w", err)
+	}
+	return overlaps, nil
+}
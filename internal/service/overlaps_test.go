@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"effective-mobile-subscriptions/internal/model"
+)
+
+// удалить подписку и создать на её месте пересекающуюся, чтобы восстановление упиралось в пересечение
+func deletedWithOverlap(t *testing.T, svc *SubscriptionService) (deleted, replacement *model.Subscription) {
+	t.Helper()
+	ctx := context.Background()
+	deleted = mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Netflix", Price: 500, StartDate: "01-2024"})
+	if _, err := svc.Delete(ctx, deleted.ID.String(), nil); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	replacement = mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Netflix", Price: 600, StartDate: "03-2024"})
+	return deleted, replacement
+}
+
+func TestRestoreFollowsOverlapPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reject", func(t *testing.T) {
+		svc := newTestService(t)
+		deleted, _ := deletedWithOverlap(t, svc)
+		if _, err := svc.Restore(ctx, deleted.ID.String()); errorCode(err) != CodeSubscriptionOverlap {
+			t.Fatalf("Restore: error %v, want %s", err, CodeSubscriptionOverlap)
+		}
+	})
+
+	t.Run("warn", func(t *testing.T) {
+		svc := newTestService(t)
+		svc.OverlapPolicy = model.OverlapPolicyWarn
+		deleted, replacement := deletedWithOverlap(t, svc)
+		restored, err := svc.Restore(ctx, deleted.ID.String())
+		if err != nil {
+			t.Fatalf("Restore: %v", err)
+		}
+		if !restored.OverlapAllowed || len(restored.Overlaps) != 1 || restored.Overlaps[0] != replacement.ID {
+			t.Fatalf("Restore: overlap_allowed = %v, overlaps = %v; want true, [%s]", restored.OverlapAllowed, restored.Overlaps, replacement.ID)
+		}
+	})
+
+	t.Run("allow", func(t *testing.T) {
+		svc := newTestService(t)
+		svc.OverlapPolicy = model.OverlapPolicyAllow
+		deleted, _ := deletedWithOverlap(t, svc)
+		restored, err := svc.Restore(ctx, deleted.ID.String())
+		if err != nil {
+			t.Fatalf("Restore: %v", err)
+		}
+		if !restored.OverlapAllowed {
+			t.Fatal("Restore: overlap_allowed = false, want true")
+		}
+	})
+}
+
+// пакет из двух пересекающихся между собой подписок и одной независимой
+func overlappingBatch() []model.CreateSubscriptionRequest {
+	return []model.CreateSubscriptionRequest{
+		{ServiceName: "Netflix", Price: 500, UserID: testUserID, StartDate: "01-2024", EndDate: strPtr("06-2024")},
+		{ServiceName: "Netflix", Price: 600, UserID: testUserID, StartDate: "03-2024"},
+		{ServiceName: "Yandex Plus", Price: 400, UserID: testUserID, StartDate: "01-2024"},
+	}
+}
+
+func TestAtomicBatchFollowsOverlapPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reject", func(t *testing.T) {
+		svc := newTestService(t)
+		results, err := svc.CreateBatch(ctx, overlappingBatch(), true)
+		if err != nil {
+			t.Fatalf("CreateBatch: %v", err)
+		}
+		if !results[0].Skipped || errorCode(results[1].Err) != CodeSubscriptionOverlap || !results[2].Skipped {
+			t.Fatalf("CreateBatch results = %+v, want the overlapping item rejected and the rest skipped", results)
+		}
+		page, err := svc.List(ctx, model.ListSubscriptionsRequest{})
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(page.Items) != 0 {
+			t.Fatalf("List: %d subscriptions saved by a cancelled atomic batch", len(page.Items))
+		}
+	})
+
+	for _, policy := range []string{model.OverlapPolicyWarn, model.OverlapPolicyAllow} {
+		t.Run(policy, func(t *testing.T) {
+			svc := newTestService(t)
+			svc.OverlapPolicy = policy
+			results, err := svc.CreateBatch(ctx, overlappingBatch(), true)
+			if err != nil {
+				t.Fatalf("CreateBatch: %v", err)
+			}
+			for i, result := range results {
+				if result.Subscription == nil {
+					t.Fatalf("item %d not created: %+v", i, result)
+				}
+			}
+			if !results[1].Subscription.OverlapAllowed {
+				t.Error("overlapping item saved without overlap_allowed")
+			}
+		})
+	}
+}
+
+func TestCreateFollowsOverlapPolicy(t *testing.T) {
+	ctx := context.Background()
+	first := model.CreateSubscriptionRequest{ServiceName: "Netflix", Price: 500, StartDate: "01-2024", EndDate: strPtr("06-2024")}
+	second := model.CreateSubscriptionRequest{ServiceName: "Netflix", Price: 600, UserID: testUserID, StartDate: "06-2024"}
+
+	t.Run("reject", func(t *testing.T) {
+		svc := newTestService(t)
+		mustCreate(t, svc, first)
+		if _, err := svc.Create(ctx, second); errorCode(err) != CodeSubscriptionOverlap {
+			t.Fatalf("Create: error %v, want %s", err, CodeSubscriptionOverlap)
+		}
+		// подписка на другой сервис или после окончания первой не пересекается
+		mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Spotify", Price: 300, StartDate: "06-2024"})
+		mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Netflix", Price: 600, StartDate: "07-2024"})
+	})
+
+	t.Run("warn", func(t *testing.T) {
+		svc := newTestService(t)
+		svc.OverlapPolicy = model.OverlapPolicyWarn
+		existing := mustCreate(t, svc, first)
+		sub := mustCreate(t, svc, second)
+		if !sub.OverlapAllowed || len(sub.Overlaps) != 1 || sub.Overlaps[0] != existing.ID {
+			t.Fatalf("Create: overlap_allowed = %v, overlaps = %v; want true, [%s]", sub.OverlapAllowed, sub.Overlaps, existing.ID)
+		}
+		overlaps, err := svc.Overlaps(ctx, model.OverlapsRequest{})
+		if err != nil {
+			t.Fatalf("Overlaps: %v", err)
+		}
+		if len(overlaps) != 1 {
+			t.Fatalf("Overlaps returned %d pairs, want 1", len(overlaps))
+		}
+	})
+
+	t.Run("allow", func(t *testing.T) {
+		svc := newTestService(t)
+		svc.OverlapPolicy = model.OverlapPolicyAllow
+		mustCreate(t, svc, first)
+		if sub := mustCreate(t, svc, second); !sub.OverlapAllowed || len(sub.Overlaps) != 0 {
+			t.Fatalf("Create: overlap_allowed = %v, overlaps = %v; want true without overlaps", sub.OverlapAllowed, sub.Overlaps)
+		}
+	})
+}
+
+func TestUpdateFollowsOverlapPolicy(t *testing.T) {
+	ctx := context.Background()
+	for _, policy := range []string{model.OverlapPolicyReject, model.OverlapPolicyWarn} {
+		t.Run(policy, func(t *testing.T) {
+			svc := newTestService(t)
+			svc.OverlapPolicy = policy
+			mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Netflix", Price: 500, StartDate: "01-2024", EndDate: strPtr("06-2024")})
+			sub := mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Netflix", Price: 500, StartDate: "07-2024"})
+
+			updated, err := svc.Update(ctx, sub.ID.String(), model.UpdateSubscriptionRequest{StartDate: strPtr("05-2024")}, nil)
+			if policy == model.OverlapPolicyReject {
+				if errorCode(err) != CodeSubscriptionOverlap {
+					t.Fatalf("Update: error %v, want %s", err, CodeSubscriptionOverlap)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			if !updated.OverlapAllowed {
+				t.Fatal("Update: overlap_allowed = false, want true")
+			}
+		})
+	}
+}
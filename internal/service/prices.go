@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"effective-mobile-subscriptions/internal/model"
+	"effective-mobile-subscriptions/internal/repository"
+	"github.com/google/uuid"
+)
+
+// изменить цену подписки начиная с месяца effective_from; возвращает итоговый график цен
+func (s *SubscriptionService) ChangePrice(ctx context.Context, idStr string, req model.PriceChangeRequest) ([]model.PriceChange, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, ValidationError(CodeInvalidUUID, "id", "incorrect format ID (expected UUID)")
+	}
+	v := &validator{}
+	change := model.PriceChange{Price: req.Price}
+	if req.Price <= 0 {
+		v.add(CodePriceNonPositive, "price", "price must be greater than zero")
+	}
+	if req.EffectiveFrom == "" {
+		v.add(CodeRequiredField, "effective_from", "effective_from is required")
+	} else if effectiveFrom, err := ParseMonthYear("effective_from", req.EffectiveFrom); v.check(err) {
+		change.EffectiveFrom = effectiveFrom
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	var schedule []model.PriceChange
+	err = s.Repo.WithTx(ctx, func(tx repository.Store) error {
+		sub, err := tx.GetByIDForUpdate(ctx, id)
+		if err != nil {
+			log.Printf("ERROR: Failed to fetch subscription %s for price change: %v", idStr, err)
+			return fmt.Errorf("failed to retrieve subscription for price change: %w", err)
+		}
+		if sub == nil {
+			return ErrSubscriptionNotFound
+		}
+		if change.EffectiveFrom.Before(monthStart(sub.StartDate)) {
+			return ValidationError(CodePriceChangeOutOfPeriod, "effective_from", "effective_from cannot be earlier than the start_date month")
+		}
+		if sub.EndDate != nil && change.EffectiveFrom.After(*sub.EndDate) {
+			return ValidationError(CodePriceChangeOutOfPeriod, "effective_from", "effective_from cannot be later than end_date")
+		}
+		if change.EffectiveFrom.Equal(monthStart(sub.StartDate)) {
+			// изменение с месяца начала исправляет исходную цену подписки
+			if err := correctInitialPrice(ctx, tx, sub, change.Price); err != nil {
+				return err
+			}
+		} else if err := tx.SetPriceChange(ctx, id, change); err != nil {
+			log.Printf("ERROR: Failed to save price change for subscription %s: %v", idStr, err)
+			return fmt.Errorf("failed to save price change: %w", err)
+		}
+		schedule, err = priceSchedule(ctx, tx, sub)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// заменить исходную цену подписки; версия увеличивается, изменение попадает в историю как updated
+func correctInitialPrice(ctx context.Context, tx repository.SubscriptionStore, sub *model.Subscription, price int) error {
+	if sub.Price == price {
+		return nil
+	}
+	sub.Price = price
+	if err := tx.Update(ctx, sub); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return ErrVersionMismatch
+		}
+		log.Printf("ERROR: Failed to correct initial price of subscription %s: %v", sub.ID, err)
+		return fmt.Errorf("failed to save initial price: %w", err)
+	}
+	return nil
+}
+
+// цена, валюта и период списания начавшейся подписки действуют с месяца начала: их изменение через PUT
+// переписало бы стоимость уже прошедших месяцев. Исходную цену можно исправить изменением цены
+// с месяца начала (POST /subscriptions/{id}/price-changes)
+func checkStartedTerms(before, after *model.Subscription) error {
+	if !monthStart(before.StartDate).Before(monthStart(time.Now())) {
+		return nil
+	}
+	v := &validator{}
+	if after.Price != before.Price {
+		v.add(CodePriceChangeRequired, "price",
+			"price of a subscription that started before the current month is changed via POST /subscriptions/{id}/price-changes")
+	}
+	if after.Currency != before.Currency {
+		v.add(CodeStartedTermsLocked, "currency", "currency of a subscription that started before the current month cannot be changed")
+	}
+	if after.BillingPeriod != before.BillingPeriod {
+		v.add(CodeStartedTermsLocked, "billing_period", "billing_period of a subscription that started before the current month cannot be changed")
+	}
+	return v.err()
+}
+
+// получить график цен подписки: исходная цена с месяца начала и последующие изменения
+func (s *SubscriptionService) PriceSchedule(ctx context.Context, idStr string) ([]model.PriceChange, error) {
+	sub, err := s.GetByID(ctx, idStr)
+	if err != nil {
+		return nil, err
+	}
+	return priceSchedule(ctx, s.Repo, sub)
+}
+
+// исходная цена и изменения, которые действуют после месяца начала подписки
+func priceSchedule(ctx context.Context, repo repository.PriceStore, sub *model.Subscription) ([]model.PriceChange, error) {
+	changes, err := repo.ListPriceChanges(ctx, sub.ID)
+	if err != nil {
+		log.Printf("ERROR: Failed to fetch price changes for subscription %s: %v", sub.ID, err)
+		return nil, fmt.Errorf("failed to retrieve price changes: %w", err)
+	}
+	start := monthStart(sub.StartDate)
+	schedule := []model.PriceChange{{EffectiveFrom: start, Price: sub.Price}}
+	for _, change := range changes {
+		if change.EffectiveFrom.After(start) {
+			schedule = append(schedule, change)
+		}
+	}
+	return schedule, nil
+}
+
+// первое число месяца указанной даты
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"effective-mobile-subscriptions/internal/model"
+)
+
+func TestUpdateRejectsTermsOfStartedSubscription(t *testing.T) {
+	future := time.Now().AddDate(0, 2, 0).Format(monthYearLayout)
+	newPrice := 500
+	tests := []struct {
+		name string
+		req  model.UpdateSubscriptionRequest
+		want string
+	}{
+		{"price", model.UpdateSubscriptionRequest{Price: &newPrice}, CodePriceChangeRequired},
+		{"currency", model.UpdateSubscriptionRequest{Currency: strPtr("USD")}, CodeStartedTermsLocked},
+		{"billing period", model.UpdateSubscriptionRequest{BillingPeriod: strPtr(model.BillingYearly)}, CodeStartedTermsLocked},
+		// проверка идет по start_date до изменения: перенос в будущее не открывает смену цены
+		{"price with start moved to future", model.UpdateSubscriptionRequest{Price: &newPrice, StartDate: &future}, CodePriceChangeRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t)
+			sub := mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Yandex Plus", Price: 400, StartDate: "01-2024"})
+			_, err := svc.Update(context.Background(), sub.ID.String(), tt.req, nil)
+			if code := errorCode(err); code != tt.want {
+				t.Fatalf("Update: error code %q (%v), want %q", code, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateKeepsUnchangedTermsOfStartedSubscription(t *testing.T) {
+	svc := newTestService(t)
+	sub := mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Yandex Plus", Price: 400, StartDate: "01-2024"})
+
+	samePrice := 400
+	req := model.UpdateSubscriptionRequest{Price: &samePrice, Currency: strPtr("rub"), ServiceName: strPtr("Kinopoisk")}
+	if _, err := svc.Update(context.Background(), sub.ID.String(), req, nil); err != nil {
+		t.Fatalf("Update with unchanged terms: %v", err)
+	}
+}
+
+func TestUpdateAllowsPriceOfFutureSubscription(t *testing.T) {
+	svc := newTestService(t)
+	start := time.Now().AddDate(0, 2, 0).Format(monthYearLayout)
+	sub := mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Yandex Plus", Price: 400, StartDate: start})
+
+	newPrice := 500
+	updated, err := svc.Update(context.Background(), sub.ID.String(), model.UpdateSubscriptionRequest{Price: &newPrice}, nil)
+	if err != nil {
+		t.Fatalf("Update price: %v", err)
+	}
+	if updated.Price != newPrice {
+		t.Fatalf("price = %d, want %d", updated.Price, newPrice)
+	}
+}
+
+func TestPriceChangeKeepsPastMonths(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	sub := mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Yandex Plus", Price: 400, StartDate: "01-2024", EndDate: strPtr("12-2024")})
+
+	if _, err := svc.ChangePrice(ctx, sub.ID.String(), model.PriceChangeRequest{Price: 500, EffectiveFrom: "07-2024"}); err != nil {
+		t.Fatalf("ChangePrice: %v", err)
+	}
+	tests := []struct {
+		from, to string
+		want     int
+	}{
+		{"01-2024", "06-2024", 6 * 400},
+		{"07-2024", "12-2024", 6 * 500},
+		{"01-2024", "12-2024", 6*400 + 6*500},
+	}
+	for _, tt := range tests {
+		total, _, err := svc.GetCostAnalytics(ctx, model.CostAnalyticsRequest{StartDateStr: tt.from, EndDateStr: tt.to})
+		if err != nil {
+			t.Fatalf("GetCostAnalytics(%s..%s): %v", tt.from, tt.to, err)
+		}
+		if total != tt.want {
+			t.Errorf("GetCostAnalytics(%s..%s) = %d, want %d", tt.from, tt.to, total, tt.want)
+		}
+	}
+}
+
+func TestPriceChangeFromStartMonthCorrectsInitialPrice(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	sub := mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Yandex Plus", Price: 400, StartDate: "2024-01-15", EndDate: strPtr("12-2024")})
+
+	if _, err := svc.ChangePrice(ctx, sub.ID.String(), model.PriceChangeRequest{Price: 500, EffectiveFrom: "07-2024"}); err != nil {
+		t.Fatalf("ChangePrice(07-2024): %v", err)
+	}
+	schedule, err := svc.ChangePrice(ctx, sub.ID.String(), model.PriceChangeRequest{Price: 450, EffectiveFrom: "01-2024"})
+	if err != nil {
+		t.Fatalf("ChangePrice(01-2024): %v", err)
+	}
+	if len(schedule) != 2 || schedule[0].Price != 450 || schedule[1].Price != 500 {
+		t.Fatalf("schedule = %+v, want 450 from 01-2024 and 500 from 07-2024", schedule)
+	}
+	corrected, err := svc.GetByID(ctx, sub.ID.String())
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if corrected.Price != 450 || corrected.Version != sub.Version+1 {
+		t.Fatalf("price = %d, version = %d; want 450, %d", corrected.Price, corrected.Version, sub.Version+1)
+	}
+	total, _, err := svc.GetCostAnalytics(ctx, model.CostAnalyticsRequest{StartDateStr: "02-2024", EndDateStr: "06-2024"})
+	if err != nil {
+		t.Fatalf("GetCostAnalytics: %v", err)
+	}
+	if total != 5*450 {
+		t.Fatalf("GetCostAnalytics(02-2024..06-2024) = %d, want %d", total, 5*450)
+	}
+
+	_, err = svc.ChangePrice(ctx, sub.ID.String(), model.PriceChangeRequest{Price: 450, EffectiveFrom: "12-2023"})
+	if code := errorCode(err); code != CodePriceChangeOutOfPeriod {
+		t.Fatalf("ChangePrice(12-2023): error code %q (%v), want %q", code, err, CodePriceChangeOutOfPeriod)
+	}
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"effective-mobile-subscriptions/internal/model"
+	"effective-mobile-subscriptions/internal/repository"
+	"github.com/google/uuid"
+)
+
+// ограничения длины полей записи каталога (как в таблицах services и service_aliases)
+const (
+	maxServiceNameLength     = 255
+	maxServiceCategoryLength = 100
+)
+
+// возвращается из транзакции пробного сопоставления, чтобы откатить изменения
+var errDryRun = errors.New("dry run")
+
+// создать запись каталога сервисов
+func (s *SubscriptionService) CreateService(ctx context.Context, req model.ServiceRequest) (*model.Service, error) {
+	service, err := s.prepareService(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Repo.WithTx(ctx, func(tx repository.Store) error {
+		if err := checkServiceNames(ctx, tx, service); err != nil {
+			return err
+		}
+		if err := tx.CreateService(ctx, service); err != nil {
+			if errors.Is(err, repository.ErrServiceNameTaken) {
+				return errServiceNameTaken("")
+			}
+			log.Printf("ERROR: Failed to create service %q in repository: %v", service.Name, err)
+			return fmt.Errorf("failed to save service: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+// получить запись каталога по ID
+func (s *SubscriptionService) GetService(ctx context.Context, idStr string) (*model.Service, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, ValidationError(CodeInvalidUUID, "id", "incorrect format ID (expected UUID)")
+	}
+	service, err := s.Repo.GetService(ctx, id)
+	if err != nil {
+		log.Printf("ERROR: GetService failed to fetch service %s from repository: %v", idStr, err)
+		return nil, fmt.Errorf("service error when receiving a catalogue entry: %w", err)
+	}
+	if service == nil {
+		return nil, ErrServiceNotFound
+	}
+	return service, nil
+}
+
+// получить записи каталога; пустая category — все категории
+func (s *SubscriptionService) ListServices(ctx context.Context, category string) ([]model.Service, error) {
+	services, err := s.Repo.ListServices(ctx, strings.TrimSpace(category))
+	if err != nil {
+		log.Printf("ERROR: ListServices failed to fetch services from repository: %v", err)
+		return nil, fmt.Errorf("service error when receiving the catalogue: %w", err)
+	}
+	return services, nil
+}
+
+// заменить запись каталога. При смене канонического названия подписки, сохраненные под прежним,
+// переводятся на новое в той же транзакции
+func (s *SubscriptionService) UpdateService(ctx context.Context, idStr string, req model.ServiceRequest) (*model.Service, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, ValidationError(CodeInvalidUUID, "id", "incorrect format ID (expected UUID)")
+	}
+	service, err := s.prepareService(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	service.ID = id
+	err = s.Repo.WithTx(ctx, func(tx repository.Store) error {
+		existing, err := tx.GetService(ctx, id)
+		if err != nil {
+			log.Printf("ERROR: Failed to fetch service %s from repository: %v", idStr, err)
+			return fmt.Errorf("failed to retrieve service for update: %w", err)
+		}
+		if existing == nil {
+			return ErrServiceNotFound
+		}
+		if err := checkServiceNames(ctx, tx, service); err != nil {
+			return err
+		}
+		if err := tx.UpdateService(ctx, service); err != nil {
+			if errors.Is(err, repository.ErrServiceNameTaken) {
+				return errServiceNameTaken("")
+			}
+			log.Printf("ERROR: Failed to update service %s in repository: %v", idStr, err)
+			return fmt.Errorf("failed to save updated service: %w", err)
+		}
+		if existing.Name == service.Name {
+			return nil
+		}
+		remapped, overlapAllowed, err := tx.RemapSubscriptions(ctx, existing.Name, service)
+		if err != nil {
+			log.Printf("ERROR: Failed to move subscriptions of %q to %q: %v", existing.Name, service.Name, err)
+			return fmt.Errorf("failed to rename subscriptions of service: %w", err)
+		}
+		log.Printf("Service %s renamed from %q to %q, %d subscriptions moved (%d marked as allowed overlap)",
+			id, existing.Name, service.Name, remapped, overlapAllowed)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+// удалить запись каталога; подписки сохраняют название
+func (s *SubscriptionService) DeleteService(ctx context.Context, idStr string) error {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return ValidationError(CodeInvalidUUID, "id", "incorrect format ID (expected UUID)")
+	}
+	deleted, err := s.Repo.DeleteService(ctx, id)
+	if err != nil {
+		log.Printf("ERROR: DeleteService failed to remove service %s from repository: %v", idStr, err)
+		return fmt.Errorf("service error when deleting a catalogue entry: %w", err)
+	}
+	if !deleted {
+		return ErrServiceNotFound
+	}
+	return nil
+}
+
+// сопоставить названия сервисов из подписок с каталогом и перевести подписки на канонические названия.
+// apply=false — пробный прогон: изменения откатываются, но результат сопоставления возвращается полностью
+func (s *SubscriptionService) MapServiceNames(ctx context.Context, apply bool) ([]model.ServiceMapping, error) {
+	var mappings []model.ServiceMapping
+	err := s.Repo.WithTx(ctx, func(tx repository.Store) error {
+		names, err := tx.ListServiceNames(ctx)
+		if err != nil {
+			log.Printf("ERROR: Failed to fetch service names from repository: %v", err)
+			return fmt.Errorf("failed to retrieve service names: %w", err)
+		}
+		for i := range names {
+			service, err := resolveService(ctx, tx, names[i].ServiceName)
+			if err != nil {
+				return err
+			}
+			if service == nil {
+				continue
+			}
+			names[i].Service = service
+			names[i].Remapped, names[i].OverlapAllowed, err = tx.RemapSubscriptions(ctx, names[i].ServiceName, service)
+			if err != nil {
+				return fmt.Errorf("failed to remap subscriptions of %q: %w", names[i].ServiceName, err)
+			}
+		}
+		mappings = names
+		if !apply {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return mappings, nil
+}
+
+// найти запись каталога по названию сервиса из запроса; nil, если название пустое или не найдено
+func resolveService(ctx context.Context, repo repository.ServiceCatalogStore, name string) (*model.Service, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, nil
+	}
+	service, err := repo.ResolveService(ctx, name)
+	if err != nil {
+		log.Printf("ERROR: Failed to resolve service name %q in repository: %v", name, err)
+		return nil, fmt.Errorf("failed to resolve service name: %w", err)
+	}
+	return service, nil
+}
+
+// сопоставить название сервиса подписки с каталогом: найденное заменяется каноническим, а подписка
+// получает ссылку на запись; не найденное сохраняется как есть и без ссылки
+func applyService(ctx context.Context, repo repository.ServiceCatalogStore, sub *model.Subscription) error {
+	service, err := resolveService(ctx, repo, sub.ServiceName)
+	if err != nil {
+		return err
+	}
+	sub.ServiceID = nil
+	if service != nil {
+		sub.ServiceName = service.Name
+		sub.ServiceID = &service.ID
+	}
+	return nil
+}
+
+// проверить, что название и синонимы записи не заняты другой записью каталога
+func checkServiceNames(ctx context.Context, repo repository.ServiceCatalogStore, service *model.Service) error {
+	for _, name := range append([]string{service.Name}, service.Aliases...) {
+		other, err := resolveService(ctx, repo, name)
+		if err != nil {
+			return err
+		}
+		if other != nil && other.ID != service.ID {
+			return errServiceNameTaken(fmt.Sprintf("%q is already used by service %q", name, other.Name))
+		}
+	}
+	return nil
+}
+
+// ошибка service_name_taken; пустое message — без указания занятого названия
+func errServiceNameTaken(message string) error {
+	if message == "" {
+		message = "service name or alias is already used by another service"
+	}
+	return &Error{Kind: ErrConflict, Code: CodeServiceNameTaken, Message: message}
+}
+
+// провалидировать запрос записи каталога и проверить, что для валюты цены по умолчанию есть курс
+func (s *SubscriptionService) prepareService(ctx context.Context, req model.ServiceRequest) (*model.Service, error) {
+	service, err := parseServiceRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if service.DefaultPrice == nil {
+		return service, nil
+	}
+	if service.Currency == "" {
+		service.Currency = s.Rates.Base()
+	} else if _, err := s.conversionRates(ctx, "currency", service.Currency); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+// провалидировать запрос записи каталога: названия приводятся к одиночным пробелам, синонимы,
+// совпадающие с названием или друг с другом без учета регистра, отбрасываются
+func parseServiceRequest(req model.ServiceRequest) (*model.Service, error) {
+	v := &validator{}
+	service := &model.Service{
+		Name:         strings.Join(strings.Fields(req.Name), " "),
+		Category:     strings.TrimSpace(req.Category),
+		DefaultPrice: req.DefaultPrice,
+		Aliases:      make([]string, 0, len(req.Aliases)),
+	}
+	if service.Name == "" {
+		v.add(CodeRequiredField, "name", "name is required")
+	} else if utf8.RuneCountInString(service.Name) > maxServiceNameLength {
+		v.add(CodeInvalidParameter, "name", fmt.Sprintf("name cannot be longer than %d characters", maxServiceNameLength))
+	}
+	seen := map[string]bool{repository.ServiceKey(service.Name): true}
+	for _, alias := range req.Aliases {
+		alias = strings.Join(strings.Fields(alias), " ")
+		if alias == "" {
+			v.add(CodeRequiredField, "aliases", "aliases cannot contain empty names")
+			continue
+		}
+		if utf8.RuneCountInString(alias) > maxServiceNameLength {
+			v.add(CodeInvalidParameter, "aliases", fmt.Sprintf("alias cannot be longer than %d characters", maxServiceNameLength))
+			continue
+		}
+		if key := repository.ServiceKey(alias); !seen[key] {
+			seen[key] = true
+			service.Aliases = append(service.Aliases, alias)
+		}
+	}
+	if utf8.RuneCountInString(service.Category) > maxServiceCategoryLength {
+		v.add(CodeInvalidParameter, "category", fmt.Sprintf("category cannot be longer than %d characters", maxServiceCategoryLength))
+	}
+	if req.DefaultPrice != nil && *req.DefaultPrice <= 0 {
+		v.add(CodePriceNonPositive, "default_price", "default_price must be greater than zero")
+	}
+	if req.Currency != "" {
+		if req.DefaultPrice == nil {
+			v.add(CodeInvalidParameter, "currency", "currency can only be set together with default_price")
+		} else if currency, err := parseCurrency("currency", req.Currency); v.check(err) {
+			service.Currency = currency
+		}
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"effective-mobile-subscriptions/internal/model"
+)
+
+// последнее событие истории подписки
+func lastEvent(t *testing.T, svc *SubscriptionService, sub *model.Subscription) model.SubscriptionEvent {
+	t.Helper()
+	events, err := svc.History(context.Background(), sub.ID.String())
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	return events[len(events)-1]
+}
+
+// название сервиса из снимка события
+func snapshotServiceName(t *testing.T, snapshot json.RawMessage) string {
+	t.Helper()
+	var sub model.Subscription
+	if err := json.Unmarshal(snapshot, &sub); err != nil {
+		t.Fatalf("decode event snapshot %s: %v", snapshot, err)
+	}
+	return sub.ServiceName
+}
+
+func TestMapServiceNamesRecordsEvents(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	sub := mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Яндекс Плюс", Price: 400, StartDate: "01-2024"})
+	if _, err := svc.CreateService(ctx, model.ServiceRequest{Name: "Yandex Plus", Aliases: []string{"Яндекс Плюс"}}); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+
+	if _, err := svc.MapServiceNames(ctx, false); err != nil {
+		t.Fatalf("MapServiceNames dry run: %v", err)
+	}
+	if event := lastEvent(t, svc, sub); event.Type != model.EventCreated {
+		t.Fatalf("dry run left a %s event", event.Type)
+	}
+
+	if _, err := svc.MapServiceNames(ctx, true); err != nil {
+		t.Fatalf("MapServiceNames: %v", err)
+	}
+	event := lastEvent(t, svc, sub)
+	if event.Type != model.EventUpdated {
+		t.Fatalf("last event = %s, want %s", event.Type, model.EventUpdated)
+	}
+	if before, after := snapshotServiceName(t, event.Before), snapshotServiceName(t, event.After); before != "Яндекс Плюс" || after != "Yandex Plus" {
+		t.Fatalf("event service_name %q -> %q, want %q -> %q", before, after, "Яндекс Плюс", "Yandex Plus")
+	}
+}
+
+func TestRenameServiceRecordsEvents(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	service, err := svc.CreateService(ctx, model.ServiceRequest{Name: "Kinopoisk"})
+	if err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	sub := mustCreate(t, svc, model.CreateSubscriptionRequest{ServiceName: "Kinopoisk", Price: 300, StartDate: "01-2024"})
+
+	if _, err := svc.UpdateService(ctx, service.ID.String(), model.ServiceRequest{Name: "Кинопоиск", Aliases: []string{"Kinopoisk"}}); err != nil {
+		t.Fatalf("UpdateService: %v", err)
+	}
+	event := lastEvent(t, svc, sub)
+	if event.Type != model.EventUpdated || snapshotServiceName(t, event.After) != "Кинопоиск" {
+		t.Fatalf("last event = %s with %s, want %s to Кинопоиск", event.Type, event.After, model.EventUpdated)
+	}
+}
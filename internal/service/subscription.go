@@ -2,56 +2,97 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
 	"time"
 
 	"effective-mobile-subscriptions/internal/model"
+	"effective-mobile-subscriptions/internal/rates"
 	"effective-mobile-subscriptions/internal/repository"
 	"github.com/google/uuid"
 )
 
 // определить методы бизнес-логики
 type SubscriptionService struct {
-	Repo *repository.SubscriptionRepository
+	Repo repository.Store
+	// источник курсов: базовая валюта по умолчанию и пересчет аналитики
+	Rates rates.Provider
+	// срок действия ключей идемпотентности
+	IdempotencyTTL time.Duration
+	// политика пересечений подписок одного пользователя на один сервис: reject, warn или allow
+	OverlapPolicy string
 }
 
-func NewSubscriptionService(repo *repository.SubscriptionRepository) *SubscriptionService {
-	return &SubscriptionService{Repo: repo}
+func NewSubscriptionService(repo repository.Store, rateProvider rates.Provider) *SubscriptionService {
+	return &SubscriptionService{
+		Repo:           repo,
+		Rates:          rateProvider,
+		IdempotencyTTL: DefaultIdempotencyTTL,
+		OverlapPolicy:  model.OverlapPolicyReject,
+	}
 }
 
 // создать подписку
 func (s *SubscriptionService) Create(ctx context.Context, req model.CreateSubscriptionRequest) (*model.Subscription, error) {
-	if err := ValidateCreateRequest(req); err != nil {
+	sub, err := s.prepareCreate(ctx, req)
+	if err != nil {
 		return nil, err
 	}
-	userID, err := uuid.Parse(req.UserID)
+	err = s.Repo.WithTx(ctx, func(tx repository.Store) error {
+		return s.saveNew(ctx, tx, sub)
+	})
 	if err != nil {
-		return nil, ValidationError("incorrect format user_id (expected UUID)")
+		return nil, err
+	}
+	return sub, nil
+}
+
+// проверить пересечения и сохранить новую подписку, переведя ошибки хранилища в ошибки сервиса
+func (s *SubscriptionService) saveNew(ctx context.Context, repo repository.Store, sub *model.Subscription) error {
+	if err := s.checkOverlaps(ctx, repo, sub); err != nil {
+		return err
+	}
+	if err := repo.Create(ctx, sub); err != nil {
+		if errors.Is(err, repository.ErrEndBeforeStart) {
+			return errEndBeforeStart("end_date")
+		}
+		if errors.Is(err, repository.ErrOverlap) {
+			// параллельный запрос успел создать пересекающуюся подписку
+			return errOverlap(nil)
+		}
+		log.Printf("ERROR: Failed to create subscription in repository: %v", err)
+		return fmt.Errorf("failed to save subscription: %w", err)
 	}
-	startDate, err := ParseMonthYear("start_date", req.StartDate)
+	return nil
+}
+
+// провалидировать запрос на создание и построить подписку с валютой по умолчанию. Название сервиса
+// сопоставляется с каталогом; без price подставляется цена по умолчанию из каталога, если валюта не задана другая
+func (s *SubscriptionService) prepareCreate(ctx context.Context, req model.CreateSubscriptionRequest) (*model.Subscription, error) {
+	service, err := resolveService(ctx, s.Repo, req.ServiceName)
 	if err != nil {
 		return nil, err
 	}
-	var endDate *time.Time
-	if req.EndDate != nil && *req.EndDate != "" {
-		parsedEndDate, err := ParseMonthYear("end_date", *req.EndDate)
-		if err != nil {
-			return nil, err
+	if service != nil {
+		req.ServiceName = service.Name
+		if req.Price == 0 && service.DefaultPrice != nil && (req.Currency == "" || strings.EqualFold(strings.TrimSpace(req.Currency), service.Currency)) {
+			req.Price = *service.DefaultPrice
+			req.Currency = service.Currency
 		}
-		endDate = &parsedEndDate
 	}
-	sub := &model.Subscription{
-		ServiceName: req.ServiceName,
-		Price:       req.Price,
-		UserID:      userID,
-		StartDate:   startDate,
-		EndDate:     endDate,
+	sub, err := parseCreateRequest(req)
+	if err != nil {
+		return nil, err
 	}
-	if err := s.Repo.Create(ctx, sub); err != nil {
-		log.Printf("ERROR: Failed to create subscription in repository: %v", err)
-		return nil, fmt.Errorf("failed to save subscription: %w", err)
+	if service != nil {
+		sub.ServiceID = &service.ID
+	}
+	if sub.Currency == "" {
+		sub.Currency = s.Rates.Base()
+	} else if _, err := s.conversionRates(ctx, "currency", sub.Currency); err != nil {
+		return nil, err
 	}
 	return sub, nil
 }
@@ -60,7 +101,7 @@ func (s *SubscriptionService) Create(ctx context.Context, req model.CreateSubscr
 func (s *SubscriptionService) GetByID(ctx context.Context, idStr string) (*model.Subscription, error) {
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		return nil, ValidationError("incorrect format ID (expected UUID)")
+		return nil, ValidationError(CodeInvalidUUID, "id", "incorrect format ID (expected UUID)")
 	}
 	sub, err := s.Repo.GetByID(ctx, id)
 	if err != nil {
@@ -68,164 +109,179 @@ func (s *SubscriptionService) GetByID(ctx context.Context, idStr string) (*model
 		return nil, fmt.Errorf("service error when receiving a subscription: %w", err)
 	}
 	if sub == nil {
-		return nil, ErrNotFound
+		return nil, ErrSubscriptionNotFound
 	}
 	return sub, nil
 }
 
-// обновить существующую подписку (только переданные поля)
-func (s *SubscriptionService) Update(ctx context.Context, id string, req model.UpdateSubscriptionRequest) (*model.Subscription, error) {
-	if err := ValidateUpdateRequest(req); err != nil {
+// обновить существующую подписку (только переданные поля).
+// expectedVersion — версия из If-Match; nil означает обновление без проверки версии клиентом
+func (s *SubscriptionService) Update(ctx context.Context, id string, req model.UpdateSubscriptionRequest, expectedVersion *int) (*model.Subscription, error) {
+	patch, err := parseUpdateRequest(req)
+	if err != nil {
 		return nil, err
 	}
 	subID, err := uuid.Parse(id)
 	if err != nil {
-		return nil, ValidationError("incorrect format subscription ID (expected UUID)")
-	}
-	existingSub, err := s.Repo.GetByID(ctx, subID)
-	if err != nil {
-		log.Printf("ERROR: Failed to fetch existing subscription %s from repository: %v", id, err)
-		return nil, fmt.Errorf("failed to retrieve subscription for update: %w", err)
-	}
-	if existingSub == nil {
-		return nil, ErrNotFound
+		return nil, ValidationError(CodeInvalidUUID, "id", "incorrect format subscription ID (expected UUID)")
 	}
-	if req.ServiceName != nil {
-		existingSub.ServiceName = *req.ServiceName
-	}
-	if req.Price != nil {
-		existingSub.Price = *req.Price
-	}
-	if req.StartDate != nil {
-		startDate, err := ParseMonthYear("start_date", *req.StartDate)
-		if err != nil {
+	if patch.currency != nil {
+		if _, err := s.conversionRates(ctx, "currency", *patch.currency); err != nil {
 			return nil, err
 		}
-		existingSub.StartDate = startDate
 	}
-	if req.EndDate != nil {
-		if *req.EndDate != "" {
-			parsedEndDate, err := ParseMonthYear("end_date", *req.EndDate)
-			if err != nil {
-				return nil, err
+	var updatedSub *model.Subscription
+	err = s.Repo.WithTx(ctx, func(tx repository.Store) error {
+		existingSub, err := tx.GetByIDForUpdate(ctx, subID)
+		if err != nil {
+			log.Printf("ERROR: Failed to fetch existing subscription %s from repository: %v", id, err)
+			return fmt.Errorf("failed to retrieve subscription for update: %w", err)
+		}
+		if existingSub == nil {
+			return ErrSubscriptionNotFound
+		}
+		if expectedVersion != nil && existingSub.Version != *expectedVersion {
+			return ErrVersionMismatch
+		}
+		original := *existingSub
+		patch.apply(existingSub)
+		if err := checkStartedTerms(&original, existingSub); err != nil {
+			return err
+		}
+		if patch.serviceName != nil {
+			if err := applyService(ctx, tx, existingSub); err != nil {
+				return err
 			}
-			existingSub.EndDate = &parsedEndDate
-		} else {
-			existingSub.EndDate = nil
 		}
+		if existingSub.EndDate != nil && existingSub.EndDate.Before(existingSub.StartDate) {
+			return errEndBeforeStart(patch.changedPeriodField())
+		}
+		// пересечения проверяются, только если изменился сервис или период: иначе уже допущенное
+		// пересечение не мешало бы менять, например, цену
+		if patch.changesPeriod() {
+			if err := s.checkOverlaps(ctx, tx, existingSub); err != nil {
+				return err
+			}
+		}
+		if err := tx.Update(ctx, existingSub); err != nil {
+			if errors.Is(err, repository.ErrEndBeforeStart) {
+				return errEndBeforeStart(patch.changedPeriodField())
+			}
+			if errors.Is(err, repository.ErrOverlap) {
+				return errOverlap(nil)
+			}
+			if errors.Is(err, repository.ErrVersionConflict) {
+				return ErrVersionMismatch
+			}
+			log.Printf("ERROR: Failed to update subscription %s in repository: %v", id, err)
+			return fmt.Errorf("failed to save updated subscription: %w", err)
+		}
+		updatedSub = existingSub
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	if err := s.Repo.Update(ctx, existingSub); err != nil {
-		log.Printf("ERROR: Failed to update subscription %s in repository: %v", id, err)
-		return nil, fmt.Errorf("failed to save updated subscription: %w", err)
-	}
-	return existingSub, nil
+	return updatedSub, nil
 }
 
-// удалить подписку по ID
-func (s *SubscriptionService) Delete(ctx context.Context, idStr string) (bool, error) {
+// удалить подписку по ID; expectedVersion — версия из If-Match (nil — без проверки)
+func (s *SubscriptionService) Delete(ctx context.Context, idStr string, expectedVersion *int) (bool, error) {
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		return false, ValidationError("incorrect format ID (expected UUID)")
+		return false, ValidationError(CodeInvalidUUID, "id", "incorrect format ID (expected UUID)")
+	}
+	deleted, err := s.Repo.Delete(ctx, id, expectedVersion)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		return false, ErrVersionMismatch
 	}
-	deleted, err := s.Repo.Delete(ctx, id)
 	if err != nil {
 		log.Printf("ERROR: Delete failed to remove subscription for ID %s from repository: %v", idStr, err)
 		return false, fmt.Errorf("service error when deleting a subscription: %w", err)
 	}
 	if !deleted {
-		return false, ErrNotFound
+		return false, ErrSubscriptionNotFound
 	}
 	return true, nil
 }
 
-// получить все подписки
-func (s *SubscriptionService) List(ctx context.Context) ([]model.Subscription, error) {
-	subscriptions, err := s.Repo.List(ctx)
+// восстановить мягко удаленную подписку; для действующей подписки операция ничего не меняет
+func (s *SubscriptionService) Restore(ctx context.Context, idStr string) (*model.Subscription, error) {
+	id, err := uuid.Parse(idStr)
 	if err != nil {
-		log.Printf("ERROR: List failed to retrieve subscriptions from repository: %v", err)
-		return nil, fmt.Errorf("service error while retrieving list: %w", err)
-	}
-	return subscriptions, nil
-}
-
-// получить суммарную стоимость по фильтрам
-func (s *SubscriptionService) GetCostAnalytics(ctx context.Context, req model.CostAnalyticsRequest) (int, error) {
-	filters := model.CostAnalyticsRequest{
-		UserID:      req.UserID,
-		ServiceName: req.ServiceName,
-	}
-	var startDateParsed *time.Time
-	var endDateParsed *time.Time
-	if req.StartDateStr != "" {
-		startDate, err := ParseMonthYear("start_date_from", req.StartDateStr)
-		if err != nil {
-			return 0, err
+		return nil, ValidationError(CodeInvalidUUID, "id", "incorrect format ID (expected UUID)")
+	}
+	var restoredSub *model.Subscription
+	err = s.Repo.WithTx(ctx, func(tx repository.Store) error {
+		// за время удаления могла появиться подписка на тот же сервис, пересекающаяся с восстанавливаемой
+		var overlaps []uuid.UUID
+		sub, err := tx.Restore(ctx, id, func(sub *model.Subscription) error {
+			sub.DeletedAt = nil
+			if err := s.checkOverlaps(ctx, tx, sub); err != nil {
+				return err
+			}
+			overlaps = sub.Overlaps
+			return nil
+		})
+		if errors.Is(err, repository.ErrOverlap) {
+			return errOverlap(nil)
+		}
+		if errors.Is(err, ErrConflict) {
+			return err
 		}
-		startDateParsed = &startDate
-		filters.StartDateStr = startDate.Format("2006-01-02")
-	}
-	if req.EndDateStr != "" {
-		endDate, err := ParseMonthYear("start_date_to", req.EndDateStr)
 		if err != nil {
-			return 0, err
+			log.Printf("ERROR: Restore failed for subscription %s in repository: %v", idStr, err)
+			return fmt.Errorf("service error when restoring a subscription: %w", err)
 		}
-		endDateParsed = &endDate
-		filters.EndDateStr = endDate.Format("2006-01-02")
-	}
-	if startDateParsed != nil && endDateParsed != nil && startDateParsed.After(*endDateParsed) {
-		return 0, ValidationError("start_date_from cannot be after start_date_to")
-	}
-	if req.UserID != "" {
-		if _, err := uuid.Parse(req.UserID); err != nil {
-			return 0, ValidationError("incorrect format user_id (expected UUID)")
+		if sub == nil {
+			// подписка не удалена (повторный запрос) или не существует
+			if sub, err = tx.GetByIDForUpdate(ctx, id); err != nil {
+				return fmt.Errorf("service error when restoring a subscription: %w", err)
+			}
+			if sub == nil {
+				return ErrSubscriptionNotFound
+			}
 		}
-	}
-	totalCost, err := s.Repo.GetTotalCost(ctx, filters)
+		sub.Overlaps = overlaps
+		restoredSub = sub
+		return nil
+	})
 	if err != nil {
-		log.Printf("ERROR: GetCostAnalytics failed to execute total cost query in repository: %v", err)
-		return 0, fmt.Errorf("service error while receiving analytics: %w", err)
+		return nil, err
 	}
-	return totalCost, nil
+	return restoredSub, nil
 }
 
-const monthYearLayout = "01-2006"
-
-func ParseMonthYear(fieldName, value string) (time.Time, error) {
-	parsed, err := time.Parse(monthYearLayout, value)
+// получить историю изменений подписки (в том числе удаленной)
+func (s *SubscriptionService) History(ctx context.Context, idStr string) ([]model.SubscriptionEvent, error) {
+	id, err := uuid.Parse(idStr)
 	if err != nil {
-		return time.Time{}, ValidationError(fmt.Sprintf("incorrect format %s (expected MM-YYYY)", fieldName))
+		return nil, ValidationError(CodeInvalidUUID, "id", "incorrect format ID (expected UUID)")
 	}
-	return parsed, nil
-}
-
-func ValidateCreateRequest(req model.CreateSubscriptionRequest) error {
-	if strings.TrimSpace(req.ServiceName) == "" {
-		return ValidationError("service_name is required")
-	}
-	if req.Price <= 0 {
-		return ValidationError("price must be greater than zero")
-	}
-	if strings.TrimSpace(req.UserID) == "" {
-		return ValidationError("user_id is required")
+	events, err := s.Repo.ListEvents(ctx, id)
+	if err != nil {
+		log.Printf("ERROR: History failed to fetch events for subscription %s from repository: %v", idStr, err)
+		return nil, fmt.Errorf("service error when receiving subscription history: %w", err)
 	}
-	if strings.TrimSpace(req.StartDate) == "" {
-		return ValidationError("start_date is required")
+	if len(events) == 0 {
+		// подписки, созданные до появления истории, событий не имеют
+		sub, err := s.Repo.GetByID(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("service error when receiving subscription history: %w", err)
+		}
+		if sub == nil {
+			return nil, ErrSubscriptionNotFound
+		}
 	}
-	return nil
+	return events, nil
 }
 
-func ValidateUpdateRequest(req model.UpdateSubscriptionRequest) error {
-	if req.ServiceName == nil && req.Price == nil && req.StartDate == nil && req.EndDate == nil {
-		return ValidationError("at least one field must be provided for update")
-	}
-	if req.ServiceName != nil && strings.TrimSpace(*req.ServiceName) == "" {
-		return ValidationError("service_name cannot be empty")
-	}
-	if req.Price != nil && *req.Price <= 0 {
-		return ValidationError("price must be greater than zero")
-	}
-	if req.StartDate != nil && strings.TrimSpace(*req.StartDate) == "" {
-		return ValidationError("start_date cannot be empty")
+// окончательно удалить подписки, мягко удаленные более retention назад
+func (s *SubscriptionService) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	purged, err := s.Repo.Purge(ctx, time.Now().Add(-retention))
+	if err != nil {
+		log.Printf("ERROR: Failed to purge deleted subscriptions: %v", err)
+		return 0, fmt.Errorf("service error when purging deleted subscriptions: %w", err)
 	}
-	return nil
+	return purged, nil
 }
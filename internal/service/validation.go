@@ -0,0 +1,274 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"effective-mobile-subscriptions/internal/model"
+	"effective-mobile-subscriptions/internal/rates"
+	"github.com/google/uuid"
+)
+
+const monthYearLayout = "01-2006"
+
+// дата с точностью до дня (ISO 8601)
+const dateLayout = "2006-01-02"
+
+// собирает ошибки валидации по всем полям запроса, не останавливаясь на первой
+type validator struct {
+	errs []*Error
+}
+
+// добавить ошибку по полю
+func (v *validator) add(code, field, message string) {
+	v.errs = append(v.errs, &Error{Kind: ErrValidation, Code: code, Field: field, Message: message})
+}
+
+// добавить ошибку, возвращенную функцией разбора поля (например, ParseMonthYear)
+func (v *validator) check(err error) bool {
+	if err == nil {
+		return true
+	}
+	if fieldErr, ok := err.(*Error); ok {
+		v.errs = append(v.errs, fieldErr)
+	} else {
+		v.add(CodeInvalidParameter, "", err.Error())
+	}
+	return false
+}
+
+// итоговая ошибка: nil, если проблем не найдено
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationErrors{Errors: v.errs}
+}
+
+func ParseMonthYear(fieldName, value string) (time.Time, error) {
+	parsed, err := time.Parse(monthYearLayout, value)
+	if err != nil {
+		return time.Time{}, ValidationError(CodeInvalidMonthYear, fieldName, fmt.Sprintf("incorrect format %s (expected MM-YYYY)", fieldName))
+	}
+	return parsed, nil
+}
+
+// разобрать дату в формате MM-YYYY или YYYY-MM-DD; вместе с датой возвращает её точность
+// (model.DatePrecisionMonth или model.DatePrecisionDay)
+func ParseDate(fieldName, value string) (time.Time, string, error) {
+	if parsed, err := time.Parse(monthYearLayout, value); err == nil {
+		return parsed, model.DatePrecisionMonth, nil
+	}
+	if parsed, err := time.Parse(dateLayout, value); err == nil {
+		return parsed, model.DatePrecisionDay, nil
+	}
+	return time.Time{}, "", ValidationError(CodeInvalidMonthYear, fieldName, fmt.Sprintf("incorrect format %s (expected MM-YYYY or YYYY-MM-DD)", fieldName))
+}
+
+// последний день месяца указанной даты
+func monthEnd(t time.Time) time.Time {
+	return monthStart(t).AddDate(0, 1, -1)
+}
+
+// привести код валюты к верхнему регистру и проверить формат ISO 4217
+func parseCurrency(fieldName, value string) (string, error) {
+	currency := strings.ToUpper(strings.TrimSpace(value))
+	if !rates.ValidCurrency(currency) {
+		return "", ValidationError(CodeInvalidCurrency, fieldName, fmt.Sprintf("incorrect format %s (expected ISO 4217 code, e.g. RUB)", fieldName))
+	}
+	return currency, nil
+}
+
+// разобрать период списания: weekly, monthly, quarterly или yearly
+func parseBillingPeriod(fieldName, value string) (string, error) {
+	period := strings.ToLower(strings.TrimSpace(value))
+	switch period {
+	case model.BillingWeekly, model.BillingMonthly, model.BillingQuarterly, model.BillingYearly:
+		return period, nil
+	}
+	return "", ValidationError(CodeInvalidBillingPeriod, fieldName, fmt.Sprintf("incorrect %s (expected %s, %s, %s or %s)",
+		fieldName, model.BillingWeekly, model.BillingMonthly, model.BillingQuarterly, model.BillingYearly))
+}
+
+// провалидировать запрос на создание и построить по нему подписку
+func parseCreateRequest(req model.CreateSubscriptionRequest) (*model.Subscription, error) {
+	v := &validator{}
+	sub := &model.Subscription{ServiceName: req.ServiceName, Price: req.Price, BillingPeriod: model.BillingMonthly, DatePrecision: model.DatePrecisionMonth}
+	if strings.TrimSpace(req.ServiceName) == "" {
+		v.add(CodeRequiredField, "service_name", "service_name is required")
+	}
+	if req.Price <= 0 {
+		v.add(CodePriceNonPositive, "price", "price must be greater than zero")
+	}
+	if req.Currency != "" {
+		if currency, err := parseCurrency("currency", req.Currency); v.check(err) {
+			sub.Currency = currency
+		}
+	}
+	if req.BillingPeriod != "" {
+		if period, err := parseBillingPeriod("billing_period", req.BillingPeriod); v.check(err) {
+			sub.BillingPeriod = period
+		}
+	}
+	if strings.TrimSpace(req.UserID) == "" {
+		v.add(CodeRequiredField, "user_id", "user_id is required")
+	} else if userID, err := uuid.Parse(req.UserID); err != nil {
+		v.add(CodeInvalidUUID, "user_id", "incorrect format user_id (expected UUID)")
+	} else {
+		sub.UserID = userID
+	}
+	startValid := false
+	if strings.TrimSpace(req.StartDate) == "" {
+		v.add(CodeRequiredField, "start_date", "start_date is required")
+	} else {
+		startDate, precision, err := ParseDate("start_date", req.StartDate)
+		startValid = v.check(err)
+		sub.StartDate = startDate
+		if precision == model.DatePrecisionDay {
+			sub.DatePrecision = model.DatePrecisionDay
+		}
+	}
+	if req.EndDate != nil && *req.EndDate != "" {
+		endDate, precision, err := ParseDate("end_date", *req.EndDate)
+		if v.check(err) {
+			if precision == model.DatePrecisionDay {
+				sub.DatePrecision = model.DatePrecisionDay
+			} else if sub.DatePrecision == model.DatePrecisionDay {
+				// месяц окончания при точности до дня означает его последний день
+				endDate = monthEnd(endDate)
+			}
+			sub.EndDate = &endDate
+			if startValid && endDate.Before(sub.StartDate) {
+				v.check(errEndBeforeStart("end_date"))
+			}
+		}
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// провалидированные изменения из запроса на обновление
+type subscriptionPatch struct {
+	serviceName *string
+	price       *int
+	currency    *string
+	billing     *string
+	startDate   *time.Time
+	endDateSet  bool
+	endDate     *time.Time
+	// хотя бы одна из переданных дат задана с точностью до дня
+	dayPrecision bool
+	// end_date передана в формате MM-YYYY
+	endDateMonth bool
+}
+
+// применить изменения к подписке
+func (p *subscriptionPatch) apply(sub *model.Subscription) {
+	if p.serviceName != nil {
+		sub.ServiceName = *p.serviceName
+	}
+	if p.price != nil {
+		sub.Price = *p.price
+	}
+	if p.currency != nil {
+		sub.Currency = *p.currency
+	}
+	if p.billing != nil {
+		sub.BillingPeriod = *p.billing
+	}
+	if p.startDate != nil {
+		sub.StartDate = *p.startDate
+	}
+	endDateMonth := sub.DatePrecision != model.DatePrecisionDay
+	if p.endDateSet {
+		sub.EndDate = p.endDate
+		endDateMonth = p.endDateMonth
+	}
+	if p.dayPrecision {
+		sub.DatePrecision = model.DatePrecisionDay
+	}
+	if sub.DatePrecision == model.DatePrecisionDay && endDateMonth && sub.EndDate != nil {
+		// месяц окончания при точности до дня означает его последний день
+		endDate := monthEnd(*sub.EndDate)
+		sub.EndDate = &endDate
+	}
+}
+
+// последний день действия подписки по end_date из запроса
+func (p *subscriptionPatch) periodEnd() time.Time {
+	if p.endDateMonth && p.dayPrecision {
+		return monthEnd(*p.endDate)
+	}
+	return *p.endDate
+}
+
+// изменяются ли сервис или период подписки, от которых зависят пересечения
+func (p *subscriptionPatch) changesPeriod() bool {
+	return p.serviceName != nil || p.startDate != nil || p.endDateSet
+}
+
+// поле периода, изменение которого сделало период некорректным
+func (p *subscriptionPatch) changedPeriodField() string {
+	if p.endDateSet {
+		return "end_date"
+	}
+	return "start_date"
+}
+
+// ошибка периода, в котором end_date раньше start_date
+func errEndBeforeStart(field string) error {
+	return ValidationError(CodeEndBeforeStart, field, "end_date cannot be earlier than start_date")
+}
+
+// провалидировать запрос на обновление и разобрать переданные поля
+func parseUpdateRequest(req model.UpdateSubscriptionRequest) (*subscriptionPatch, error) {
+	if req.ServiceName == nil && req.Price == nil && req.Currency == nil && req.BillingPeriod == nil && req.StartDate == nil && req.EndDate == nil {
+		return nil, ValidationError(CodeNoFieldsToUpdate, "", "at least one field must be provided for update")
+	}
+	v := &validator{}
+	patch := &subscriptionPatch{serviceName: req.ServiceName, price: req.Price}
+	if req.ServiceName != nil && strings.TrimSpace(*req.ServiceName) == "" {
+		v.add(CodeRequiredField, "service_name", "service_name cannot be empty")
+	}
+	if req.Price != nil && *req.Price <= 0 {
+		v.add(CodePriceNonPositive, "price", "price must be greater than zero")
+	}
+	if req.Currency != nil {
+		if currency, err := parseCurrency("currency", *req.Currency); v.check(err) {
+			patch.currency = &currency
+		}
+	}
+	if req.BillingPeriod != nil {
+		if period, err := parseBillingPeriod("billing_period", *req.BillingPeriod); v.check(err) {
+			patch.billing = &period
+		}
+	}
+	if req.StartDate != nil {
+		if strings.TrimSpace(*req.StartDate) == "" {
+			v.add(CodeRequiredField, "start_date", "start_date cannot be empty")
+		} else if startDate, precision, err := ParseDate("start_date", *req.StartDate); v.check(err) {
+			patch.startDate = &startDate
+			patch.dayPrecision = precision == model.DatePrecisionDay
+		}
+	}
+	if req.EndDate != nil {
+		patch.endDateSet = true
+		if *req.EndDate != "" {
+			if endDate, precision, err := ParseDate("end_date", *req.EndDate); v.check(err) {
+				patch.endDate = &endDate
+				patch.endDateMonth = precision == model.DatePrecisionMonth
+				patch.dayPrecision = patch.dayPrecision || precision == model.DatePrecisionDay
+			}
+		}
+	}
+	if patch.startDate != nil && patch.endDate != nil && patch.periodEnd().Before(*patch.startDate) {
+		v.check(errEndBeforeStart("end_date"))
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
@@ -0,0 +1,36 @@
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+-- период действия подписки как полуоткрытый диапазон дат: end_date при точности month означает весь месяц,
+-- при точности day — включительно; NULL — бессрочно. Некорректный период (end_date < start_date) пуст
+CREATE OR REPLACE FUNCTION subscription_period(start_date DATE, end_date DATE, date_precision VARCHAR)
+RETURNS daterange
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT daterange(start_date, CASE
+        WHEN end_date IS NULL THEN NULL
+        WHEN end_date < start_date THEN start_date
+        WHEN date_precision = 'day' THEN end_date + 1
+        ELSE (date_trunc('month', end_date::timestamp) + INTERVAL '1 month')::date
+    END, '[)')
+$$;
+
+-- пересечение с другими подписками того же сервиса допущено политикой warn/allow
+ALTER TABLE subscriptions ADD COLUMN overlap_allowed BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- уже существующие пересечения допускаются: из каждой группы под ограничение попадает только самая ранняя подписка.
+-- найти их можно через GET /subscriptions/overlaps
+UPDATE subscriptions s SET overlap_allowed = TRUE
+WHERE s.deleted_at IS NULL AND EXISTS (
+    SELECT 1 FROM subscriptions o
+    WHERE o.user_id = s.user_id
+      AND o.service_name = s.service_name
+      AND o.deleted_at IS NULL
+      AND (o.created_at, o.id) < (s.created_at, s.id)
+      AND subscription_period(o.start_date, o.end_date, o.date_precision) && subscription_period(s.start_date, s.end_date, s.date_precision)
+);
+
+-- у пользователя не может быть двух действующих подписок на один сервис с пересекающимися периодами
+ALTER TABLE subscriptions ADD CONSTRAINT excl_subscriptions_overlap EXCLUDE USING gist (
+    user_id WITH =,
+    service_name WITH =,
+    subscription_period(start_date, end_date, date_precision) WITH &&
+) WHERE (deleted_at IS NULL AND NOT overlap_allowed);
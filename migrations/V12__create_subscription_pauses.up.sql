@@ -0,0 +1,15 @@
+-- приостановки подписок: месяцы с paused_from до resumed_from (не включая) не оплачиваются
+-- и не учитываются аналитикой; resumed_from NULL — подписка приостановлена до возобновления
+CREATE TABLE IF NOT EXISTS subscription_pauses (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id uuid NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    paused_from DATE NOT NULL,
+    resumed_from DATE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_subscription_pauses_period CHECK (resumed_from IS NULL OR resumed_from > paused_from)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_pauses_subscription_id ON subscription_pauses (subscription_id, paused_from);
+
+-- у подписки не больше одной незавершенной приостановки
+CREATE UNIQUE INDEX IF NOT EXISTS uq_subscription_pauses_open ON subscription_pauses (subscription_id) WHERE resumed_from IS NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS service_id;
+
+DROP TABLE IF EXISTS service_aliases;
+DROP TABLE IF EXISTS services;
@@ -0,0 +1,25 @@
+-- каталог сервисов: каноническое название, категория и цена по умолчанию (в валюте currency)
+CREATE TABLE IF NOT EXISTS services (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    category VARCHAR(100) NOT NULL DEFAULT '',
+    default_price INTEGER NULL CHECK (default_price > 0),
+    currency CHAR(3) NULL CONSTRAINT chk_services_currency CHECK (currency ~ '^[A-Z]{3}$'),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- названия, по которым находится сервис: каноническое название и синонимы.
+-- alias_key — нормализованная форма (нижний регистр, одиночные пробелы), уникальная во всем каталоге
+CREATE TABLE IF NOT EXISTS service_aliases (
+    alias_key VARCHAR(255) PRIMARY KEY,
+    service_id uuid NOT NULL REFERENCES services (id) ON DELETE CASCADE,
+    alias VARCHAR(255) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_service_aliases_service_id ON service_aliases (service_id);
+
+-- запись каталога, с которой сопоставлена подписка; NULL — название не найдено в каталоге
+ALTER TABLE subscriptions ADD COLUMN service_id uuid NULL REFERENCES services (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_id ON subscriptions (service_id);
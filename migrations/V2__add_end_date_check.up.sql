@@ -0,0 +1,5 @@
+-- NOT VALID: ограничение действует для новых и изменяемых строк, не блокируя миграцию из-за уже существующих.
+-- найти нарушающие строки: `check-dates`; после исправления:
+-- ALTER TABLE subscriptions VALIDATE CONSTRAINT chk_subscriptions_end_date;
+ALTER TABLE subscriptions
+    ADD CONSTRAINT chk_subscriptions_end_date CHECK (end_date IS NULL OR end_date >= start_date) NOT VALID;
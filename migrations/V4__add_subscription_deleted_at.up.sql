@@ -0,0 +1,3 @@
+ALTER TABLE subscriptions ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_deleted_at ON subscriptions (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,12 @@
+-- история изменений подписок; без внешнего ключа, чтобы история переживала окончательное удаление подписки
+CREATE TABLE IF NOT EXISTS subscription_events (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id uuid NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    actor VARCHAR(128) NOT NULL,
+    before JSONB NULL,
+    after JSONB NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_events_subscription_id ON subscription_events (subscription_id, id);
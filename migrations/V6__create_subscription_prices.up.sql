@@ -0,0 +1,10 @@
+-- изменения цены подписки, действующие с первого числа месяца effective_from.
+-- subscriptions.price остается ценой с начала подписки до первого изменения
+CREATE TABLE IF NOT EXISTS subscription_prices (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id uuid NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    effective_from DATE NOT NULL,
+    price INTEGER NOT NULL CHECK (price >= 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_subscription_prices_effective_from UNIQUE (subscription_id, effective_from)
+);
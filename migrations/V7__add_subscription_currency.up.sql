@@ -0,0 +1,11 @@
+-- валюта цены подписки (ISO 4217); существующие подписки считаются рублевыми
+ALTER TABLE subscriptions ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'RUB'
+    CONSTRAINT chk_subscriptions_currency CHECK (currency ~ '^[A-Z]{3}$');
+
+-- курсы валют к базовой валюте (rates.base) для источника курсов table:
+-- 1 единица currency = rate единиц базовой валюты
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    currency CHAR(3) PRIMARY KEY,
+    rate NUMERIC(18, 6) NOT NULL CHECK (rate > 0),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
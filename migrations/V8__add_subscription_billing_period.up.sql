@@ -0,0 +1,3 @@
+-- период списания цены; существующие подписки считаются ежемесячными
+ALTER TABLE subscriptions ADD COLUMN billing_period VARCHAR(16) NOT NULL DEFAULT 'monthly'
+    CONSTRAINT chk_subscriptions_billing_period CHECK (billing_period IN ('weekly', 'monthly', 'quarterly', 'yearly'));
@@ -0,0 +1,4 @@
+-- точность дат подписки: month — даты заданы как MM-YYYY и end_date означает весь месяц,
+-- day — даты заданы с точностью до дня, end_date включительно
+ALTER TABLE subscriptions ADD COLUMN date_precision VARCHAR(8) NOT NULL DEFAULT 'month'
+    CONSTRAINT chk_subscriptions_date_precision CHECK (date_precision IN ('month', 'day'));
@@ -0,0 +1,8 @@
+// Package migrations содержит SQL-миграции схемы, встраиваемые в бинарник.
+// Файлы именуются V<версия>__<описание>.up.sql / .down.sql.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS